@@ -3,13 +3,27 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Log retention env vars are shared with verify-full; flags override them.
+const (
+	envLogsKeep    = "CI_SELF_LOGS_KEEP"
+	envLogsTTLDays = "CI_SELF_LOGS_TTL_DAYS"
+	envLogsMaxMB   = "CI_SELF_LOGS_MAX_MB"
+)
+
+// verifyFullRunPattern is the <stamp> of out/logs/verify-full/<stamp> and
+// out/evidence/<stamp>.tar.gz; other entries there are left alone.
+var verifyFullRunPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z$`)
+
 type entry struct {
 	name    string
 	full    string
@@ -22,6 +36,7 @@ type config struct {
 	apply          bool
 	ttlLogsDays    int
 	keepLogs       int
+	maxLogsMB      int
 	keepReviewpack int
 	keepGHA        int
 	maxDelete      int
@@ -43,11 +58,16 @@ func main() {
 }
 
 func parseConfig() (config, bool) {
-	cfg := config{}
+	cfg, err := logRetentionFromEnv(os.Getenv)
+	if err != nil {
+		fmt.Printf("ERROR: config reason=invalid_env err=%v\n", err)
+		return config{}, false
+	}
 	flag.StringVar(&cfg.repo, "repo", "", "repo root (default: current dir)")
 	flag.BoolVar(&cfg.apply, "apply", false, "apply deletions (default: dry-run)")
-	flag.IntVar(&cfg.ttlLogsDays, "ttl-logs-days", 14, "delete out/logs entries older than N days")
-	flag.IntVar(&cfg.keepLogs, "keep-logs", 5, "keep N newest out/logs entries")
+	flag.IntVar(&cfg.ttlLogsDays, "ttl-logs-days", cfg.ttlLogsDays, "delete out/logs entries older than N days")
+	flag.IntVar(&cfg.keepLogs, "keep-logs", cfg.keepLogs, "keep N newest out/logs entries (per section)")
+	flag.IntVar(&cfg.maxLogsMB, "max-logs-mb", cfg.maxLogsMB, "cap each out/logs section at N MiB (0: no cap)")
 	flag.IntVar(&cfg.keepReviewpack, "keep-reviewpack", 5, "keep latest.tar.gz + N newest review-pack-*.tar.gz")
 	flag.IntVar(&cfg.keepGHA, "keep-gha", 10, "keep N newest out/gha-artifacts/<run_id>/ dirs")
 	flag.IntVar(&cfg.maxDelete, "max-delete", 200, "max deletion count per section")
@@ -65,13 +85,38 @@ func parseConfig() (config, bool) {
 		fmt.Printf("ERROR: config reason=invalid_max_delete value=%d\n", cfg.maxDelete)
 		return config{}, false
 	}
-	if cfg.ttlLogsDays < 0 || cfg.keepLogs < 0 || cfg.keepReviewpack < 0 || cfg.keepGHA < 0 {
+	if cfg.ttlLogsDays < 0 || cfg.keepLogs < 0 || cfg.maxLogsMB < 0 || cfg.keepReviewpack < 0 || cfg.keepGHA < 0 {
 		fmt.Printf("ERROR: config reason=negative_parameter\n")
 		return config{}, false
 	}
 	return cfg, true
 }
 
+// logRetentionFromEnv reads the log retention defaults the same way
+// verify-full does, rejecting values that are not non-negative integers.
+func logRetentionFromEnv(getenv func(string) string) (config, error) {
+	cfg := config{keepLogs: 5, ttlLogsDays: 14}
+	for _, item := range []struct {
+		key   string
+		value *int
+	}{
+		{envLogsKeep, &cfg.keepLogs},
+		{envLogsTTLDays, &cfg.ttlLogsDays},
+		{envLogsMaxMB, &cfg.maxLogsMB},
+	} {
+		raw := strings.TrimSpace(getenv(item.key))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return config{}, fmt.Errorf("%s must be a non-negative integer: %q", item.key, raw)
+		}
+		*item.value = n
+	}
+	return cfg, nil
+}
+
 func run(cfg config) {
 	fmt.Printf("OK: gc_out start repo=%s apply=%t\n", cfg.repo, cfg.apply)
 	stop := false
@@ -82,6 +127,18 @@ func run(cfg config) {
 		stop = true
 	}
 
+	if stop {
+		fmt.Printf("SKIP: step=verify-full-logs reason=STOP\n")
+	} else if !stepVerifyFullLogs(cfg) {
+		stop = true
+	}
+
+	if stop {
+		fmt.Printf("SKIP: step=evidence reason=STOP\n")
+	} else if !stepEvidence(cfg) {
+		stop = true
+	}
+
 	if stop {
 		fmt.Printf("SKIP: step=reviewpack reason=STOP\n")
 	} else if !stepReviewpack(cfg) {
@@ -109,22 +166,109 @@ func stepLogs(cfg config) bool {
 		fmt.Printf("SKIP: step=%s reason=missing_dir path=%s\n", step, logsDir)
 		return true
 	}
+	// out/logs/verify-full is trimmed per run by stepVerifyFullLogs.
+	others := ents[:0]
+	for _, e := range ents {
+		if !(e.isDir && e.name == "verify-full") {
+			others = append(others, e)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].modTime.After(others[j].modTime) })
+	return removeLogTargets(step, cfg, others)
+}
 
-	cutoff := time.Now().Add(-time.Duration(cfg.ttlLogsDays) * 24 * time.Hour)
-	sort.Slice(ents, func(i, j int) bool { return ents[i].modTime.After(ents[j].modTime) })
+func stepVerifyFullLogs(cfg config) bool {
+	step := "verify-full-logs"
+	dir := filepath.Join(cfg.repo, "out", "logs", "verify-full")
+	ents, err := listDir(dir)
+	if err != nil {
+		fmt.Printf("SKIP: step=%s reason=missing_dir path=%s\n", step, dir)
+		return true
+	}
+	var runs []entry
+	for _, e := range ents {
+		if !e.isDir || !verifyFullRunPattern.MatchString(e.name) {
+			continue
+		}
+		// Age comes from the run stamp, matching verify-full's own retention.
+		if at, err := time.Parse("20060102T150405Z", e.name); err == nil {
+			e.modTime = at
+		}
+		runs = append(runs, e)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].name > runs[j].name })
+	return removeLogTargets(step, cfg, runs)
+}
+
+// stepEvidence trims verify-full's out/evidence/<stamp>.tar.gz bundles with
+// the log policy; each is a copy of one run's logs.
+func stepEvidence(cfg config) bool {
+	step := "evidence"
+	dir := filepath.Join(cfg.repo, "out", "evidence")
+	ents, err := listDir(dir)
+	if err != nil {
+		fmt.Printf("SKIP: step=%s reason=missing_dir path=%s\n", step, dir)
+		return true
+	}
+	var bundles []entry
+	for _, e := range ents {
+		stamp, ok := strings.CutSuffix(e.name, ".tar.gz")
+		if e.isDir || !ok || !verifyFullRunPattern.MatchString(stamp) {
+			continue
+		}
+		if at, err := time.Parse("20060102T150405Z", stamp); err == nil {
+			e.modTime = at
+		}
+		bundles = append(bundles, e)
+	}
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].name > bundles[j].name })
+	return removeLogTargets(step, cfg, bundles)
+}
+
+type logTarget struct {
+	entry
+	reason string
+	bytes  int64
+}
 
-	var targets []entry
+// removeLogTargets applies keep / TTL / size to newest-first entries and
+// reports each candidate (dry-run) or removed entry (--apply).
+func removeLogTargets(step string, cfg config, ents []entry) bool {
+	cutoff := time.Now().Add(-time.Duration(cfg.ttlLogsDays) * 24 * time.Hour)
+	maxBytes := int64(cfg.maxLogsMB) << 20
+	var kept int64
+	var targets []logTarget
 	for i, e := range ents {
-		if i >= cfg.keepLogs || e.modTime.Before(cutoff) {
-			targets = append(targets, e)
+		size, err := entryBytes(e.full)
+		if err != nil {
+			fmt.Printf("ERROR: step=%s reason=size_failed path=%s err=%v\n", step, e.full, err)
+			return false
+		}
+		reason := ""
+		switch {
+		case i >= cfg.keepLogs:
+			reason = "count"
+		case e.modTime.Before(cutoff):
+			reason = "age"
+		case maxBytes > 0 && kept+size > maxBytes:
+			reason = "size"
+		}
+		if reason == "" {
+			kept += size
+			continue
 		}
+		targets = append(targets, logTarget{entry: e, reason: reason, bytes: size})
 	}
+	policy := fmt.Sprintf("keep=%d ttl_days=%d max_mb=%d", cfg.keepLogs, cfg.ttlLogsDays, cfg.maxLogsMB)
 	if len(targets) == 0 {
-		fmt.Printf("OK: step=%s candidates=0 keep=%d ttl_days=%d\n", step, cfg.keepLogs, cfg.ttlLogsDays)
+		fmt.Printf("OK: step=%s candidates=0 %s\n", step, policy)
 		return true
 	}
 	if !cfg.apply {
-		fmt.Printf("SKIP: step=%s reason=apply=0 keep=%d ttl_days=%d candidates=%d\n", step, cfg.keepLogs, cfg.ttlLogsDays, len(targets))
+		for _, t := range targets {
+			fmt.Printf("SKIP: step=%s candidate=%s reason=%s bytes=%d\n", step, t.full, t.reason, t.bytes)
+		}
+		fmt.Printf("SKIP: step=%s reason=apply=0 %s candidates=%d\n", step, policy, len(targets))
 		return true
 	}
 
@@ -139,6 +283,7 @@ func stepLogs(cfg config) bool {
 			fmt.Printf("ERROR: step=%s reason=remove_failed path=%s err=%v\n", step, t.full, err)
 			return false
 		}
+		fmt.Printf("OK: step=%s removed=%s reason=%s bytes=%d\n", step, t.full, t.reason, t.bytes)
 		deleted++
 	}
 	if remaining > 0 {
@@ -149,6 +294,24 @@ func stepLogs(cfg config) bool {
 	return true
 }
 
+func entryBytes(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
 func stepReviewpack(cfg config) bool {
 	step := "reviewpack"
 	dir := filepath.Join(cfg.repo, "out", "reviewpack")
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stampLayout = "20060102T150405Z"
+
+// writeRun creates out/logs/verify-full/<name>/verify-full.log of size bytes.
+func writeRun(t *testing.T, repo, name string, size int) string {
+	t.Helper()
+	dir := filepath.Join(repo, "out", "logs", "verify-full", name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "verify-full.log"), bytes.Repeat([]byte("x"), size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func exists(t *testing.T, path string) bool {
+	t.Helper()
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestStepVerifyFullLogsOnlyRemovesStampDirs(t *testing.T) {
+	repo := t.TempDir()
+	run := writeRun(t, repo, time.Now().UTC().Add(-time.Hour).Format(stampLayout), 10)
+	others := []string{
+		writeRun(t, repo, "pinned", 10),
+		writeRun(t, repo, "2026-03-01", 10),
+	}
+	stray := filepath.Join(repo, "out", "logs", "verify-full", "20200101T000000Z.log")
+	if err := os.WriteFile(stray, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-365 * 24 * time.Hour)
+	for _, path := range append(others, stray) {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config{repo: repo, apply: true, keepLogs: 0, ttlLogsDays: 14, maxDelete: 200}
+	if !stepVerifyFullLogs(cfg) {
+		t.Fatal("stepVerifyFullLogs failed")
+	}
+	if exists(t, run) {
+		t.Fatalf("stamp run %s should be removed with keep=0", run)
+	}
+	for _, path := range append(others, stray) {
+		if !exists(t, path) {
+			t.Fatalf("%s is not a verify-full run and must be kept", path)
+		}
+	}
+}
+
+func TestStepVerifyFullLogsAgesByStamp(t *testing.T) {
+	repo := t.TempDir()
+	now := time.Now().UTC()
+	expired := writeRun(t, repo, now.Add(-30*24*time.Hour).Format(stampLayout), 10)
+	recent := writeRun(t, repo, now.Add(-time.Hour).Format(stampLayout), 10)
+	// Directory mtimes say the opposite; only the stamp counts.
+	old := now.Add(-60 * 24 * time.Hour)
+	if err := os.Chtimes(recent, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(expired, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config{repo: repo, apply: true, keepLogs: 5, ttlLogsDays: 14, maxDelete: 200}
+	if !stepVerifyFullLogs(cfg) {
+		t.Fatal("stepVerifyFullLogs failed")
+	}
+	if exists(t, expired) || !exists(t, recent) {
+		t.Fatalf("expired kept=%t recent kept=%t", exists(t, expired), exists(t, recent))
+	}
+}
+
+func TestStepVerifyFullLogsCapsSize(t *testing.T) {
+	repo := t.TempDir()
+	now := time.Now().UTC()
+	var runs []string
+	for i := range 3 {
+		runs = append(runs, writeRun(t, repo, now.Add(-time.Duration(i+1)*time.Hour).Format(stampLayout), 400<<10))
+	}
+
+	cfg := config{repo: repo, apply: true, keepLogs: 5, ttlLogsDays: 14, maxLogsMB: 1, maxDelete: 200}
+	if !stepVerifyFullLogs(cfg) {
+		t.Fatal("stepVerifyFullLogs failed")
+	}
+	// Newest first: 400 KiB + 400 KiB fit in 1 MiB, the third does not.
+	if !exists(t, runs[0]) || !exists(t, runs[1]) || exists(t, runs[2]) {
+		t.Fatalf("kept=%t,%t,%t", exists(t, runs[0]), exists(t, runs[1]), exists(t, runs[2]))
+	}
+}
+
+func TestLogRetentionFromEnvRejectsNegative(t *testing.T) {
+	env := map[string]string{envLogsKeep: "3", envLogsMaxMB: "64"}
+	cfg, err := logRetentionFromEnv(func(key string) string { return env[key] })
+	if err != nil || cfg.keepLogs != 3 || cfg.ttlLogsDays != 14 || cfg.maxLogsMB != 64 {
+		t.Fatalf("cfg=%+v err=%v", cfg, err)
+	}
+
+	env[envLogsTTLDays] = "-1"
+	_, err = logRetentionFromEnv(func(key string) string { return env[key] })
+	if err == nil || !strings.Contains(err.Error(), `CI_SELF_LOGS_TTL_DAYS must be a non-negative integer: "-1"`) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestStepEvidenceTrimsStampBundles(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "out", "evidence")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	recent := filepath.Join(dir, now.Add(-time.Hour).Format(stampLayout)+".tar.gz")
+	expired := filepath.Join(dir, now.Add(-30*24*time.Hour).Format(stampLayout)+".tar.gz")
+	other := filepath.Join(dir, "manual.tar.gz")
+	for _, path := range []string{recent, expired, other} {
+		if err := os.WriteFile(path, []byte("bundle"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config{repo: repo, apply: true, keepLogs: 5, ttlLogsDays: 14, maxDelete: 200}
+	if !stepEvidence(cfg) {
+		t.Fatal("stepEvidence failed")
+	}
+	if !exists(t, recent) || exists(t, expired) || !exists(t, other) {
+		t.Fatalf("recent=%t expired=%t other=%t", exists(t, recent), exists(t, expired), exists(t, other))
+	}
+}
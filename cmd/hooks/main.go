@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies hooks written by this command. Files without it are
+// never overwritten or removed.
+const hookMarker = "# ci-self-hook: managed by `ci-self hooks install`"
+
+// hookModes maps each installed hook to the verify-lite arguments it runs.
+var hookModes = []struct {
+	name string
+	args []string
+}{
+	{name: "pre-commit", args: []string{"--staged"}},
+	{name: "pre-push", args: nil},
+}
+
+type options struct {
+	command   string
+	repoDir   string
+	runnerDir string
+	hook      string
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		printUsage()
+		fmt.Printf("ERROR: hooks invalid_args=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(2)
+	}
+	if opts.command == "help" {
+		printUsage()
+		return
+	}
+
+	switch opts.command {
+	case "install":
+		err = install(opts, os.Stdout)
+	case "uninstall":
+		err = uninstall(opts, os.Stdout)
+	case "status":
+		err = status(opts, os.Stdout)
+	case "run":
+		err = runHook(opts, os.Stdout)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: hooks %s err=%s\n", opts.command, err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(1)
+	}
+	fmt.Println("STATUS: OK")
+}
+
+func printUsage() {
+	fmt.Println("Usage: ci-self hooks <install|uninstall|status> [--repo-dir path]")
+	fmt.Println()
+	fmt.Println("install    write pre-commit (verify-lite --staged) and pre-push (verify-lite) hooks")
+	fmt.Println("uninstall  remove hooks written by install; other hooks are left untouched")
+	fmt.Println("status     show each hook as installed, missing or foreign")
+	fmt.Println("run <hook> what the installed hooks execute (pre-commit | pre-push)")
+}
+
+func parseOptions(args []string) (options, error) {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		return options{command: "help"}, nil
+	}
+	opts := options{command: args[0]}
+	switch opts.command {
+	case "install", "uninstall", "status", "run":
+	default:
+		return options{}, fmt.Errorf("unknown_command=%s", opts.command)
+	}
+
+	fs := flag.NewFlagSet("hooks "+opts.command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	repoDir := fs.String("repo-dir", ".", "target git repository")
+	runnerDir := fs.String("runner-dir", ".", "ci-self-runner checkout the hooks run verify-lite from")
+	rest := args[1:]
+	if opts.command == "run" {
+		if len(rest) == 0 {
+			return options{}, errors.New("run requires a hook name")
+		}
+		opts.hook, rest = rest[0], rest[1:]
+		if _, ok := hookArgs(opts.hook); !ok {
+			return options{}, fmt.Errorf("unknown_hook=%s", opts.hook)
+		}
+	}
+	if err := fs.Parse(rest); err != nil {
+		return options{}, err
+	}
+	if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected_args=%s", strings.Join(fs.Args(), ","))
+	}
+	var err error
+	if opts.repoDir, err = filepath.Abs(*repoDir); err != nil {
+		return options{}, err
+	}
+	if opts.runnerDir, err = filepath.Abs(*runnerDir); err != nil {
+		return options{}, err
+	}
+	return opts, nil
+}
+
+func hookArgs(name string) ([]string, bool) {
+	for _, h := range hookModes {
+		if h.name == name {
+			return h.args, true
+		}
+	}
+	return nil, false
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// hooksDir resolves the hooks directory; git rev-parse honors core.hooksPath.
+func hooksDir(repoDir string) (string, error) {
+	dir, err := git(repoDir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoDir, dir)
+	}
+	return dir, nil
+}
+
+// hookScript is kept to a thin POSIX sh wrapper; the decision logic lives in
+// `hooks run`.
+func hookScript(name, runnerDir string) string {
+	return strings.Join([]string{
+		"#!/usr/bin/env sh",
+		hookMarker,
+		"# Remove with `ci-self hooks uninstall`; bypass once with `git " + gitBypass(name) + " --no-verify`.",
+		"set -eu",
+		`repo_dir="$(git rev-parse --show-toplevel)"`,
+		"cd " + shellQuote(runnerDir),
+		`exec go run ./cmd/hooks run ` + name + ` --repo-dir "$repo_dir" --runner-dir .`,
+		"",
+	}, "\n")
+}
+
+func gitBypass(name string) string {
+	if name == "pre-push" {
+		return "push"
+	}
+	return "commit"
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hookState is "installed", "missing" or "foreign" (written by someone else).
+func hookState(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "missing", nil
+		}
+		return "", err
+	}
+	if strings.Contains(string(content), hookMarker) {
+		return "installed", nil
+	}
+	return "foreign", nil
+}
+
+func install(opts options, out io.Writer) error {
+	dir, err := hooksDir(opts.repoDir)
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	for _, h := range hookModes {
+		path := filepath.Join(dir, h.name)
+		state, err := hookState(path)
+		if err != nil {
+			return err
+		}
+		if state == "foreign" {
+			conflicts = append(conflicts, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("existing hooks not written by ci-self: %s (move them aside or chain them manually)", strings.Join(conflicts, ", "))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, h := range hookModes {
+		path := filepath.Join(dir, h.name)
+		if err := os.WriteFile(path, []byte(hookScript(h.name, opts.runnerDir)), 0o755); err != nil {
+			return err
+		}
+		// WriteFile keeps the mode of an existing file.
+		if err := os.Chmod(path, 0o755); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "OK: hooks install hook=%s path=%s\n", h.name, path)
+	}
+	return nil
+}
+
+func uninstall(opts options, out io.Writer) error {
+	dir, err := hooksDir(opts.repoDir)
+	if err != nil {
+		return err
+	}
+	for _, h := range hookModes {
+		path := filepath.Join(dir, h.name)
+		state, err := hookState(path)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case "installed":
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "OK: hooks uninstall hook=%s path=%s\n", h.name, path)
+		case "foreign":
+			fmt.Fprintf(out, "SKIP: hooks uninstall hook=%s reason=not_managed_by_ci_self path=%s\n", h.name, path)
+		default:
+			fmt.Fprintf(out, "SKIP: hooks uninstall hook=%s reason=missing\n", h.name)
+		}
+	}
+	return nil
+}
+
+func status(opts options, out io.Writer) error {
+	dir, err := hooksDir(opts.repoDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "OK: hooks dir=%s\n", dir)
+	for _, h := range hookModes {
+		path := filepath.Join(dir, h.name)
+		state, err := hookState(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "OK: hooks hook=%s state=%s\n", h.name, state)
+	}
+	return nil
+}
+
+// runHook is what the installed hook executes. verify-lite always exits 0
+// and records the result in its status file, so the hook exit code is
+// derived from that file.
+func runHook(opts options, out io.Writer) error {
+	gitDir, err := git(opts.repoDir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return err
+	}
+	outDir := filepath.Join(gitDir, "ci-self", opts.hook)
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	statusPath := filepath.Join(outDir, "verify-lite.status")
+	_ = os.Remove(statusPath)
+
+	hookFlags, _ := hookArgs(opts.hook)
+	args := append([]string{"run", "./cmd/verify-lite"}, hookFlags...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = opts.runnerDir
+	cmd.Env = append(os.Environ(), "REPO_DIR="+opts.repoDir, "OUT_DIR="+outDir)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("verify-lite failed: %w", err)
+	}
+	st, reason := readStatus(statusPath)
+	if st != "OK" {
+		if st == "" {
+			return fmt.Errorf("status_file_missing path=%s", statusPath)
+		}
+		return fmt.Errorf("%s blocked status=%s reason=%s", opts.hook, st, reason)
+	}
+	fmt.Fprintf(out, "OK: hooks run hook=%s status=%s\n", opts.hook, st)
+	return nil
+}
+
+func readStatus(path string) (string, string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+	var st, reason string
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(line, "status="); ok && st == "" {
+			st = value
+		}
+		if value, ok := strings.CutPrefix(line, "reason="); ok {
+			reason = value
+		}
+	}
+	return st, reason
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func gitInit(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "ci@example.com"},
+		{"config", "user.name", "ci"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestInstallStatusUninstall(t *testing.T) {
+	repo := gitInit(t)
+	opts := options{repoDir: repo, runnerDir: "/opt/ci-self-runner"}
+
+	var out bytes.Buffer
+	if err := install(opts, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pre-commit", "pre-push"} {
+		path := filepath.Join(repo, ".git", "hooks", name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0o111 == 0 {
+			t.Fatalf("%s is not executable", name)
+		}
+		content, _ := os.ReadFile(path)
+		if !strings.Contains(string(content), "cd '/opt/ci-self-runner'") || !strings.Contains(string(content), "run "+name) {
+			t.Fatalf("%s content:\n%s", name, content)
+		}
+	}
+	// Reinstalling over our own hooks is allowed.
+	if err := install(opts, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := status(opts, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "state=installed") != 2 {
+		t.Fatalf("status:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := uninstall(opts, &out); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	_ = status(opts, &out)
+	if strings.Count(out.String(), "state=missing") != 2 {
+		t.Fatalf("status after uninstall:\n%s", out.String())
+	}
+}
+
+func TestInstallNeverClobbersForeignHooks(t *testing.T) {
+	repo := gitInit(t)
+	foreign := filepath.Join(repo, ".git", "hooks", "pre-push")
+	if err := os.WriteFile(foreign, []byte("#!/bin/sh\necho mine\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{repoDir: repo, runnerDir: "/opt/ci-self-runner"}
+
+	err := install(opts, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "not written by ci-self") {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "hooks", "pre-commit")); !os.IsNotExist(err) {
+		t.Fatal("install must not write any hook when one would be clobbered")
+	}
+
+	var out bytes.Buffer
+	if err := uninstall(opts, &out); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(foreign)
+	if string(content) != "#!/bin/sh\necho mine\n" || !strings.Contains(out.String(), "reason=not_managed_by_ci_self") {
+		t.Fatalf("foreign hook changed: %q out=%s", content, out.String())
+	}
+}
+
+func TestInstallRespectsCoreHooksPath(t *testing.T) {
+	repo := gitInit(t)
+	if _, err := git(repo, "config", "core.hooksPath", ".githooks"); err != nil {
+		t.Fatal(err)
+	}
+	if err := install(options{repoDir: repo, runnerDir: "/opt/ci-self-runner"}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".githooks", "pre-commit")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "hooks", "pre-commit")); !os.IsNotExist(err) {
+		t.Fatal("hook written to .git/hooks despite core.hooksPath")
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions([]string{"run", "pre-commit", "--repo-dir", "/tmp/r"})
+	if err != nil || opts.command != "run" || opts.hook != "pre-commit" || opts.repoDir != "/tmp/r" {
+		t.Fatalf("opts=%+v err=%v", opts, err)
+	}
+	if _, err := parseOptions([]string{"run", "post-merge"}); err == nil {
+		t.Fatal("expected unknown hook error")
+	}
+	if _, err := parseOptions([]string{"enable"}); err == nil {
+		t.Fatal("expected unknown command error")
+	}
+}
+
+// TestRunHookPreCommitBlocksUnformattedStagedFile runs the real verify-lite
+// in staged mode, as the installed pre-commit hook does.
+func TestRunHookPreCommitBlocksUnformattedStagedFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go run ./cmd/verify-lite")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	runnerDir, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := gitInit(t)
+	path := filepath.Join(repo, "main.go")
+	if err := os.WriteFile(path, []byte("package main\nfunc main(){}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(repo, "add", "main.go"); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{command: "run", hook: "pre-commit", repoDir: repo, runnerDir: runnerDir}
+
+	var out bytes.Buffer
+	err = runHook(opts, &out)
+	if err == nil || !strings.Contains(err.Error(), "unformatted staged files: main.go") {
+		t.Fatalf("err=%v out=%s", err, out.String())
+	}
+
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(repo, "add", "main.go"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runHook(opts, &out); err != nil {
+		t.Fatalf("err=%v out=%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "OK: hooks run hook=pre-commit status=OK") {
+		t.Fatalf("out=%s", out.String())
+	}
+}
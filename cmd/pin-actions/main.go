@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type options struct {
+	repoDir string
+	check   bool
+	apiURL  string
+	cache   string
+	token   string
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:], os.Getenv)
+	if err != nil {
+		printUsage()
+		fmt.Printf("ERROR: pin-actions invalid_args=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(2)
+	}
+
+	if err := run(opts, os.Stdout); err != nil {
+		fmt.Printf("ERROR: pin-actions %s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(1)
+	}
+	fmt.Println("STATUS: OK")
+}
+
+func parseOptions(args []string, getenv func(string) string) (options, error) {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printUsage()
+			os.Exit(0)
+		}
+	}
+
+	apiDefault := firstNonEmpty(getenv("CI_SELF_GITHUB_API_URL"), getenv("GITHUB_API_URL"), "https://api.github.com")
+	fs := flag.NewFlagSet("pin-actions", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	repoDir := fs.String("repo-dir", ".", "repository whose .github/workflows are pinned")
+	check := fs.Bool("check", false, "report unpinned uses and pinned SHAs whose tag has moved; do not rewrite")
+	apiURL := fs.String("api-url", apiDefault, "GitHub API base URL")
+	cache := fs.String("cache", "", "resolution cache file (default <repo-dir>/cache/pin-actions.json)")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected_args=%s", strings.Join(fs.Args(), ","))
+	}
+	opts := options{
+		repoDir: *repoDir,
+		check:   *check,
+		apiURL:  strings.TrimRight(*apiURL, "/"),
+		cache:   *cache,
+		token:   firstNonEmpty(getenv("GITHUB_TOKEN"), getenv("GH_TOKEN")),
+	}
+	if opts.cache == "" {
+		opts.cache = filepath.Join(opts.repoDir, "cache", "pin-actions.json")
+	}
+	return opts, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func printUsage() {
+	fmt.Println("Usage: ci-self pin-actions [--check] [--repo-dir path] [--api-url url] [--cache path]")
+	fmt.Println()
+	fmt.Println("Rewrites `uses: owner/repo@tag` in .github/workflows to the tag's commit SHA and keeps `# tag` as a comment.")
+	fmt.Println("--check only reports unpinned uses and pinned SHAs whose `# tag` now points elsewhere.")
+	fmt.Println("GITHUB_TOKEN / GH_TOKEN is sent when set. GITHUB_API_URL or --api-url selects the API host.")
+}
+
+// usesLinePattern matches a block-style `uses:` line, optionally as a list
+// item, with an optional quote and trailing comment.
+var usesLinePattern = regexp.MustCompile(`^(\s*(?:-\s+)?uses:\s*)(["']?)([^"'\s#@]+)@([^"'\s#]+)(["']?)(\s*#\s*(.*))?$`)
+
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// usesRef is one `uses:` occurrence in a workflow file.
+type usesRef struct {
+	file    string
+	line    int
+	action  string
+	ref     string
+	comment string
+}
+
+// repo returns owner/repo for actions in subdirectories and reusable workflows.
+func (u usesRef) repo() string {
+	parts := strings.SplitN(u.action, "/", 3)
+	if len(parts) < 2 {
+		return u.action
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// commentTag returns the tag recorded in a trailing comment such as "# v4".
+func (u usesRef) commentTag() string {
+	fields := strings.Fields(u.comment)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func parseUsesLine(file string, number int, line string) (usesRef, bool) {
+	m := usesLinePattern.FindStringSubmatch(line)
+	if m == nil || m[2] != m[5] {
+		return usesRef{}, false
+	}
+	action := m[3]
+	if strings.HasPrefix(action, "./") || strings.HasPrefix(action, "docker://") || !strings.Contains(action, "/") {
+		return usesRef{}, false
+	}
+	return usesRef{file: file, line: number, action: action, ref: m[4], comment: strings.TrimSpace(m[7])}, true
+}
+
+// pinLine rewrites one uses line to sha, keeping the tag (and any previous
+// comment text) as a trailing comment.
+func pinLine(line, sha string) string {
+	m := usesLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return line
+	}
+	comment := m[4]
+	if old := strings.TrimSpace(m[7]); old != "" {
+		comment += " " + old
+	}
+	return fmt.Sprintf("%s%s%s@%s%s # %s", m[1], m[2], m[3], sha, m[5], comment)
+}
+
+// resolver turns owner/repo@ref into a commit SHA via the GitHub API and a
+// JSON cache file.
+type resolver struct {
+	apiURL   string
+	token    string
+	client   *http.Client
+	cache    map[string]cacheEntry
+	useCache bool
+	dirty    bool
+}
+
+type cacheEntry struct {
+	SHA        string `json:"sha"`
+	ResolvedAt string `json:"resolved_at"`
+}
+
+func loadCache(path string) (map[string]cacheEntry, error) {
+	cache := map[string]cacheEntry{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return nil, fmt.Errorf("parse cache %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+func saveCache(path string, cache map[string]cacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+func (r *resolver) resolve(repo, ref string) (string, error) {
+	key := repo + "@" + ref
+	if r.useCache {
+		if entry, ok := r.cache[key]; ok && shaPattern.MatchString(entry.SHA) {
+			return entry.SHA, nil
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/repos/%s/commits/%s", r.apiURL, repo, url.PathEscape(ref))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", key, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve %s: http_status=%d", key, resp.StatusCode)
+	}
+	sha := strings.ToLower(strings.TrimSpace(string(body)))
+	if !shaPattern.MatchString(sha) {
+		return "", fmt.Errorf("resolve %s: unexpected response %q", key, sha)
+	}
+	r.cache[key] = cacheEntry{SHA: sha, ResolvedAt: time.Now().UTC().Format(time.RFC3339)}
+	r.dirty = true
+	return sha, nil
+}
+
+func workflowFiles(repoDir string) ([]string, error) {
+	root := filepath.Join(repoDir, ".github", "workflows")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")) {
+			continue
+		}
+		files = append(files, filepath.Join(root, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func run(opts options, out io.Writer) error {
+	mode := "rewrite"
+	if opts.check {
+		mode = "check"
+	}
+	fmt.Fprintf(out, "OK: pin-actions start mode=%s repo_dir=%s api_url=%s\n", mode, opts.repoDir, opts.apiURL)
+
+	files, err := workflowFiles(opts.repoDir)
+	if err != nil {
+		return fmt.Errorf("list_workflows err=%s", err.Error())
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(out, "SKIP: pin-actions reason=no_workflows")
+		return nil
+	}
+
+	cache, err := loadCache(opts.cache)
+	if err != nil {
+		return err
+	}
+	// --check must see where a tag points now, so it never trusts the cache.
+	res := &resolver{
+		apiURL:   opts.apiURL,
+		token:    opts.token,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		cache:    cache,
+		useCache: !opts.check,
+	}
+
+	problems := 0
+	changed := 0
+	for _, file := range files {
+		n, p, err := processFile(file, opts.check, res, out)
+		if err != nil {
+			return err
+		}
+		changed += n
+		problems += p
+	}
+	if res.dirty {
+		if err := saveCache(opts.cache, res.cache); err != nil {
+			fmt.Fprintf(out, "SKIP: pin-actions cache_write reason=%s\n", err.Error())
+		}
+	}
+
+	if opts.check {
+		if problems > 0 {
+			return fmt.Errorf("check_failed problems=%d", problems)
+		}
+		fmt.Fprintln(out, "OK: pin-actions check done")
+		return nil
+	}
+	if problems > 0 {
+		return fmt.Errorf("rewrite_failed unresolved=%d", problems)
+	}
+	fmt.Fprintf(out, "OK: pin-actions rewrite done pinned=%d\n", changed)
+	return nil
+}
+
+// processFile pins (or, in check mode, audits) every uses line in one file.
+// It returns the number of rewritten lines and the number of problems.
+func processFile(file string, check bool, res *resolver, out io.Writer) (int, int, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	rel := filepath.ToSlash(file)
+	lines := strings.Split(string(content), "\n")
+	changed, problems := 0, 0
+	for i, line := range lines {
+		ref, ok := parseUsesLine(rel, i+1, line)
+		if !ok {
+			continue
+		}
+		where := fmt.Sprintf("file=%s line=%d uses=%s@%s", ref.file, ref.line, ref.action, ref.ref)
+
+		if shaPattern.MatchString(ref.ref) {
+			if !check {
+				continue
+			}
+			tag := ref.commentTag()
+			if tag == "" {
+				fmt.Fprintf(out, "SKIP: pin-actions %s reason=no_tag_comment\n", where)
+				continue
+			}
+			current, err := res.resolve(ref.repo(), tag)
+			if err != nil {
+				fmt.Fprintf(out, "ERROR: pin-actions %s err=%s\n", where, err.Error())
+				problems++
+				continue
+			}
+			if !strings.EqualFold(current, ref.ref) {
+				fmt.Fprintf(out, "ERROR: pin-actions moved %s tag=%s current=%s\n", where, tag, current)
+				problems++
+				continue
+			}
+			fmt.Fprintf(out, "OK: pin-actions pinned %s tag=%s\n", where, tag)
+			continue
+		}
+
+		if check {
+			fmt.Fprintf(out, "ERROR: pin-actions unpinned %s\n", where)
+			problems++
+			continue
+		}
+		sha, err := res.resolve(ref.repo(), ref.ref)
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: pin-actions %s err=%s\n", where, err.Error())
+			problems++
+			continue
+		}
+		lines[i] = pinLine(line, sha)
+		changed++
+		fmt.Fprintf(out, "OK: pin-actions rewrite %s sha=%s\n", where, sha)
+	}
+	if changed > 0 {
+		info, err := os.Stat(file)
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+			return 0, 0, err
+		}
+	}
+	return changed, problems, nil
+}
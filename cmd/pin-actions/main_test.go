@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	checkoutV4 = "11bd71901bbe5b1630ceea73d27597364c9af683"
+	checkoutV5 = "08c6903cd8c0fde910a37f88322edcfb5dd907a8"
+	setupGoV5  = "d35c59abb061a4a6fb18e82ac0862c26744d6ab5"
+)
+
+// fakeGitHub serves GET /repos/{owner}/{repo}/commits/{ref} from tags.
+type fakeGitHub struct {
+	mu    sync.Mutex
+	tags  map[string]string
+	calls []string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, r.URL.Path)
+	if r.Header.Get("Accept") != "application/vnd.github.sha" {
+		http.Error(w, "bad accept", http.StatusBadRequest)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/repos/")
+	repo, ref, ok := strings.Cut(rest, "/commits/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	sha, ok := f.tags[repo+"@"+ref]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write([]byte(sha))
+}
+
+func writeWorkflow(t *testing.T, repoDir, content string) string {
+	t.Helper()
+	path := filepath.Join(repoDir, ".github", "workflows", "verify.yml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const unpinnedWorkflow = `name: verify
+on: [push]
+permissions:
+  contents: read
+jobs:
+  verify:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - name: Setup Go
+        uses: "actions/setup-go@v5" # toolchain
+      - uses: ./.github/actions/local
+      - uses: docker://alpine:3.20
+  reuse:
+    uses: octo/shared/.github/workflows/ci.yml@v1
+`
+
+func TestRunRewritesTagsToSHAAndKeepsTagComment(t *testing.T) {
+	api := &fakeGitHub{tags: map[string]string{
+		"actions/checkout@v4": checkoutV4,
+		"actions/setup-go@v5": setupGoV5,
+		"octo/shared@v1":      checkoutV5,
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	repoDir := t.TempDir()
+	path := writeWorkflow(t, repoDir, unpinnedWorkflow)
+	opts := options{repoDir: repoDir, apiURL: server.URL, cache: filepath.Join(repoDir, "cache", "pin-actions.json")}
+
+	var out bytes.Buffer
+	if err := run(opts, &out); err != nil {
+		t.Fatalf("run returned error: %v\n%s", err, out.String())
+	}
+	content, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"      - uses: actions/checkout@" + checkoutV4 + " # v4\n",
+		`        uses: "actions/setup-go@` + setupGoV5 + `" # v5 toolchain` + "\n",
+		"      - uses: ./.github/actions/local\n",
+		"      - uses: docker://alpine:3.20\n",
+		"    uses: octo/shared/.github/workflows/ci.yml@" + checkoutV5 + " # v1\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Fatalf("workflow missing %q:\n%s", want, content)
+		}
+	}
+	if !strings.Contains(out.String(), "OK: pin-actions rewrite done pinned=3") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	// A second run resolves nothing new and the cache answers without the API.
+	writeWorkflow(t, repoDir, unpinnedWorkflow)
+	calls := len(api.calls)
+	out.Reset()
+	if err := run(opts, &out); err != nil {
+		t.Fatalf("second run returned error: %v", err)
+	}
+	if len(api.calls) != calls {
+		t.Fatalf("cached refs should not hit the API: %v", api.calls[calls:])
+	}
+}
+
+func TestRunCheckReportsMovedTagsAndUnpinnedUses(t *testing.T) {
+	api := &fakeGitHub{tags: map[string]string{
+		"actions/checkout@v4": checkoutV5,
+		"actions/setup-go@v5": setupGoV5,
+	}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	repoDir := t.TempDir()
+	original := `jobs:
+  verify:
+    steps:
+      - uses: actions/checkout@` + checkoutV4 + ` # v4
+      - uses: actions/setup-go@` + setupGoV5 + ` # v5
+      - uses: actions/cache@v4
+      - uses: octo/tool@` + checkoutV4 + `
+`
+	path := writeWorkflow(t, repoDir, original)
+	cachePath := filepath.Join(repoDir, "cache", "pin-actions.json")
+	// A stale cache entry must not hide a moved tag in --check mode.
+	if err := saveCache(cachePath, map[string]cacheEntry{"actions/checkout@v4": {SHA: checkoutV4}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err := run(options{repoDir: repoDir, check: true, apiURL: server.URL, cache: cachePath}, &out)
+	if err == nil || err.Error() != "check_failed problems=2" {
+		t.Fatalf("expected two problems, got %v\n%s", err, out.String())
+	}
+	log := out.String()
+	for _, want := range []string{
+		"ERROR: pin-actions moved file=",
+		"tag=v4 current=" + checkoutV5,
+		"OK: pin-actions pinned file=",
+		"ERROR: pin-actions unpinned file=",
+		"uses=actions/cache@v4",
+		"reason=no_tag_comment",
+	} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("output missing %q:\n%s", want, log)
+		}
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != original {
+		t.Fatal("--check must not rewrite workflows")
+	}
+}
+
+func TestRunFailsOnUnresolvableTag(t *testing.T) {
+	server := httptest.NewServer(&fakeGitHub{tags: map[string]string{}})
+	defer server.Close()
+
+	repoDir := t.TempDir()
+	writeWorkflow(t, repoDir, "steps:\n  - uses: actions/checkout@v99\n")
+	var out bytes.Buffer
+	err := run(options{repoDir: repoDir, apiURL: server.URL, cache: filepath.Join(repoDir, "c.json")}, &out)
+	if err == nil || !strings.Contains(out.String(), "http_status=404") {
+		t.Fatalf("expected resolution failure, got %v\n%s", err, out.String())
+	}
+}
+
+func TestParseOptionsUsesEnvironment(t *testing.T) {
+	env := map[string]string{"GITHUB_API_URL": "https://ghe.example.com/api/v3/", "GH_TOKEN": "t0"}
+	opts, err := parseOptions([]string{"--repo-dir", "/tmp/x"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("parseOptions returned error: %v", err)
+	}
+	if opts.apiURL != "https://ghe.example.com/api/v3" || opts.token != "t0" || opts.cache != filepath.Join("/tmp/x", "cache", "pin-actions.json") {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// utilizationTarget is the ceiling from docs/ci/RESOURCE_MODEL.md.
+const utilizationTarget = 0.60
+
+type config struct {
+	historyPath string
+	days        int
+	concurrency int
+}
+
+// runRecord is the part of verify-full.status.json the report needs.
+type runRecord struct {
+	Timestamp  string       `json:"timestamp"`
+	Mode       string       `json:"mode"`
+	Status     string       `json:"status"`
+	CPUs       float64      `json:"cpus"`
+	DurationMS float64      `json:"pipeline_duration_ms"`
+	Steps      []stepRecord `json:"steps"`
+	Disk       []diskRecord `json:"disk"`
+	GoHits     float64      `json:"go_cache_hits"`
+	GoMisses   float64      `json:"go_cache_misses"`
+	GoCold     string       `json:"go_cache_cold_start"`
+	at         time.Time
+}
+
+type stepRecord struct {
+	Name       string  `json:"step"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	CPUSeconds float64 `json:"cpu_seconds"`
+	CPUAvg     float64 `json:"cpu_avg"`
+	CPUPeak    float64 `json:"cpu_peak"`
+	RSSPeakMB  float64 `json:"rss_peak_mb"`
+}
+
+type diskRecord struct {
+	Name       string  `json:"disk"`
+	DeltaBytes float64 `json:"delta_bytes"`
+}
+
+// report holds the RESOURCE_MODEL variables.
+type report struct {
+	runs       int
+	windowDays float64
+	j          float64
+	tMeanMin   float64
+	tP95Min    float64
+	kcpu       float64
+	kcpuRuns   int
+	cpuPeakMax float64
+	rssPeakMax float64
+	disk       map[string][]float64
+	steps      map[string]*stepSummary
+	goRuns     int
+	goHits     float64
+	goMisses   float64
+	goCold     int
+}
+
+type stepSummary struct {
+	runs       int
+	durationMS float64
+	cpuAvgSum  float64
+	cpuPeakMax float64
+	rssPeakMax float64
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.historyPath, "history", "out/metrics/verify-full.jsonl", "verify-full run history (one status JSON per line)")
+	flag.IntVar(&cfg.days, "days", 14, "only use runs from the last N days (0: all)")
+	flag.IntVar(&cfg.concurrency, "concurrency", 1, "concurrent verify-full runs (C)")
+	flag.Parse()
+
+	if err := run(cfg, time.Now().UTC(), os.Stdout); err != nil {
+		fmt.Printf("ERROR: resource_report %s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+	}
+}
+
+func run(cfg config, now time.Time, w io.Writer) error {
+	if cfg.days < 0 || cfg.concurrency < 1 {
+		return errors.New("--days must be >= 0 and --concurrency >= 1")
+	}
+	f, err := os.Open(cfg.historyPath)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+	records, skipped, err := readHistory(f, cfg.days, now)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "OK: resource_report history=%s runs=%d skipped=%d\n", cfg.historyPath, len(records), skipped)
+	if len(records) == 0 {
+		fmt.Fprintln(w, "SKIP: resource_report reason=no_full_runs")
+		fmt.Fprintln(w, "STATUS: SKIP")
+		return nil
+	}
+
+	r := aggregate(records, cfg.days, now)
+	utilization := r.j * r.tMeanMin / (24 * 60 * float64(cfg.concurrency))
+	fmt.Fprintf(w, "OK: resource_report window_days=%.1f J=%.2f\n", r.windowDays, r.j)
+	fmt.Fprintf(w, "OK: resource_report T_mean_min=%.1f T_p95_min=%.1f\n", r.tMeanMin, r.tP95Min)
+	if r.kcpuRuns > 0 {
+		fmt.Fprintf(w, "OK: resource_report Kcpu=%.2f runs=%d cpu_peak_max=%.2f rss_peak_max_mb=%.1f\n", r.kcpu, r.kcpuRuns, r.cpuPeakMax, r.rssPeakMax)
+	} else {
+		fmt.Fprintln(w, "SKIP: resource_report Kcpu reason=no_usage_samples")
+	}
+	fmt.Fprintf(w, "OK: resource_report C=%d utilization=%.2f target=%.2f\n", cfg.concurrency, utilization, utilizationTarget)
+	if utilization > utilizationTarget {
+		fmt.Fprintf(w, "WARN: resource_report utilization=%.2f exceeds target=%.2f\n", utilization, utilizationTarget)
+	}
+	for _, name := range sortedKeys(r.disk) {
+		deltas := r.disk[name]
+		total := 0.0
+		for _, d := range deltas {
+			total += d
+		}
+		fmt.Fprintf(w, "OK: resource_report disk=%s delta_mean_mb=%.1f delta_total_mb=%.1f\n", name, total/float64(len(deltas))/(1<<20), total/(1<<20))
+	}
+	if r.goRuns > 0 {
+		fmt.Fprintf(w, "OK: resource_report go_cache runs=%d hit_rate=%.2f cold_starts=%d\n", r.goRuns, r.goHits/math.Max(1, r.goHits+r.goMisses), r.goCold)
+	}
+	for _, name := range sortedKeys(r.steps) {
+		s := r.steps[name]
+		fmt.Fprintf(w, "OK: resource_report step=%s runs=%d T_mean_min=%.1f cpu_avg_mean=%.2f cpu_peak_max=%.2f rss_peak_max_mb=%.1f\n",
+			name, s.runs, s.durationMS/float64(s.runs)/60000, s.cpuAvgSum/float64(s.runs), s.cpuPeakMax, s.rssPeakMax)
+	}
+	fmt.Fprintln(w, "STATUS: OK")
+	return nil
+}
+
+// readHistory keeps full-mode runs that executed a pipeline inside the
+// window. Dry-runs and lines that do not parse are counted as skipped.
+func readHistory(r io.Reader, days int, now time.Time) ([]runRecord, int, error) {
+	var records []runRecord
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			skipped++
+			continue
+		}
+		at, err := time.Parse("20060102T150405Z", rec.Timestamp)
+		if err != nil || rec.Mode != "full" || rec.DurationMS <= 0 {
+			skipped++
+			continue
+		}
+		if days > 0 && at.Before(now.Add(-time.Duration(days)*24*time.Hour)) {
+			continue
+		}
+		rec.at = at
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("read history: %w", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].at.Before(records[j].at) })
+	return records, skipped, nil
+}
+
+func aggregate(records []runRecord, days int, now time.Time) report {
+	r := report{runs: len(records), disk: map[string][]float64{}, steps: map[string]*stepSummary{}}
+
+	// A history shorter than the window would understate J, so the window
+	// starts at the first run (at least one day).
+	r.windowDays = math.Max(1, now.Sub(records[0].at).Hours()/24)
+	if days > 0 && r.windowDays > float64(days) {
+		r.windowDays = float64(days)
+	}
+	r.j = float64(len(records)) / r.windowDays
+
+	durations := make([]float64, 0, len(records))
+	kcpuSum := 0.0
+	for _, rec := range records {
+		durations = append(durations, rec.DurationMS/60000)
+		cpuSeconds := 0.0
+		sampled := false
+		for _, step := range rec.Steps {
+			if step.CPUSeconds > 0 || step.RSSPeakMB > 0 {
+				sampled = true
+			}
+			cpuSeconds += step.CPUSeconds
+			r.cpuPeakMax = math.Max(r.cpuPeakMax, step.CPUPeak)
+			r.rssPeakMax = math.Max(r.rssPeakMax, step.RSSPeakMB)
+			if step.Status == "SKIP" {
+				continue
+			}
+			s := r.steps[step.Name]
+			if s == nil {
+				s = &stepSummary{}
+				r.steps[step.Name] = s
+			}
+			s.runs++
+			s.durationMS += step.DurationMS
+			s.cpuAvgSum += step.CPUAvg
+			s.cpuPeakMax = math.Max(s.cpuPeakMax, step.CPUPeak)
+			s.rssPeakMax = math.Max(s.rssPeakMax, step.RSSPeakMB)
+		}
+		// Kcpu: share of the VM's CPUs the run kept busy.
+		if sampled && rec.CPUs > 0 {
+			kcpuSum += math.Min(1, cpuSeconds/(rec.DurationMS/1000*rec.CPUs))
+			r.kcpuRuns++
+		}
+		for _, d := range rec.Disk {
+			r.disk[d.Name] = append(r.disk[d.Name], d.DeltaBytes)
+		}
+		if rec.GoCold != "" {
+			r.goRuns++
+			r.goHits += rec.GoHits
+			r.goMisses += rec.GoMisses
+			if rec.GoCold == "true" {
+				r.goCold++
+			}
+		}
+	}
+	if r.kcpuRuns > 0 {
+		r.kcpu = kcpuSum / float64(r.kcpuRuns)
+	}
+
+	sort.Float64s(durations)
+	total := 0.0
+	for _, d := range durations {
+		total += d
+	}
+	r.tMeanMin = total / float64(len(durations))
+	// Nearest-rank p95.
+	r.tP95Min = durations[int(math.Ceil(0.95*float64(len(durations))))-1]
+	return r
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReportsResourceModelVariables(t *testing.T) {
+	history := strings.Join([]string{
+		// 10 and 30 minute runs on a 4 CPU VM; 2 CPU-min and 24 CPU-min of work.
+		`{"timestamp":"20260308T000000Z","mode":"full","status":"OK","cpus":4,"pipeline_duration_ms":600000,` +
+			`"steps":[{"step":"test","status":"OK","duration_ms":600000,"cpu_seconds":120,"cpu_avg":0.2,"cpu_peak":1.5,"rss_peak_mb":300}],` +
+			`"disk":[{"disk":"cache","delta_bytes":104857600}],"go_cache_hits":10,"go_cache_misses":90,"go_cache_cold_start":"true"}`,
+		`{"timestamp":"20260309T000000Z","mode":"full","status":"ERROR","cpus":4,"pipeline_duration_ms":1800000,` +
+			`"steps":[{"step":"test","status":"ERROR","duration_ms":1800000,"cpu_seconds":1440,"cpu_avg":0.8,"cpu_peak":3.5,"rss_peak_mb":900}],` +
+			`"disk":[{"disk":"cache","delta_bytes":0}],"go_cache_hits":90,"go_cache_misses":10,"go_cache_cold_start":"false"}`,
+		`{"timestamp":"20260309T120000Z","mode":"dry-run","status":"OK"}`,
+		`{"timestamp":"20250101T000000Z","mode":"full","status":"OK","cpus":4,"pipeline_duration_ms":60000}`,
+		`not json`,
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "verify-full.jsonl")
+	if err := os.WriteFile(path, []byte(history), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	now := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	if err := run(config{historyPath: path, days: 14, concurrency: 1}, now, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"runs=2 skipped=2",
+		"window_days=2.0 J=1.00",
+		"T_mean_min=20.0 T_p95_min=30.0",
+		// (120/(600*4) + 1440/(1800*4)) / 2
+		"Kcpu=0.12 runs=2 cpu_peak_max=3.50 rss_peak_max_mb=900.0",
+		"C=1 utilization=0.01",
+		"disk=cache delta_mean_mb=50.0 delta_total_mb=100.0",
+		"go_cache runs=2 hit_rate=0.50 cold_starts=1",
+		"step=test runs=2 T_mean_min=20.0 cpu_avg_mean=0.50",
+		"STATUS: OK",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunWithoutFullRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verify-full.jsonl")
+	if err := os.WriteFile(path, []byte(`{"timestamp":"20260309T000000Z","mode":"dry-run"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := run(config{historyPath: path, concurrency: 1}, time.Now(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "STATUS: SKIP") {
+		t.Fatalf("output:\n%s", buf.String())
+	}
+}
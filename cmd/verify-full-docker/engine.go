@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// engineAPIVersion is the oldest Engine API with every field used here
+// (Docker 20.10; colima ships newer).
+const engineAPIVersion = "v1.41"
+
+// engineClient talks to the Docker Engine API over its Unix socket.
+type engineClient struct {
+	http *http.Client
+	base string
+}
+
+func newEngineClient(socket string) *engineClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &engineClient{http: &http.Client{Transport: transport}, base: "http://docker/" + engineAPIVersion}
+}
+
+// findEngineSocket honours DOCKER_HOST (unix:// only), then the default
+// socket, then colima's.
+func findEngineSocket(getenv func(string) string) (string, error) {
+	if host := strings.TrimSpace(getenv("DOCKER_HOST")); host != "" {
+		path, ok := strings.CutPrefix(host, "unix://")
+		if !ok {
+			return "", fmt.Errorf("DOCKER_HOST must be a unix:// socket: %s", host)
+		}
+		return path, nil
+	}
+	candidates := []string{"/var/run/docker.sock"}
+	if home := getenv("HOME"); home != "" {
+		candidates = append(candidates,
+			filepath.Join(home, ".colima", "default", "docker.sock"),
+			filepath.Join(home, ".colima", "docker.sock"))
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no docker socket found (tried %s)", strings.Join(candidates, ", "))
+}
+
+// engineError is a non-2xx answer from the engine.
+type engineError struct {
+	method  string
+	path    string
+	status  int
+	message string
+}
+
+func (e *engineError) Error() string {
+	return fmt.Sprintf("engine %s %s: %d %s", e.method, e.path, e.status, e.message)
+}
+
+// do sends a request and returns the response when its status is 2xx or
+// listed in allowed. The caller closes the body.
+func (c *engineClient) do(ctx context.Context, method, path string, body any, allowed ...int) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	for _, status := range allowed {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	var msg struct {
+		Message string `json:"message"`
+	}
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(content, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(content))
+	}
+	return nil, &engineError{method: method, path: path, status: resp.StatusCode, message: msg.Message}
+}
+
+// call is do for requests whose answer is decoded into out (or dropped).
+func (c *engineClient) call(ctx context.Context, method, path string, body, out any, allowed ...int) error {
+	resp, err := c.do(ctx, method, path, body, allowed...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *engineClient) ping(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/_ping", nil, nil)
+}
+
+// containerSpec is the body of POST /containers/create.
+type containerSpec struct {
+	Image      string     `json:"Image"`
+	Cmd        []string   `json:"Cmd"`
+	Env        []string   `json:"Env"`
+	User       string     `json:"User"`
+	WorkingDir string     `json:"WorkingDir"`
+	Tty        bool       `json:"Tty"`
+	HostConfig hostConfig `json:"HostConfig"`
+}
+
+type hostConfig struct {
+	Binds          []string          `json:"Binds"`
+	NetworkMode    string            `json:"NetworkMode,omitempty"`
+	ReadonlyRootfs bool              `json:"ReadonlyRootfs,omitempty"`
+	CapDrop        []string          `json:"CapDrop,omitempty"`
+	SecurityOpt    []string          `json:"SecurityOpt,omitempty"`
+	Tmpfs          map[string]string `json:"Tmpfs,omitempty"`
+}
+
+func (c *engineClient) create(ctx context.Context, spec containerSpec) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/containers/create", spec, &created); err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return "", errors.New("engine create: empty container id")
+	}
+	return created.ID, nil
+}
+
+func (c *engineClient) start(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, http.StatusNotModified)
+}
+
+// streamLogs follows stdout and stderr with engine timestamps until the
+// container exits. Without a TTY the stream is multiplexed: an 8-byte
+// header (stream, 0, 0, 0, big-endian size) before every frame.
+func (c *engineClient) streamLogs(ctx context.Context, id string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+id+"/logs?follow=1&stdout=1&stderr=1&timestamps=1", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(resp.Body, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, resp.Body, size); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until the container is not running and returns its exit code.
+func (c *engineClient) wait(ctx context.Context, id string) (int, error) {
+	var waited struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/containers/"+id+"/wait?condition=not-running", nil, &waited); err != nil {
+		return -1, err
+	}
+	if waited.Error != nil && waited.Error.Message != "" {
+		return waited.StatusCode, errors.New("engine wait: " + waited.Error.Message)
+	}
+	return waited.StatusCode, nil
+}
+
+// stop sends SIGTERM and lets the engine SIGKILL after grace.
+func (c *engineClient) stop(ctx context.Context, id string, grace time.Duration) error {
+	path := fmt.Sprintf("/containers/%s/stop?t=%d", id, int(grace.Seconds()))
+	return c.call(ctx, http.MethodPost, path, nil, nil, http.StatusNotModified)
+}
+
+func (c *engineClient) kill(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodPost, "/containers/"+id+"/kill", nil, nil, http.StatusConflict)
+}
+
+type containerState struct {
+	Status    string `json:"Status"`
+	ExitCode  int    `json:"ExitCode"`
+	OOMKilled bool   `json:"OOMKilled"`
+}
+
+func (c *engineClient) inspect(ctx context.Context, id string) (containerState, error) {
+	var inspected struct {
+		State containerState `json:"State"`
+	}
+	err := c.call(ctx, http.MethodGet, "/containers/"+id+"/json", nil, &inspected)
+	return inspected.State, err
+}
+
+func (c *engineClient) remove(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, "/containers/"+id+"?force=1&"+url.Values{"v": {"0"}}.Encode(), nil, nil, http.StatusNotFound)
+}
+
+// containerResult is what the engine reports once the container is gone.
+type containerResult struct {
+	exitCode  int
+	oomKilled bool
+	timedOut  bool
+}
+
+func (r containerResult) String() string {
+	return fmt.Sprintf("exit_code=%d oom_killed=%t timed_out=%t", r.exitCode, r.oomKilled, r.timedOut)
+}
+
+// runContainer creates, starts and follows one container. After timeout it
+// is stopped with grace and killed if stopping fails. The container is
+// always removed.
+func runContainer(ctx context.Context, c *engineClient, spec containerSpec, timeout, grace time.Duration, logs io.Writer) (containerResult, error) {
+	result := containerResult{exitCode: -1}
+	id, err := c.create(ctx, spec)
+	if err != nil {
+		return result, err
+	}
+	// Cleanup runs even when ctx is already done.
+	cleanup := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), grace+30*time.Second)
+	}
+	defer func() {
+		cctx, cancel := cleanup()
+		defer cancel()
+		_ = c.remove(cctx, id)
+	}()
+	if err := c.start(ctx, id); err != nil {
+		return result, err
+	}
+
+	logsDone := make(chan error, 1)
+	go func() { logsDone <- c.streamLogs(ctx, id, logs) }()
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	code, waitErr := c.wait(waitCtx, id)
+	cancel()
+	if waitErr != nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		result.timedOut = true
+		cctx, cancel := cleanup()
+		if err := c.stop(cctx, id, grace); err != nil {
+			_ = c.kill(cctx, id)
+		}
+		code, waitErr = c.wait(cctx, id)
+		cancel()
+	}
+	if waitErr != nil {
+		return result, waitErr
+	}
+	result.exitCode = code
+
+	cctx, cancel := cleanup()
+	defer cancel()
+	if state, err := c.inspect(cctx, id); err == nil {
+		result.oomKilled = state.OOMKilled
+	}
+	select {
+	case <-logsDone:
+	case <-time.After(5 * time.Second):
+	}
+	return result, nil
+}
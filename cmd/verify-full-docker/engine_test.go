@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEngine serves the Engine API endpoints runContainer uses. onStart
+// plays the container: it gets the create spec and returns the exit code.
+type fakeEngine struct {
+	mu       sync.Mutex
+	calls    []string
+	specs    []containerSpec
+	exited   map[string]chan struct{}
+	codes    map[string]int
+	oom      bool
+	stopFail bool
+	hang     bool
+	onStart  func(spec containerSpec) int
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+engineAPIVersion)
+	f.mu.Lock()
+	f.calls = append(f.calls, r.Method+" "+path)
+	f.mu.Unlock()
+	if path == "/_ping" {
+		_, _ = w.Write([]byte("OK"))
+		return
+	}
+	if path == "/containers/create" {
+		var spec containerSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, `{"message":"bad body"}`, http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		id := fmt.Sprintf("c%d", len(f.specs))
+		f.specs = append(f.specs, spec)
+		f.exited[id] = make(chan struct{})
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"Id":%q}`, id)
+		return
+	}
+	rest, ok := strings.CutPrefix(path, "/containers/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, action, _ := strings.Cut(rest, "/")
+	f.mu.Lock()
+	exited := f.exited[id]
+	f.mu.Unlock()
+	switch action {
+	case "start":
+		var index int
+		fmt.Sscanf(id, "c%d", &index)
+		f.mu.Lock()
+		spec := f.specs[index]
+		f.mu.Unlock()
+		code := 0
+		if f.onStart != nil {
+			code = f.onStart(spec)
+		}
+		f.mu.Lock()
+		f.codes[id] = code
+		f.mu.Unlock()
+		if !f.hang {
+			close(exited)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "logs":
+		for _, frame := range []struct {
+			stream byte
+			text   string
+		}{{1, "2026-10-18T00:00:00.000000000Z OK: verify-full started\n"}, {2, "2026-10-18T00:00:01.000000000Z WARN: from stderr\n"}} {
+			header := []byte{frame.stream, 0, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(header[4:], uint32(len(frame.text)))
+			_, _ = w.Write(append(header, frame.text...))
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-exited:
+		case <-r.Context().Done():
+		}
+	case "wait":
+		select {
+		case <-exited:
+		case <-r.Context().Done():
+			return
+		}
+		f.mu.Lock()
+		code := f.codes[id]
+		f.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"StatusCode":%d}`, code)
+	case "stop":
+		if f.stopFail {
+			http.Error(w, `{"message":"stop failed"}`, http.StatusInternalServerError)
+			return
+		}
+		f.exit(id, 143)
+		w.WriteHeader(http.StatusNoContent)
+	case "kill":
+		f.exit(id, 137)
+		w.WriteHeader(http.StatusNoContent)
+	case "json":
+		f.mu.Lock()
+		code := f.codes[id]
+		f.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"State":{"Status":"exited","ExitCode":%d,"OOMKilled":%t}}`, code, f.oom)
+	case "":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeEngine) exit(id string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.codes[id] = code
+	select {
+	case <-f.exited[id]:
+	default:
+		close(f.exited[id])
+	}
+}
+
+func (f *fakeEngine) callLog() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, "\n")
+}
+
+// startFakeEngine listens on a Unix socket; socket paths are short-limited,
+// so it does not live under t.TempDir.
+func startFakeEngine(t *testing.T, f *fakeEngine) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.exited = map[string]chan struct{}{}
+	f.codes = map[string]int{}
+	server := httptest.NewUnstartedServer(f)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+func TestRunContainerStreamsLogsAndCollectsExit(t *testing.T) {
+	f := &fakeEngine{oom: true, onStart: func(containerSpec) int { return 3 }}
+	client := newEngineClient(startFakeEngine(t, f))
+
+	var logs bytes.Buffer
+	spec := containerSpec{Image: "ci-self-runner:local", Cmd: []string{"/usr/local/bin/verify-full"}}
+	result, err := runContainer(context.Background(), client, spec, time.Minute, time.Second, &logs)
+	if err != nil {
+		t.Fatalf("runContainer: %v", err)
+	}
+	if result.exitCode != 3 || !result.oomKilled || result.timedOut {
+		t.Fatalf("result=%s", result)
+	}
+	for _, want := range []string{"2026-10-18T00:00:00.000000000Z OK: verify-full started\n", "WARN: from stderr"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("logs missing %q:\n%q", want, logs.String())
+		}
+	}
+	if calls := f.callLog(); !strings.Contains(calls, "DELETE /containers/c0") {
+		t.Fatalf("container not removed:\n%s", calls)
+	}
+}
+
+func TestRunContainerTimeoutStopsThenKills(t *testing.T) {
+	f := &fakeEngine{hang: true, stopFail: true}
+	client := newEngineClient(startFakeEngine(t, f))
+
+	result, err := runContainer(context.Background(), client, containerSpec{Image: "img"}, 200*time.Millisecond, time.Second, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("runContainer: %v", err)
+	}
+	if !result.timedOut || result.exitCode != 137 {
+		t.Fatalf("result=%s", result)
+	}
+	calls := f.callLog()
+	stop, kill := strings.Index(calls, "POST /containers/c0/stop"), strings.Index(calls, "POST /containers/c0/kill")
+	if stop < 0 || kill < stop {
+		t.Fatalf("expected stop then kill:\n%s", calls)
+	}
+}
+
+func TestFindEngineSocket(t *testing.T) {
+	if got, err := findEngineSocket(func(key string) string {
+		return map[string]string{"DOCKER_HOST": "unix:///run/user/1/docker.sock"}[key]
+	}); err != nil || got != "/run/user/1/docker.sock" {
+		t.Fatalf("got=%s err=%v", got, err)
+	}
+	if _, err := findEngineSocket(func(key string) string {
+		return map[string]string{"DOCKER_HOST": "tcp://127.0.0.1:2375"}[key]
+	}); err == nil {
+		t.Fatal("expected tcp DOCKER_HOST to be rejected")
+	}
+}
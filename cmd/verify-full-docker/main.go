@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeoutSec = 3600
+	defaultGraceSec   = 10
+	fetchDir          = "fetch"
+	fetchStatusFile   = "verify-full-fetch.status"
+	shardTimingsFile  = "metrics/verify-full-shard-timings.json"
+)
+
+// options mirror the environment contract of ops/ci/run_verify_full.sh.
+type options struct {
+	image       string
+	repoDir     string
+	outDir      string
+	cacheVol    string
+	dryRun      bool
+	ghaSync     bool
+	user        string
+	shards      int
+	isolation   string
+	tmpfsSize   string
+	socket      string
+	timeout     time.Duration
+	grace       time.Duration
+	passEnv     []string
+	ghaOutput   string
+	ghaSummary  string
+	githubRunID string
+	githubSHA   string
+	githubRef   string
+}
+
+// statusError carries the reason= written to out/verify-full.status.
+type statusError struct {
+	reason string
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.reason + ": " + e.err.Error()
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Printf("ERROR: verify-full-docker invalid_args=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(2)
+	}
+
+	status, err := run(context.Background(), opts, os.Stdout)
+	if err != nil {
+		var se *statusError
+		reason := "docker_run_failed"
+		if errors.As(err, &se) {
+			reason = se.reason
+		}
+		if readStatus(filepath.Join(opts.outDir, "verify-full.status")) == "" {
+			writeErrorStatus(opts, opts.outDir, reason, nil)
+		}
+		fmt.Printf("ERROR: verify-full-docker %s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(1)
+	}
+	fmt.Printf("STATUS: %s\n", status)
+	if status == "ERROR" {
+		os.Exit(1)
+	}
+}
+
+func parseOptions(args []string, getenv func(string) string) (options, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return options{}, err
+	}
+	fs := flag.NewFlagSet("verify-full-docker", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dryRun := fs.Bool("dry-run", parseBool(getenv("VERIFY_DRY_RUN")), "write the dry-run status without docker")
+	socket := fs.String("socket", "", "docker engine socket (default: DOCKER_HOST, /var/run/docker.sock, colima)")
+	timeoutSec := fs.Int("timeout-sec", envInt(getenv, "VERIFY_FULL_TIMEOUT_SEC", defaultTimeoutSec), "stop a container running longer than N seconds")
+	graceSec := fs.Int("stop-grace-sec", envInt(getenv, "VERIFY_FULL_STOP_GRACE_SEC", defaultGraceSec), "seconds between SIGTERM and SIGKILL on timeout")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected_args=%s", strings.Join(fs.Args(), ","))
+	}
+	if *timeoutSec <= 0 || *graceSec < 0 {
+		return options{}, errors.New("timeout must be positive and grace must not be negative")
+	}
+
+	opts := options{
+		image:       envDefault(getenv, "IMAGE", "ci-self-runner:local"),
+		repoDir:     envDefault(getenv, "REPO_DIR", cwd),
+		outDir:      envDefault(getenv, "OUT_DIR", filepath.Join(cwd, "out")),
+		cacheVol:    envDefault(getenv, "CACHE_VOL", "ci-cache"),
+		dryRun:      *dryRun,
+		ghaSync:     parseBool(getenv("VERIFY_GHA_SYNC")) || getenv("GITHUB_ACTIONS") == "true",
+		user:        envDefault(getenv, "HOST_UID", strconv.Itoa(os.Getuid())) + ":" + envDefault(getenv, "HOST_GID", strconv.Itoa(os.Getgid())),
+		isolation:   envDefault(getenv, "VERIFY_ISOLATION", "standard"),
+		tmpfsSize:   envDefault(getenv, "VERIFY_TMPFS_SIZE", "2g"),
+		socket:      *socket,
+		timeout:     time.Duration(*timeoutSec) * time.Second,
+		grace:       time.Duration(*graceSec) * time.Second,
+		ghaOutput:   getenv("GITHUB_OUTPUT"),
+		ghaSummary:  getenv("GITHUB_STEP_SUMMARY"),
+		githubRunID: getenv("GITHUB_RUN_ID"),
+		githubSHA:   getenv("GITHUB_SHA"),
+		githubRef:   getenv("GITHUB_REF_NAME"),
+	}
+	for _, key := range []string{"VERIFY_DRY_RUN", "VERIFY_GHA_SYNC", "GITHUB_ACTIONS", "GITHUB_RUN_ID", "GITHUB_SHA", "GITHUB_REF_NAME", "GITHUB_SERVER_URL", "GITHUB_REPOSITORY"} {
+		value := getenv(key)
+		switch {
+		case key == "VERIFY_DRY_RUN" && value == "":
+			value = "0"
+		case key == "VERIFY_GHA_SYNC" && value == "":
+			value = "0"
+		case key == "GITHUB_ACTIONS" && value == "":
+			value = "false"
+		}
+		opts.passEnv = append(opts.passEnv, key+"="+value)
+	}
+	// Bind mounts need absolute host paths.
+	if opts.repoDir, err = filepath.Abs(opts.repoDir); err != nil {
+		return options{}, err
+	}
+	if opts.outDir, err = filepath.Abs(opts.outDir); err != nil {
+		return options{}, err
+	}
+	shards := envDefault(getenv, "VERIFY_SHARDS", "1")
+	if opts.shards, err = strconv.Atoi(shards); err != nil || opts.shards < 1 {
+		opts.shards = 0
+	}
+	return opts, nil
+}
+
+// run returns the status= of out/verify-full.status once the containers
+// are done. Errors before or around the containers are *statusError.
+func run(ctx context.Context, opts options, stdout io.Writer) (string, error) {
+	statusPath := filepath.Join(opts.outDir, "verify-full.status")
+	if err := os.MkdirAll(opts.outDir, 0o755); err != nil {
+		return "", err
+	}
+	_ = os.Remove(statusPath)
+
+	if opts.dryRun {
+		return "OK", writeDryRun(opts, stdout)
+	}
+	switch {
+	case opts.isolation != "standard" && opts.isolation != "hermetic":
+		return "", &statusError{"invalid_verify_isolation", fmt.Errorf("VERIFY_ISOLATION must be standard or hermetic: %s", opts.isolation)}
+	case opts.shards < 1:
+		return "", &statusError{"invalid_verify_shards", errors.New("VERIFY_SHARDS must be a positive integer")}
+	}
+
+	client, err := connectEngine(ctx, opts, stdout)
+	if err != nil {
+		return "", &statusError{"docker_daemon_unavailable", err}
+	}
+
+	if opts.isolation == "hermetic" {
+		fetchPath := filepath.Join(opts.outDir, fetchDir, fetchStatusFile)
+		_ = os.RemoveAll(filepath.Join(opts.outDir, fetchDir))
+		spec := opts.spec(opts.outDir, "", false, "standard", "--fetch")
+		result, err := runContainer(ctx, client, spec, opts.timeout, opts.grace, stdout)
+		if err != nil {
+			return "", &statusError{"docker_run_failed", err}
+		}
+		fmt.Fprintf(stdout, "OK: container=fetch %s\n", result)
+		if readStatus(fetchPath) != "OK" {
+			return "", &statusError{"fetch_failed", fmt.Errorf("fetch phase failed status=%s", fetchPath)}
+		}
+		fmt.Fprintln(stdout, "OK: fetch phase completed")
+	}
+
+	var mergeArgs []string
+	if opts.shards > 1 {
+		runShards(ctx, client, opts, stdout)
+		mergeArgs = []string{"--merge-shards", strconv.Itoa(opts.shards)}
+	}
+	spec := opts.spec(opts.outDir, "", true, opts.isolation, mergeArgs...)
+	result, err := runContainer(ctx, client, spec, opts.timeout, opts.grace, stdout)
+	if err != nil {
+		return "", &statusError{"docker_run_failed", err}
+	}
+	fmt.Fprintf(stdout, "OK: container=verify-full %s\n", result)
+	status := readStatus(statusPath)
+	if status == "" {
+		return "", &statusError{failureReason(result), fmt.Errorf("status file missing %s", result)}
+	}
+	return status, nil
+}
+
+// runShards runs one container per shard in parallel. A shard without a
+// status gets an ERROR status so the merge reports why.
+func runShards(ctx context.Context, client *engineClient, opts options, stdout io.Writer) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := 0; i < opts.shards; i++ {
+		shardOut := filepath.Join(opts.outDir, "shards", strconv.Itoa(i))
+		shard := fmt.Sprintf("%d/%d", i, opts.shards)
+		_ = os.MkdirAll(shardOut, 0o755)
+		_ = os.Remove(filepath.Join(shardOut, "verify-full.status"))
+		_ = os.Remove(filepath.Join(shardOut, shardTimingsFile))
+		fmt.Fprintf(stdout, "OK: shard=%s started console=%s\n", shard, filepath.Join(shardOut, "console.log"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			line := fmt.Sprintf("OK: shard=%s", shard)
+			console, err := os.Create(filepath.Join(shardOut, "console.log"))
+			if err != nil {
+				line = fmt.Sprintf("ERROR: shard=%s console err=%s", shard, err.Error())
+				writeErrorStatus(opts, shardOut, "console_create_failed", nil)
+			} else {
+				defer console.Close()
+				result, err := runContainer(ctx, client, opts.spec(shardOut, shard, false, opts.isolation), opts.timeout, opts.grace, console)
+				switch {
+				case err != nil:
+					line = fmt.Sprintf("ERROR: shard=%s err=%s", shard, err.Error())
+					writeErrorStatus(opts, shardOut, "docker_run_failed", nil)
+				case readStatus(filepath.Join(shardOut, "verify-full.status")) == "":
+					line += " " + result.String()
+					writeErrorStatus(opts, shardOut, failureReason(result), []string{"container " + result.String()})
+				default:
+					line += " " + result.String()
+				}
+			}
+			mu.Lock()
+			fmt.Fprintln(stdout, line)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// spec is the container run_verify_full.sh would start with the same
+// arguments: repo, out and cache mounts, the host user, GitHub files for
+// the summary run, shard timings and the fetch result for shards and the
+// hermetic host config.
+func (o options) spec(outDir, shard string, gha bool, isolation string, args ...string) containerSpec {
+	spec := containerSpec{
+		Image:      o.image,
+		Cmd:        append([]string{"/usr/local/bin/verify-full"}, args...),
+		Env:        append(append([]string{}, o.passEnv...), "VERIFY_ISOLATION="+isolation),
+		User:       o.user,
+		WorkingDir: "/repo",
+		HostConfig: hostConfig{Binds: []string{o.repoDir + ":/repo", outDir + ":/out", o.cacheVol + ":/cache"}},
+	}
+	if gha {
+		for _, f := range []struct{ host, target, env string }{
+			{o.ghaOutput, "/gha/output", "GITHUB_OUTPUT"},
+			{o.ghaSummary, "/gha/step_summary", "GITHUB_STEP_SUMMARY"},
+		} {
+			if info, err := os.Stat(f.host); f.host != "" && err == nil && info.Mode().IsRegular() {
+				spec.HostConfig.Binds = append(spec.HostConfig.Binds, f.host+":"+f.target)
+				spec.Env = append(spec.Env, f.env+"="+f.target)
+			}
+		}
+	}
+	if shard != "" {
+		spec.Env = append(spec.Env, "VERIFY_FULL_SHARD="+shard)
+		timings := filepath.Join(o.outDir, shardTimingsFile)
+		if _, err := os.Stat(timings); err == nil {
+			spec.HostConfig.Binds = append(spec.HostConfig.Binds, timings+":/shard-timings.json:ro")
+			spec.Env = append(spec.Env, "VERIFY_FULL_SHARD_TIMINGS=/shard-timings.json")
+		}
+		// A shard's /out is out/shards/<i>; the fetch result is mounted read-only.
+		if info, err := os.Stat(filepath.Join(o.outDir, fetchDir)); err == nil && info.IsDir() {
+			spec.HostConfig.Binds = append(spec.HostConfig.Binds, filepath.Join(o.outDir, fetchDir)+":/fetch:ro")
+			spec.Env = append(spec.Env, "VERIFY_FULL_FETCH_DIR=/fetch")
+		}
+	}
+	if isolation == "hermetic" {
+		spec.HostConfig.NetworkMode = "none"
+		spec.HostConfig.ReadonlyRootfs = true
+		spec.HostConfig.CapDrop = []string{"ALL"}
+		spec.HostConfig.SecurityOpt = []string{"no-new-privileges"}
+		spec.HostConfig.Tmpfs = map[string]string{"/tmp": "rw,exec,nosuid,nodev,size=" + o.tmpfsSize}
+		spec.Env = append(spec.Env, "HOME=/tmp", "GOPROXY=off")
+	}
+	return spec
+}
+
+// connectEngine pings the engine and, like run_verify_full.sh, tries
+// colima start once when it does not answer.
+func connectEngine(ctx context.Context, opts options, stdout io.Writer) (*engineClient, error) {
+	ping := func() (*engineClient, error) {
+		socket := opts.socket
+		if socket == "" {
+			var err error
+			if socket, err = findEngineSocket(os.Getenv); err != nil {
+				return nil, err
+			}
+		}
+		client := newEngineClient(socket)
+		pctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := client.ping(pctx); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(stdout, "OK: docker engine socket=%s api=%s\n", socket, engineAPIVersion)
+		return client, nil
+	}
+	client, err := ping()
+	if err == nil {
+		return client, nil
+	}
+	if _, lookErr := exec.LookPath("colima"); lookErr != nil {
+		return nil, err
+	}
+	fmt.Fprintf(stdout, "WARN: docker engine unavailable err=%s; attempting colima start\n", err.Error())
+	if exec.CommandContext(ctx, "colima", "status").Run() != nil {
+		start := exec.CommandContext(ctx, "colima", "start")
+		start.Stdout, start.Stderr = stdout, stdout
+		if startErr := start.Run(); startErr != nil {
+			return nil, fmt.Errorf("colima start: %w", startErr)
+		}
+	}
+	return ping()
+}
+
+func failureReason(result containerResult) string {
+	switch {
+	case result.timedOut:
+		return "container_timeout"
+	case result.oomKilled:
+		return "container_oom_killed"
+	case result.exitCode != 0:
+		return "docker_run_failed"
+	default:
+		return "status_file_missing"
+	}
+}
+
+// readStatus returns the status= value of a status file, or "".
+func readStatus(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "status="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// statusHead is the shared head of the status files this command writes.
+func statusHead(opts options, status string) []string {
+	mode := "full"
+	if opts.dryRun {
+		mode = "dry-run"
+	}
+	lines := []string{
+		fmt.Sprintf("%s: verify-full status=%s mode=%s", status, status, mode),
+		"timestamp=" + time.Now().UTC().Format("20060102T150405Z"),
+		"status=" + status,
+		"mode=" + mode,
+		fmt.Sprintf("gha_sync=%t", opts.ghaSync),
+	}
+	for _, kv := range [][2]string{{"github_run_id", opts.githubRunID}, {"github_sha", opts.githubSHA}, {"github_ref", opts.githubRef}} {
+		if kv[1] != "" {
+			lines = append(lines, "OK: "+kv[0]+"="+kv[1], kv[0]+"="+kv[1])
+		}
+	}
+	return append(lines, "isolation="+opts.isolation, "source=verify_full_docker")
+}
+
+func writeErrorStatus(opts options, outDir, reason string, details []string) {
+	lines := append(statusHead(opts, "ERROR"), details...)
+	lines = append(lines, "ERROR: reason="+reason, "reason="+reason)
+	_ = os.MkdirAll(outDir, 0o755)
+	_ = os.WriteFile(filepath.Join(outDir, "verify-full.status"), []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+// writeDryRun keeps the dry-run contract of run_verify_full.sh: a status
+// and a log without touching docker.
+func writeDryRun(opts options, stdout io.Writer) error {
+	head := statusHead(opts, "OK")
+	stamp := strings.TrimPrefix(head[1], "timestamp=")
+	if err := os.WriteFile(filepath.Join(opts.outDir, "verify-full.status"), []byte(strings.Join(head, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	logDir := filepath.Join(opts.outDir, "logs", "verify-full", stamp)
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return err
+	}
+	var lines []string
+	if opts.ghaSync {
+		lines = append(lines, "::notice::verify-full dry-run start")
+	}
+	lines = append(lines,
+		"OK: verify-full started stamp="+stamp,
+		"OK: mode=dry-run",
+		fmt.Sprintf("OK: gha_sync=%t", opts.ghaSync),
+		"SKIP: docker reason=dry_run",
+		"OK: verify-full completed")
+	if opts.ghaSync {
+		lines = append(lines, "::notice::verify-full dry-run done")
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	fmt.Fprint(stdout, content)
+	return os.WriteFile(filepath.Join(logDir, "verify-full.log"), []byte(content+"STATUS: OK\n"), 0o644)
+}
+
+func parseBool(raw string) bool {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+func envDefault(getenv func(string) string, key, fallback string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(getenv func(string) string, key string, fallback int) int {
+	if n, err := strconv.Atoi(getenv(key)); err == nil {
+		return n
+	}
+	return fallback
+}
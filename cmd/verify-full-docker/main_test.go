@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// outMount is the host directory a spec mounts at /out.
+func outMount(spec containerSpec) string {
+	for _, bind := range spec.HostConfig.Binds {
+		if host, ok := strings.CutSuffix(bind, ":/out"); ok {
+			return host
+		}
+	}
+	return ""
+}
+
+func testOptions(t *testing.T, socket string) options {
+	t.Helper()
+	opts, err := parseOptions([]string{"--socket", socket}, func(key string) string {
+		return map[string]string{
+			"REPO_DIR":       t.TempDir(),
+			"OUT_DIR":        filepath.Join(t.TempDir(), "out"),
+			"HOST_UID":       "501",
+			"HOST_GID":       "20",
+			"GITHUB_SHA":     "abc123",
+			"GITHUB_ACTIONS": "true",
+		}[key]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestRunMountsRepoOutCacheAndUser(t *testing.T) {
+	f := &fakeEngine{}
+	opts := testOptions(t, startFakeEngine(t, f))
+	f.onStart = func(spec containerSpec) int {
+		_ = os.WriteFile(filepath.Join(outMount(spec), "verify-full.status"), []byte("status=OK\n"), 0o644)
+		return 0
+	}
+
+	var out bytes.Buffer
+	status, err := run(context.Background(), opts, &out)
+	if err != nil || status != "OK" {
+		t.Fatalf("status=%s err=%v\n%s", status, err, out.String())
+	}
+	if len(f.specs) != 1 {
+		t.Fatalf("specs=%+v", f.specs)
+	}
+	spec := f.specs[0]
+	if spec.User != "501:20" || spec.WorkingDir != "/repo" || spec.Cmd[0] != "/usr/local/bin/verify-full" || spec.HostConfig.NetworkMode != "" {
+		t.Fatalf("spec=%+v", spec)
+	}
+	binds := strings.Join(spec.HostConfig.Binds, " ")
+	for _, want := range []string{opts.repoDir + ":/repo", opts.outDir + ":/out", "ci-cache:/cache"} {
+		if !strings.Contains(binds, want) {
+			t.Fatalf("binds missing %q: %s", want, binds)
+		}
+	}
+	env := strings.Join(spec.Env, " ")
+	for _, want := range []string{"GITHUB_SHA=abc123", "GITHUB_ACTIONS=true", "VERIFY_ISOLATION=standard"} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("env missing %q: %s", want, env)
+		}
+	}
+	if !strings.Contains(out.String(), "OK: container=verify-full exit_code=0 oom_killed=false timed_out=false") {
+		t.Fatalf("output:\n%s", out.String())
+	}
+}
+
+func TestRunWritesStatusWhenContainerOOMKilled(t *testing.T) {
+	f := &fakeEngine{oom: true, onStart: func(containerSpec) int { return 137 }}
+	opts := testOptions(t, startFakeEngine(t, f))
+
+	_, err := run(context.Background(), opts, &bytes.Buffer{})
+	se, ok := err.(*statusError)
+	if !ok || se.reason != "container_oom_killed" {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestRunHermeticShardsFetchThenMerge(t *testing.T) {
+	f := &fakeEngine{}
+	opts := testOptions(t, startFakeEngine(t, f))
+	opts.isolation, opts.shards = "hermetic", 2
+	f.onStart = func(spec containerSpec) int {
+		name := filepath.Join(outMount(spec), "verify-full.status")
+		if spec.Cmd[len(spec.Cmd)-1] == "--fetch" {
+			name = filepath.Join(outMount(spec), fetchDir, fetchStatusFile)
+			_ = os.MkdirAll(filepath.Dir(name), 0o755)
+		}
+		_ = os.WriteFile(name, []byte("status=OK\n"), 0o644)
+		return 0
+	}
+
+	var out bytes.Buffer
+	if status, err := run(context.Background(), opts, &out); err != nil || status != "OK" {
+		t.Fatalf("status=%s err=%v\n%s", status, err, out.String())
+	}
+	if len(f.specs) != 4 {
+		t.Fatalf("expected fetch, 2 shards and merge, got %d", len(f.specs))
+	}
+	fetch, merge := f.specs[0], f.specs[3]
+	if fetch.HostConfig.NetworkMode != "" || !strings.Contains(strings.Join(fetch.Env, " "), "VERIFY_ISOLATION=standard") {
+		t.Fatalf("fetch spec=%+v", fetch)
+	}
+	for _, spec := range f.specs[1:] {
+		hc := spec.HostConfig
+		if hc.NetworkMode != "none" || !hc.ReadonlyRootfs || hc.CapDrop[0] != "ALL" || hc.SecurityOpt[0] != "no-new-privileges" ||
+			hc.Tmpfs["/tmp"] != "rw,exec,nosuid,nodev,size=2g" {
+			t.Fatalf("hermetic host config=%+v", hc)
+		}
+	}
+	if strings.Join(merge.Cmd, " ") != "/usr/local/bin/verify-full --merge-shards 2" || outMount(merge) != opts.outDir {
+		t.Fatalf("merge spec=%+v", merge)
+	}
+	for _, spec := range f.specs[1:3] {
+		binds, env := strings.Join(spec.HostConfig.Binds, " "), strings.Join(spec.Env, " ")
+		if !strings.Contains(outMount(spec), filepath.Join(opts.outDir, "shards")) ||
+			!strings.Contains(binds, filepath.Join(opts.outDir, fetchDir)+":/fetch:ro") || !strings.Contains(env, "VERIFY_FULL_FETCH_DIR=/fetch") {
+			t.Fatalf("shard spec=%+v", spec)
+		}
+	}
+}
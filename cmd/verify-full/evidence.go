@@ -0,0 +1,294 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVersionsLock  = "/etc/ci/versions.lock"
+	evidenceProbeTimeout = 10 * time.Second
+)
+
+// evidenceManifest is the in-bundle index. Files lists every other member
+// with its hash so a bundle can be checked without the status file.
+type evidenceManifest struct {
+	BundleID    string         `json:"bundle_id"`
+	GeneratedAt string         `json:"generated_at"`
+	Status      string         `json:"status"`
+	Files       []evidenceFile `json:"files"`
+	Missing     []string       `json:"missing,omitempty"`
+}
+
+type evidenceFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// evidenceMember is a bundle entry read from disk (source) or generated
+// in memory (data).
+type evidenceMember struct {
+	name    string
+	source  string
+	data    []byte
+	modTime time.Time
+}
+
+// evidenceBundle is what the status file records about a written bundle.
+type evidenceBundle struct {
+	path   string
+	sha256 string
+	files  int
+}
+
+// evidenceError means the status file was already finalized as ERROR, so
+// main must not overwrite it.
+type evidenceError struct {
+	err error
+}
+
+func (e *evidenceError) Error() string {
+	return "evidence bundle: " + e.err.Error()
+}
+
+func (e *evidenceError) Unwrap() error {
+	return e.err
+}
+
+func evidencePath(cfg config) string {
+	return filepath.Join(cfg.outDir, "evidence", cfg.stamp+".tar.gz")
+}
+
+// writeEvidenceBundle packs the status file, this run's logs, the image
+// versions.lock, tool versions and git info into out/evidence/<stamp>.tar.gz.
+// Old bundles are removed by the same retention policy as run logs.
+func writeEvidenceBundle(cfg config, opts options) (evidenceBundle, error) {
+	bundleID := "verify-full-" + cfg.stamp
+	now := time.Now().UTC()
+	manifest := evidenceManifest{
+		BundleID:    bundleID,
+		GeneratedAt: now.Format(time.RFC3339),
+		Status:      statusValue(filepath.Join(cfg.outDir, "verify-full.status")),
+	}
+
+	members := []evidenceMember{
+		{name: "verify-full.status", source: filepath.Join(cfg.outDir, "verify-full.status")},
+		{name: "verify-full.status.json", source: filepath.Join(cfg.outDir, "verify-full.status.json")},
+	}
+	logMembers, err := collectLogMembers(cfg.outDir, runLogDir(cfg))
+	if err != nil {
+		return evidenceBundle{}, fmt.Errorf("collect logs: %w", err)
+	}
+	members = append(members, logMembers...)
+
+	lockPath := envOr("VERIFY_FULL_VERSIONS_LOCK", defaultVersionsLock)
+	if _, err := os.Stat(lockPath); err == nil {
+		members = append(members, evidenceMember{name: "versions.lock", source: lockPath})
+	} else {
+		manifest.Missing = append(manifest.Missing, "versions.lock path="+lockPath)
+	}
+	members = append(members,
+		evidenceMember{name: "tool-versions.txt", data: []byte(toolVersions()), modTime: now},
+		evidenceMember{name: "git.txt", data: []byte(gitInfo(cfg.repoDir, opts)), modTime: now},
+	)
+
+	target := evidencePath(cfg)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return evidenceBundle{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+cfg.stamp+"-*.tar.gz")
+	if err != nil {
+		return evidenceBundle{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	bundleHash := sha256.New()
+	gw := gzip.NewWriter(io.MultiWriter(tmp, bundleHash))
+	tw := tar.NewWriter(gw)
+	for _, member := range members {
+		file, err := writeEvidenceMember(tw, bundleID, member)
+		if err != nil {
+			tmp.Close()
+			return evidenceBundle{}, fmt.Errorf("add %s: %w", member.name, err)
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		tmp.Close()
+		return evidenceBundle{}, err
+	}
+	manifestMember := evidenceMember{name: "manifest.json", data: append(manifestBytes, '\n'), modTime: now}
+	if _, err := writeEvidenceMember(tw, bundleID, manifestMember); err != nil {
+		tmp.Close()
+		return evidenceBundle{}, fmt.Errorf("add manifest.json: %w", err)
+	}
+	if err := errors.Join(tw.Close(), gw.Close(), tmp.Close()); err != nil {
+		return evidenceBundle{}, err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return evidenceBundle{}, err
+	}
+
+	rel, _ := filepath.Rel(cfg.outDir, target)
+	return evidenceBundle{
+		path:   filepath.ToSlash(rel),
+		sha256: hex.EncodeToString(bundleHash.Sum(nil)),
+		files:  len(manifest.Files),
+	}, nil
+}
+
+// collectLogMembers lists the run's log directory with out-relative names,
+// e.g. logs/verify-full/<stamp>/01-go-test.log.
+func collectLogMembers(outDir, runLogDir string) ([]evidenceMember, error) {
+	var members []evidenceMember
+	err := filepath.WalkDir(runLogDir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrNotExist) && path == runLogDir {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		members = append(members, evidenceMember{name: filepath.ToSlash(rel), source: path})
+		return nil
+	})
+	return members, err
+}
+
+func writeEvidenceMember(tw *tar.Writer, bundleID string, member evidenceMember) (evidenceFile, error) {
+	var content io.Reader
+	size := int64(len(member.data))
+	modTime := member.modTime
+	if member.source != "" {
+		f, err := os.Open(member.source)
+		if err != nil {
+			return evidenceFile{}, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return evidenceFile{}, err
+		}
+		// Logs may still grow (e.g. verify-full.log); freeze the size now.
+		content, size, modTime = io.LimitReader(f, info.Size()), info.Size(), info.ModTime()
+	} else {
+		content = bytes.NewReader(member.data)
+	}
+
+	header := &tar.Header{
+		Name:     bundleID + "/" + member.name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return evidenceFile{}, err
+	}
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tw, h), content)
+	if err != nil {
+		return evidenceFile{}, err
+	}
+	if written != size {
+		return evidenceFile{}, fmt.Errorf("short read: %d of %d bytes", written, size)
+	}
+	return evidenceFile{Path: member.name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// statusValue returns the status= value of a status file.
+func statusValue(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(line, "status="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// toolVersions records the toolchain the pipeline steps ran with.
+func toolVersions() string {
+	var b strings.Builder
+	for _, probe := range [][]string{
+		{"go", "version"},
+		{"git", "--version"},
+	} {
+		fmt.Fprintf(&b, "%s=%s\n", probe[0], probeOutput("", probe...))
+	}
+	return b.String()
+}
+
+// gitInfo records the checked-out commit. safe.directory is needed because
+// /repo is a bind mount owned by the host user, not the container user.
+func gitInfo(repoDir string, opts options) string {
+	git := func(args ...string) string {
+		return probeOutput(repoDir, append([]string{"git", "-c", "safe.directory=*"}, args...)...)
+	}
+	lines := []string{
+		"sha=" + git("rev-parse", "HEAD"),
+		"ref=" + git("rev-parse", "--abbrev-ref", "HEAD"),
+		"dirty=" + dirtyValue(git("status", "--porcelain", "--untracked-files=no")),
+	}
+	if opts.githubSHA != "" {
+		lines = append(lines, "github_sha="+opts.githubSHA)
+	}
+	if opts.githubRef != "" {
+		lines = append(lines, "github_ref="+opts.githubRef)
+	}
+	if opts.githubRunID != "" {
+		lines = append(lines, "github_run_id="+opts.githubRunID)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func dirtyValue(porcelain string) string {
+	switch porcelain {
+	case "unavailable":
+		return porcelain
+	case "":
+		return "false"
+	default:
+		return "true"
+	}
+}
+
+// probeOutput returns the first output line of a version command, or
+// "unavailable" when it cannot run.
+func probeOutput(dir string, argv ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), evidenceProbeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "unavailable"
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(first)
+}
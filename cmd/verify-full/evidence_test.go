@@ -0,0 +1,154 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readBundle(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return members
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members[header.Name] = content
+	}
+}
+
+func TestRunWritesEvidenceBundle(t *testing.T) {
+	cfg := pipelineFixture(t, `{"steps": [{"name": "hello", "run": "echo hello"}]}`)
+	lockPath := filepath.Join(t.TempDir(), "versions.lock")
+	if err := os.WriteFile(lockPath, []byte("[toolchain]\ngo = \"1.25.6\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VERIFY_FULL_VERSIONS_LOCK", lockPath)
+	if _, err := exec.LookPath("git"); err == nil {
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"-c", "user.name=ci", "-c", "user.email=ci@example.invalid", "commit", "-q", "--allow-empty", "-m", "init"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = cfg.repoDir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := run(cfg, options{githubRef: "main"}, &buf); err != nil {
+		t.Fatalf("run: %v\n%s", err, buf.String())
+	}
+
+	bundlePath := filepath.Join(cfg.outDir, "evidence", cfg.stamp+".tar.gz")
+	raw, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(raw)
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{
+		"bundle=evidence/" + cfg.stamp + ".tar.gz",
+		"bundle_sha256=" + hex.EncodeToString(sum[:]),
+		"OK: bundle=evidence/",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status missing %q:\n%s", want, status)
+		}
+	}
+
+	prefix := "verify-full-" + cfg.stamp + "/"
+	members := readBundle(t, bundlePath)
+	var manifest evidenceManifest
+	if err := json.Unmarshal(members[prefix+"manifest.json"], &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.Status != "OK" || len(manifest.Missing) != 0 {
+		t.Fatalf("manifest: %+v", manifest)
+	}
+	listed := map[string]bool{}
+	for _, file := range manifest.Files {
+		listed[file.Path] = true
+		content, ok := members[prefix+file.Path]
+		got := sha256.Sum256(content)
+		if !ok || hex.EncodeToString(got[:]) != file.SHA256 || int64(len(content)) != file.Size {
+			t.Errorf("manifest entry %s does not match the bundle", file.Path)
+		}
+	}
+	for _, want := range []string{
+		"verify-full.status",
+		"logs/verify-full/" + cfg.stamp + "/verify-full.log",
+		"logs/verify-full/" + cfg.stamp + "/01-hello.log",
+		"versions.lock",
+		"tool-versions.txt",
+		"git.txt",
+	} {
+		if !listed[want] {
+			t.Errorf("manifest missing %s: %v", want, listed)
+		}
+	}
+	if strings.Contains(string(members[prefix+"verify-full.status"]), "bundle_sha256=") {
+		t.Fatal("bundled status must be the one written before the bundle")
+	}
+	gitTxt := string(members[prefix+"git.txt"])
+	if !strings.Contains(gitTxt, "github_ref=main") {
+		t.Fatalf("git.txt:\n%s", gitTxt)
+	}
+	if _, err := exec.LookPath("git"); err == nil && strings.Contains(gitTxt, "sha=unavailable") {
+		t.Fatalf("git.txt should record the commit:\n%s", gitTxt)
+	}
+	if !strings.Contains(buf.String(), "OK: evidence bundle=evidence/"+cfg.stamp+".tar.gz") {
+		t.Fatalf("console:\n%s", buf.String())
+	}
+}
+
+func TestRunFailsWhenEvidenceBundleCannotBeWritten(t *testing.T) {
+	cfg := pipelineFixture(t, `{"steps": [{"name": "hello", "run": "true"}]}`)
+	t.Setenv("VERIFY_FULL_VERSIONS_LOCK", filepath.Join(t.TempDir(), "missing.lock"))
+	// A file where the evidence directory should be.
+	if err := os.WriteFile(filepath.Join(cfg.outDir, "evidence"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := run(cfg, options{}, &bytes.Buffer{})
+	var bundleErr *evidenceError
+	if !errors.As(err, &bundleErr) {
+		t.Fatalf("err=%v", err)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{"status=ERROR", "bundle_error=", "reason=evidence bundle: ", "step=hello status=OK"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status missing %q:\n%s", want, status)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// cacheVolumeMarker is created on first use of the /cache volume; its
+	// absence means the volume was (re)created and starts cold.
+	cacheVolumeMarker = ".verify-full-cache-created"
+	// lowHitRate marks a run as cold even on an old volume, e.g. after a Go
+	// version bump invalidated every entry.
+	lowHitRate        = 0.10
+	lowHitRateMinimum = 20
+)
+
+// goShim is put first in PATH for pipeline steps. Each go command dumps its
+// action graph to its own file; GOFLAGS flags a command does not know are
+// ignored, so go version / go env are unaffected.
+const goShim = `#!/bin/sh
+graph=$(mktemp "$VERIFY_FULL_GOGRAPH_DIR/graph-XXXXXX") || exec "$VERIFY_FULL_REAL_GO" "$@"
+GOFLAGS="${GOFLAGS:+$GOFLAGS }-debug-actiongraph=$graph" exec "$VERIFY_FULL_REAL_GO" "$@"
+`
+
+// goCacheProbe counts Go build cache hits and misses from the action graph
+// (-debug-actiongraph, a hidden cmd/go build flag): a "build" action that
+// ran a command is a miss, one that did not is a hit.
+type goCacheProbe struct {
+	realGo   string
+	shimDir  string
+	graphDir string
+}
+
+// goCacheStats is the Go cache activity of one step or the whole run.
+type goCacheStats struct {
+	hits        int
+	misses      int
+	testsCached int
+	testsRun    int
+}
+
+func (s *goCacheStats) add(o goCacheStats) {
+	s.hits += o.hits
+	s.misses += o.misses
+	s.testsCached += o.testsCached
+	s.testsRun += o.testsRun
+}
+
+func (s goCacheStats) empty() bool {
+	return s.hits+s.misses+s.testsCached+s.testsRun == 0
+}
+
+func (s goCacheStats) hitRate() float64 {
+	if s.hits+s.misses == 0 {
+		return 0
+	}
+	return float64(s.hits) / float64(s.hits+s.misses)
+}
+
+func (s goCacheStats) fields() string {
+	return fmt.Sprintf(" go_cache_hits=%d go_cache_misses=%d go_tests_cached=%d go_tests_executed=%d",
+		s.hits, s.misses, s.testsCached, s.testsRun)
+}
+
+// newGoCacheProbe returns nil when go is not installed.
+func newGoCacheProbe() (*goCacheProbe, error) {
+	realGo, err := exec.LookPath("go")
+	if err != nil {
+		return nil, nil
+	}
+	if realGo, err = filepath.Abs(realGo); err != nil {
+		return nil, err
+	}
+	shimDir, err := os.MkdirTemp("", "verify-full-goshim-")
+	if err != nil {
+		return nil, err
+	}
+	p := &goCacheProbe{realGo: realGo, shimDir: shimDir, graphDir: filepath.Join(shimDir, "graphs")}
+	if err := os.Mkdir(p.graphDir, 0o755); err != nil {
+		p.close()
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(shimDir, "go"), []byte(goShim), 0o755); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *goCacheProbe) env() []string {
+	return []string{
+		"PATH=" + p.shimDir + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"VERIFY_FULL_REAL_GO=" + p.realGo,
+		"VERIFY_FULL_GOGRAPH_DIR=" + p.graphDir,
+	}
+}
+
+func (p *goCacheProbe) close() {
+	_ = os.RemoveAll(p.shimDir)
+}
+
+// collect reads and removes the action graphs the step left behind and
+// counts go test results in its log.
+func (p *goCacheProbe) collect(logPath string) (goCacheStats, error) {
+	var stats goCacheStats
+	graphs, err := filepath.Glob(filepath.Join(p.graphDir, "graph-*"))
+	if err != nil {
+		return stats, err
+	}
+	var errs []error
+	for _, path := range graphs {
+		hits, misses, err := countGraphActions(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+		}
+		stats.hits += hits
+		stats.misses += misses
+		_ = os.Remove(path)
+	}
+	stats.testsCached, stats.testsRun = countTestResults(logPath)
+	return stats, errors.Join(errs...)
+}
+
+type graphAction struct {
+	Mode    string
+	Package string
+	Cmd     []string
+}
+
+// countGraphActions returns build hits and misses. A command that did not
+// build anything (go env, go mod) leaves an empty file.
+func countGraphActions(path string) (int, int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		return 0, 0, err
+	}
+	var actions []graphAction
+	if err := json.Unmarshal(content, &actions); err != nil {
+		return 0, 0, err
+	}
+	hits, misses := 0, 0
+	for _, a := range actions {
+		if a.Mode != "build" || a.Package == "" {
+			continue
+		}
+		if len(a.Cmd) > 0 {
+			misses++
+		} else {
+			hits++
+		}
+	}
+	return hits, misses, nil
+}
+
+// goTestResult matches the go test summary: "ok  <pkg>  (cached)" or
+// "ok  <pkg>  0.12s".
+var goTestResult = regexp.MustCompile(`^ok\s+\S+\s+(\(cached\)|[0-9.]+s)`)
+
+func countTestResults(logPath string) (int, int) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	cached, run := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := goTestResult.FindStringSubmatch(scanner.Text())
+		switch {
+		case m == nil:
+		case m[1] == "(cached)":
+			cached++
+		default:
+			run++
+		}
+	}
+	return cached, run
+}
+
+// goCacheDir is the GOCACHE steps use: the container's own, else the one
+// cacheEnv points at the volume.
+func goCacheDir(cacheDir string) string {
+	return envOr("GOCACHE", filepath.Join(cacheDir, "go-build"))
+}
+
+// goCacheHasEntries reports whether GOCACHE holds at least one action entry.
+func goCacheHasEntries(dir string) bool {
+	found := false
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), "-a") {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// markCacheVolume creates the marker and reports whether it already existed.
+func markCacheVolume(cacheDir string) bool {
+	path := filepath.Join(cacheDir, cacheVolumeMarker)
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	_ = os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0o644)
+	return false
+}
+
+// coldStartReason explains why the run started without a usable cache, or
+// returns "".
+func coldStartReason(volumeExisted, gocacheWarm bool, stats goCacheStats) string {
+	switch {
+	case !volumeExisted:
+		return "cache_volume_new"
+	case !gocacheWarm:
+		return "gocache_empty"
+	case stats.hits+stats.misses >= lowHitRateMinimum && stats.hitRate() < lowHitRate:
+		return "low_hit_rate"
+	}
+	return ""
+}
+
+// goCacheDetails are the run totals for the status file. A cold start is
+// also printed as a WARN line so a recreated volume is visible in the log.
+func goCacheDetails(total goCacheStats, coldReason string, writer io.Writer) []string {
+	details := []string{
+		fmt.Sprintf("go_cache_hits=%d", total.hits),
+		fmt.Sprintf("go_cache_misses=%d", total.misses),
+		fmt.Sprintf("go_cache_hit_rate=%.2f", total.hitRate()),
+		fmt.Sprintf("go_tests_cached=%d", total.testsCached),
+		fmt.Sprintf("go_tests_executed=%d", total.testsRun),
+		fmt.Sprintf("go_cache_cold_start=%t", coldReason != ""),
+	}
+	fmt.Fprintf(writer, "OK: go_cache hits=%d misses=%d hit_rate=%.2f tests_cached=%d tests_executed=%d\n",
+		total.hits, total.misses, total.hitRate(), total.testsCached, total.testsRun)
+	if coldReason != "" {
+		warn := fmt.Sprintf("WARN: go_cache cold_start reason=%s hit_rate=%.2f", coldReason, total.hitRate())
+		fmt.Fprintln(writer, warn)
+		details = append(details, warn, "go_cache_cold_reason="+coldReason)
+	}
+	return details
+}
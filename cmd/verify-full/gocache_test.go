@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipelineCountsGoCacheHits(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not installed")
+	}
+	hostCache, err := exec.Command(goBin, "env", "GOCACHE").Output()
+	if err != nil {
+		t.Skip("go env GOCACHE failed")
+	}
+	cfg := pipelineFixture(t, `{"steps": [{"name": "build", "run": "go build -o /dev/null . && go vet .", "dir": "sub"}]}`)
+	// The host cache keeps the test fast; only the tiny module below is new.
+	t.Setenv("GOCACHE", strings.TrimSpace(string(hostCache)))
+	for name, content := range map[string]string{
+		"go.mod":  "module gocachefixture\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(cfg.repoDir, "sub", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out strings.Builder
+	if err := run(cfg, options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{"go_cache_cold_start=true", "go_cache_cold_reason=cache_volume_new", "WARN: go_cache cold_start reason=cache_volume_new"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("first run status missing %q:\n%s", want, status)
+		}
+	}
+
+	cfg.stamp = "20260301T000100Z"
+	out.Reset()
+	if err := run(cfg, options{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	status = mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	if !strings.Contains(status, "go_cache_cold_start=false") || strings.Contains(status, "go_cache_hits=0\n") ||
+		!strings.Contains(status, "step=build status=OK") || !strings.Contains(status, " go_cache_hits=") {
+		t.Fatalf("second run status:\n%s\nconsole:\n%s", status, out.String())
+	}
+}
+
+func TestGoCacheGraphAndColdStart(t *testing.T) {
+	graph := filepath.Join(t.TempDir(), "graph")
+	content := `[
+{"ID":0,"Mode":"build","Package":"runtime"},
+{"ID":1,"Mode":"build","Package":"example.com/a","Cmd":["compile"]},
+{"ID":2,"Mode":"link","Package":"example.com/a","Cmd":["link"]},
+{"ID":3,"Mode":"build","Package":"errors"}
+]`
+	if err := os.WriteFile(graph, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hits, misses, err := countGraphActions(graph)
+	if err != nil || hits != 2 || misses != 1 {
+		t.Fatalf("hits=%d misses=%d err=%v", hits, misses, err)
+	}
+
+	cases := []struct {
+		volume, warm bool
+		stats        goCacheStats
+		want         string
+	}{
+		{false, true, goCacheStats{hits: 50}, "cache_volume_new"},
+		{true, false, goCacheStats{misses: 50}, "gocache_empty"},
+		{true, true, goCacheStats{hits: 1, misses: 40}, "low_hit_rate"},
+		{true, true, goCacheStats{hits: 0, misses: 5}, ""},
+		{true, true, goCacheStats{hits: 40, misses: 5}, ""},
+	}
+	for _, c := range cases {
+		if got := coldStartReason(c.volume, c.warm, c.stats); got != c.want {
+			t.Errorf("coldStartReason(%v, %v, %+v) = %q, want %q", c.volume, c.warm, c.stats, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// envIsolation is the level run_verify_full.sh started the container
+	// with: "standard" (default) or "hermetic".
+	envIsolation      = "VERIFY_ISOLATION"
+	isolationStandard = "standard"
+	isolationHermetic = "hermetic"
+)
+
+// isolationReport is what the container actually looks like from inside,
+// so the status file records the observed level and not only the request.
+type isolationReport struct {
+	requested string
+	// network is "none" when only loopback interfaces are up.
+	network    string
+	rootfs     string
+	noNewPrivs bool
+	capEff     string
+}
+
+// probeIsolation reads /proc/self (VERIFY_FULL_PROC_DIR) and the network
+// interfaces. An empty level is standard.
+func probeIsolation(requested, procDir string, interfaces func() ([]net.Interface, error)) isolationReport {
+	if requested == "" {
+		requested = isolationStandard
+	}
+	r := isolationReport{requested: requested, network: "unknown", rootfs: "unknown", capEff: "unknown"}
+	if ifaces, err := interfaces(); err == nil {
+		r.network = "none"
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 {
+				r.network = "available"
+			}
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(procDir, "self", "mountinfo")); err == nil {
+		// The last mount on / is the one processes see (overlay on top).
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 6 && fields[4] == "/" {
+				r.rootfs = "rw"
+				for _, opt := range strings.Split(fields[5], ",") {
+					if opt == "ro" {
+						r.rootfs = "ro"
+					}
+				}
+			}
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(procDir, "self", "status")); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "NoNewPrivs":
+				r.noNewPrivs = strings.TrimSpace(value) == "1"
+			case "CapEff":
+				r.capEff = strings.TrimSpace(value)
+			}
+		}
+	}
+	return r
+}
+
+// violations lists what a hermetic run is missing.
+func (r isolationReport) violations() []string {
+	if r.requested != isolationHermetic {
+		return nil
+	}
+	var missing []string
+	if r.network != "none" {
+		missing = append(missing, "network="+r.network)
+	}
+	if r.rootfs != "ro" {
+		missing = append(missing, "rootfs="+r.rootfs)
+	}
+	if !r.noNewPrivs {
+		missing = append(missing, "no_new_privs=false")
+	}
+	if strings.Trim(r.capEff, "0") != "" {
+		missing = append(missing, "cap_eff="+r.capEff)
+	}
+	return missing
+}
+
+func (r isolationReport) String() string {
+	return fmt.Sprintf("isolation=%s network=%s rootfs=%s no_new_privs=%t cap_eff=%s",
+		r.requested, r.network, r.rootfs, r.noNewPrivs, r.capEff)
+}
+
+// isolationDetails are the status lines for the observed isolation.
+func isolationDetails(r isolationReport) []string {
+	return []string{
+		"isolation=" + r.requested,
+		"isolation_network=" + r.network,
+		"isolation_rootfs=" + r.rootfs,
+		fmt.Sprintf("isolation_no_new_privs=%t", r.noNewPrivs),
+		"isolation_cap_eff=" + r.capEff,
+	}
+}
+
+func requestedIsolation(getenv func(string) string) (string, error) {
+	switch level := strings.TrimSpace(getenv(envIsolation)); level {
+	case "", isolationStandard:
+		return isolationStandard, nil
+	case isolationHermetic:
+		return level, nil
+	default:
+		return "", fmt.Errorf("%s must be %s or %s: %q", envIsolation, isolationStandard, isolationHermetic, level)
+	}
+}
+
+// isolationError keeps the isolation status lines for the ERROR status file.
+type isolationError struct {
+	missing []string
+	details []string
+}
+
+func (e *isolationError) Error() string {
+	return "hermetic isolation not in effect: " + strings.Join(e.missing, ", ")
+}
+
+const fetchStatusFile = "verify-full-fetch.status"
+
+// fetchDir holds the last fetch phase: its status file and logs. It is
+// replaced by every fetch and sits outside out/logs/verify-full, so log
+// retention never counts it as a run. Shard containers get the host's
+// out/fetch read-only through VERIFY_FULL_FETCH_DIR.
+func fetchDir(cfg config) string {
+	return envOr("VERIFY_FULL_FETCH_DIR", filepath.Join(cfg.outDir, "fetch"))
+}
+
+// fetchSteps are the declared fetch steps or, for a Go module without any,
+// go mod download.
+func fetchSteps(repoDir string, pipeline *pipelineConfig) []pipelineStep {
+	if pipeline != nil && len(pipeline.Fetch) > 0 {
+		return pipeline.Fetch
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "go.mod")); err == nil {
+		return []pipelineStep{{Name: "go-mod-download", Run: stepCommand{argv: []string{"go", "mod", "download"}}}}
+	}
+	return nil
+}
+
+// runFetch is the network-enabled phase of a hermetic run. It fills /cache
+// and writes out/fetch/verify-full-fetch.status, which the isolated run
+// records along with the fetch logs.
+func runFetch(cfg config, stdout io.Writer) error {
+	for _, dir := range []string{cfg.repoDir, cfg.outDir, cfg.cacheDir} {
+		if err := requireDir(dir); err != nil {
+			return fmt.Errorf("missing %s", dir)
+		}
+	}
+	logDir := fetchDir(cfg)
+	if err := os.RemoveAll(logDir); err != nil {
+		return fmt.Errorf("clear fetch dir: %w", err)
+	}
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return fmt.Errorf("mkdir fetch dir: %w", err)
+	}
+	logFile, err := os.Create(filepath.Join(logDir, "verify-full-fetch.log"))
+	if err != nil {
+		return fmt.Errorf("create log file: %w", err)
+	}
+	defer logFile.Close()
+	writer := io.MultiWriter(stdout, logFile)
+	fmt.Fprintf(writer, "OK: verify-full fetch started stamp=%s\n", cfg.stamp)
+
+	lines := []string{"timestamp=" + cfg.stamp}
+	configPath := pipelineConfigPath(cfg.repoDir)
+	pipeline, err := loadPipelineConfig(configPath)
+	if err != nil {
+		err = fmt.Errorf("pipeline config: %w", err)
+		return writeFetchStatus(cfg, lines, err)
+	}
+	steps := fetchSteps(cfg.repoDir, pipeline)
+	if len(steps) == 0 {
+		fmt.Fprintln(writer, "SKIP: fetch reason=no_fetch_steps")
+	}
+	env := append(os.Environ(), cacheEnv(cfg.cacheDir)...)
+	var failed []string
+	for i, step := range steps {
+		logPath := filepath.Join(logDir, fmt.Sprintf("%02d-%s.log", i+1, step.Name))
+		result := runStep(cfg, step, env, logPath, stepProbes{interval: sampleInterval()}, writer)
+		if result.status == "ERROR" {
+			failed = append(failed, fmt.Sprintf("%s(%s)", step.Name, result.reason))
+		}
+		lines = append(lines, result.detail(cfg.outDir))
+	}
+	lines = append(lines, fmt.Sprintf("fetch_steps=%d", len(steps)), fmt.Sprintf("fetch_failed=%d", len(failed)))
+	if len(failed) > 0 {
+		return writeFetchStatus(cfg, lines, errors.New("fetch failed: "+strings.Join(failed, ", ")))
+	}
+	fmt.Fprintln(writer, "OK: verify-full fetch completed")
+	return writeFetchStatus(cfg, lines, nil)
+}
+
+// writeFetchStatus returns fetchErr unless the file itself cannot be written.
+func writeFetchStatus(cfg config, lines []string, fetchErr error) error {
+	status := "OK"
+	if fetchErr != nil {
+		status = "ERROR"
+		lines = append(lines, "reason="+fetchErr.Error())
+	}
+	lines = append([]string{fmt.Sprintf("%s: verify-full fetch status=%s", status, status), "status=" + status}, lines...)
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(fetchDir(cfg), fetchStatusFile), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write fetch status: %w", err)
+	}
+	return fetchErr
+}
+
+// fetchStatus is the status= of the last fetch phase, or "missing".
+func fetchStatus(cfg config) string {
+	content, err := os.ReadFile(filepath.Join(fetchDir(cfg), fetchStatusFile))
+	if err != nil {
+		return "missing"
+	}
+	if status := parseSummaryStatus(string(content)).values["status"]; status != "" {
+		return status
+	}
+	return "unknown"
+}
+
+// copyFetchLogs puts the fetch phase's status and logs under this run's log
+// directory, so they are bundled and trimmed with the run they belong to.
+func copyFetchLogs(cfg config, logDir string) error {
+	src := fetchDir(cfg)
+	entries, err := os.ReadDir(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(logDir, "fetch")
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProcSelf(t *testing.T, mountinfo, status string) string {
+	t.Helper()
+	procDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(procDir, "self"))
+	for name, content := range map[string]string{"mountinfo": mountinfo, "status": status} {
+		if err := os.WriteFile(filepath.Join(procDir, "self", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return procDir
+}
+
+func TestProbeIsolationHermetic(t *testing.T) {
+	procDir := writeProcSelf(t,
+		"1 0 0:1 / / rw,relatime - overlay overlay rw\n2 1 0:2 / / ro,relatime - overlay overlay rw\n3 1 0:3 / /tmp rw - tmpfs tmpfs rw\n",
+		"Name:\tverify-full\nCapEff:\t0000000000000000\nNoNewPrivs:\t1\n")
+	loopbackOnly := func() ([]net.Interface, error) {
+		return []net.Interface{{Name: "lo", Flags: net.FlagUp | net.FlagLoopback}, {Name: "eth0"}}, nil
+	}
+
+	r := probeIsolation(isolationHermetic, procDir, loopbackOnly)
+	if r.network != "none" || r.rootfs != "ro" || !r.noNewPrivs || r.capEff != "0000000000000000" {
+		t.Fatalf("report=%s", r)
+	}
+	if missing := r.violations(); len(missing) != 0 {
+		t.Fatalf("violations=%v", missing)
+	}
+}
+
+func TestProbeIsolationReportsViolations(t *testing.T) {
+	procDir := writeProcSelf(t,
+		"1 0 0:1 / / rw,relatime - overlay overlay rw\n",
+		"CapEff:\t00000000a80425fb\nNoNewPrivs:\t0\n")
+	withEth := func() ([]net.Interface, error) {
+		return []net.Interface{{Name: "lo", Flags: net.FlagUp | net.FlagLoopback}, {Name: "eth0", Flags: net.FlagUp}}, nil
+	}
+
+	r := probeIsolation(isolationHermetic, procDir, withEth)
+	got := strings.Join(r.violations(), ",")
+	if got != "network=available,rootfs=rw,no_new_privs=false,cap_eff=00000000a80425fb" {
+		t.Fatalf("violations=%s", got)
+	}
+	if standard := probeIsolation("", procDir, withEth); standard.requested != isolationStandard || standard.violations() != nil {
+		t.Fatalf("standard report=%s", standard)
+	}
+}
+
+func TestRequestedIsolation(t *testing.T) {
+	for raw, want := range map[string]string{"": isolationStandard, "standard": isolationStandard, " hermetic ": isolationHermetic} {
+		got, err := requestedIsolation(func(string) string { return raw })
+		if err != nil || got != want {
+			t.Fatalf("%q: got=%q err=%v", raw, got, err)
+		}
+	}
+	if _, err := requestedIsolation(func(string) string { return "sealed" }); err == nil {
+		t.Fatal("expected unknown level to fail")
+	}
+}
+
+func TestRunFetchWritesStatus(t *testing.T) {
+	cfg := pipelineFixture(t, `{
+  "steps": [{"name": "test", "run": ["true"]}],
+  "fetch": [
+    {"name": "deps", "run": "echo fetched > $GOMODCACHE.txt"},
+    {"name": "broken", "run": "exit 4"}
+  ]
+}`)
+
+	var buf bytes.Buffer
+	err := runFetch(cfg, &buf)
+	if err == nil || err.Error() != "fetch failed: broken(exit_4)" {
+		t.Fatalf("err=%v\n%s", err, buf.String())
+	}
+	if got := mustRead(t, filepath.Join(cfg.cacheDir, "go-mod.txt")); got != "fetched\n" {
+		t.Fatalf("fetch step did not write into the cache: %q", got)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "fetch", fetchStatusFile))
+	for _, want := range []string{
+		"status=ERROR",
+		"step=deps status=OK exit_code=0",
+		"log=fetch/01-deps.log",
+		"fetch_failed=1",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("fetch status missing %q:\n%s", want, status)
+		}
+	}
+	if got := fetchStatus(cfg); got != "ERROR" {
+		t.Fatalf("fetchStatus=%s", got)
+	}
+	// The fetch phase is not a run of its own for log retention.
+	if _, err := os.Stat(filepath.Join(cfg.outDir, "logs", "verify-full")); !os.IsNotExist(err) {
+		t.Fatalf("fetch created a run log directory: %v", err)
+	}
+}
+
+func TestShardReadsFetchDirAndBundlesItsLogs(t *testing.T) {
+	fetch := t.TempDir()
+	for name, content := range map[string]string{fetchStatusFile: "status=OK\n", "01-deps.log": "fetched\n"} {
+		if err := os.WriteFile(filepath.Join(fetch, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("VERIFY_FULL_FETCH_DIR", fetch)
+	cfg := pipelineFixture(t, `{"steps": [{"name": "test", "run": ["true"]}]}`)
+
+	if got := fetchStatus(cfg); got != "OK" {
+		t.Fatalf("fetchStatus=%s", got)
+	}
+	if err := copyFetchLogs(cfg, runLogDir(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustRead(t, filepath.Join(runLogDir(cfg), "fetch", "01-deps.log")); got != "fetched\n" {
+		t.Fatalf("fetch log not copied: %q", got)
+	}
+	bundle, err := writeStatus(cfg, options{}, "OK", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := readBundle(t, filepath.Join(cfg.outDir, bundle.path))
+	if got := string(members["verify-full-20260301T000000Z/logs/verify-full/20260301T000000Z/fetch/01-deps.log"]); got != "fetched\n" {
+		t.Fatalf("bundle misses the fetch log: %q", got)
+	}
+}
+
+func TestLoadPipelineConfigRejectsShardedFetch(t *testing.T) {
+	cfg := pipelineFixture(t, `{
+  "steps": [{"name": "test", "run": ["true"]}],
+  "fetch": [{"name": "deps", "run": ["go", "mod", "download"], "shard": "go-packages"}]
+}`)
+	_, err := loadPipelineConfig(pipelineConfigPath(cfg.repoDir))
+	if err == nil || !strings.Contains(err.Error(), "step deps shard") {
+		t.Fatalf("err=%v", err)
+	}
+}
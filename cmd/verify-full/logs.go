@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention env vars are shared with gc_out so both trim out/logs the same
+// way. Flags (--keep-logs / --ttl-logs-days / --max-logs-mb) override them.
+const (
+	envLogsKeep    = "CI_SELF_LOGS_KEEP"
+	envLogsTTLDays = "CI_SELF_LOGS_TTL_DAYS"
+	envLogsMaxMB   = "CI_SELF_LOGS_MAX_MB"
+
+	defaultLogsKeep    = 5
+	defaultLogsTTLDays = 14
+)
+
+// runStampPattern is verify-full's own naming; retention never touches other
+// entries under out/logs/verify-full or out/evidence.
+var runStampPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z$`)
+
+// retentionPolicy keeps the newest keep runs, drops runs older than ttlDays
+// and, when maxMB > 0, drops the oldest runs until the rest fit.
+type retentionPolicy struct {
+	keep    int
+	ttlDays int
+	maxMB   int
+}
+
+func (p retentionPolicy) String() string {
+	return fmt.Sprintf("keep=%d ttl_days=%d max_mb=%d", p.keep, p.ttlDays, p.maxMB)
+}
+
+// retentionFromEnv returns the env-configured policy used as flag defaults.
+func retentionFromEnv(getenv func(string) string) (retentionPolicy, error) {
+	policy := retentionPolicy{keep: defaultLogsKeep, ttlDays: defaultLogsTTLDays}
+	for _, item := range []struct {
+		key   string
+		value *int
+	}{
+		{envLogsKeep, &policy.keep},
+		{envLogsTTLDays, &policy.ttlDays},
+		{envLogsMaxMB, &policy.maxMB},
+	} {
+		raw := strings.TrimSpace(getenv(item.key))
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return retentionPolicy{}, fmt.Errorf("%s must be a non-negative integer: %q", item.key, raw)
+		}
+		*item.value = n
+	}
+	return policy, nil
+}
+
+func runLogDir(cfg config) string {
+	return filepath.Join(cfg.outDir, "logs", "verify-full", cfg.stamp)
+}
+
+// applyLogRetention trims old runs once this run's status is final, for OK
+// and ERROR alike. The report goes to stdout and is appended to the run's
+// verify-full.log when it exists.
+func applyLogRetention(cfg config, policy retentionPolicy, stdout io.Writer) {
+	writer := stdout
+	if f, err := os.OpenFile(filepath.Join(runLogDir(cfg), "verify-full.log"), os.O_APPEND|os.O_WRONLY, 0o644); err == nil {
+		defer f.Close()
+		writer = io.MultiWriter(stdout, f)
+	}
+	deleted, err := trimRunLogs(cfg, policy, time.Now(), writer)
+	if err != nil {
+		fmt.Fprintf(writer, "ERROR: log_retention err=%s\n", err.Error())
+		return
+	}
+	fmt.Fprintf(writer, "OK: log_retention deleted=%d %s\n", deleted, policy)
+}
+
+type logRun struct {
+	stamp string
+	path  string
+	at    time.Time
+	bytes int64
+}
+
+// trimRunLogs applies the policy to out/logs/verify-full/<stamp> and, on its
+// own, to the bundles in out/evidence/<stamp>.tar.gz, reporting every removed
+// entry. The current run is always kept.
+func trimRunLogs(cfg config, policy retentionPolicy, now time.Time, writer io.Writer) (int, error) {
+	deleted, err := trimStamped(cfg, filepath.Dir(runLogDir(cfg)), "", policy, now, writer)
+	if err != nil {
+		return deleted, err
+	}
+	bundles, err := trimStamped(cfg, filepath.Dir(evidencePath(cfg)), ".tar.gz", policy, now, writer)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return deleted + bundles, err
+}
+
+// trimStamped trims the <stamp><suffix> entries of root, newest first: run
+// directories when suffix is empty, otherwise regular files.
+func trimStamped(cfg config, root, suffix string, policy retentionPolicy, now time.Time, writer io.Writer) (int, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0, err
+	}
+	var runs []logRun
+	for _, entry := range entries {
+		stamp, ok := strings.CutSuffix(entry.Name(), suffix)
+		if !ok || entry.IsDir() != (suffix == "") || !runStampPattern.MatchString(stamp) {
+			continue
+		}
+		at, err := time.Parse("20060102T150405Z", stamp)
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		size, err := dirBytes(path)
+		if err != nil {
+			return 0, err
+		}
+		runs = append(runs, logRun{stamp: stamp, path: path, at: at, bytes: size})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].stamp > runs[j].stamp })
+
+	cutoff := now.Add(-time.Duration(policy.ttlDays) * 24 * time.Hour)
+	maxBytes := int64(policy.maxMB) << 20
+	var kept int64
+	deleted := 0
+	for i, run := range runs {
+		reason := ""
+		switch {
+		case run.stamp == cfg.stamp:
+		case i >= policy.keep:
+			reason = "count"
+		case run.at.Before(cutoff):
+			reason = "age"
+		case maxBytes > 0 && kept+run.bytes > maxBytes:
+			reason = "size"
+		}
+		if reason == "" {
+			kept += run.bytes
+			continue
+		}
+		if err := os.RemoveAll(run.path); err != nil {
+			return deleted, err
+		}
+		deleted++
+		rel, _ := filepath.Rel(cfg.outDir, run.path)
+		fmt.Fprintf(writer, "OK: log_retention removed=%s reason=%s bytes=%d\n", filepath.ToSlash(rel), reason, run.bytes)
+	}
+	return deleted, nil
+}
+
+func dirBytes(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrimRunLogsOnlyRemovesOwnRuns(t *testing.T) {
+	cfg := config{outDir: t.TempDir(), stamp: "20260310T000000Z"}
+	root := filepath.Dir(runLogDir(cfg))
+	runs := map[string]int{
+		"20260310T000000Z": 10, // current run
+		"20260309T000000Z": 600 << 10,
+		"20260308T000000Z": 600 << 10,
+		"20260307T000000Z": 10,
+		"20260306T000000Z": 10,
+		"20260201T000000Z": 10,
+	}
+	for stamp, size := range runs {
+		mustMkdirAll(t, filepath.Join(root, stamp))
+		if err := os.WriteFile(filepath.Join(root, stamp, "verify-full.log"), bytes.Repeat([]byte("x"), size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not verify-full's naming: never removed.
+	mustMkdirAll(t, filepath.Join(root, "pinned"), filepath.Join(cfg.outDir, "logs", "other-tool"))
+	if err := os.WriteFile(filepath.Join(root, "20200101T000000Z.log"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	deleted, err := trimRunLogs(cfg, retentionPolicy{keep: 4, ttlDays: 14, maxMB: 1}, now, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Fatalf("deleted=%d\n%s", deleted, buf.String())
+	}
+	for _, want := range []string{
+		"removed=logs/verify-full/20260308T000000Z reason=size",
+		"removed=logs/verify-full/20260306T000000Z reason=count",
+		"removed=logs/verify-full/20260201T000000Z reason=count",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, buf.String())
+		}
+	}
+	for _, keep := range []string{"20260310T000000Z", "20260309T000000Z", "20260307T000000Z", "pinned", "20200101T000000Z.log", "../other-tool"} {
+		if _, err := os.Stat(filepath.Join(root, keep)); err != nil {
+			t.Errorf("%s should be kept: %v", keep, err)
+		}
+	}
+
+	// Age applies even within the count limit; the current run always stays.
+	deleted, err = trimRunLogs(cfg, retentionPolicy{keep: 10, ttlDays: 0}, now.Add(time.Hour), &buf)
+	if err != nil || deleted != 2 {
+		t.Fatalf("deleted=%d err=%v\n%s", deleted, err, buf.String())
+	}
+	if _, err := os.Stat(runLogDir(cfg)); err != nil {
+		t.Fatalf("current run removed: %v", err)
+	}
+}
+
+func TestParseOptionsLogRetention(t *testing.T) {
+	env := map[string]string{envLogsKeep: "3", envLogsMaxMB: "200"}
+	opts, err := parseOptions([]string{"--ttl-logs-days", "7"}, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.retention != (retentionPolicy{keep: 3, ttlDays: 7, maxMB: 200}) {
+		t.Fatalf("retention=%+v", opts.retention)
+	}
+
+	opts, err = parseOptions([]string{"--keep-logs", "1"}, func(key string) string { return env[key] })
+	if err != nil || opts.retention.keep != 1 || opts.retention.ttlDays != defaultLogsTTLDays {
+		t.Fatalf("retention=%+v err=%v", opts.retention, err)
+	}
+
+	env[envLogsTTLDays] = "two weeks"
+	if _, err := parseOptions(nil, func(key string) string { return env[key] }); err == nil {
+		t.Fatal("expected error for invalid env value")
+	}
+}
+
+func TestApplyLogRetentionAfterFailedRun(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := config{
+		repoDir:  filepath.Join(tmp, "repo"),
+		outDir:   filepath.Join(tmp, "out"),
+		cacheDir: filepath.Join(tmp, "cache"),
+		stamp:    time.Now().UTC().Format("20060102T150405Z"),
+	}
+	mustMkdirAll(t, cfg.repoDir, cfg.outDir, cfg.cacheDir)
+	old := filepath.Join(filepath.Dir(runLogDir(cfg)), "20200101T000000Z")
+	mustMkdirAll(t, old)
+
+	// No README.md and no pipeline: the run fails.
+	if err := run(cfg, options{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected run to fail")
+	}
+	var buf bytes.Buffer
+	applyLogRetention(cfg, retentionPolicy{keep: 5, ttlDays: 14}, &buf)
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expired run kept after a failed run: %v\n%s", err, buf.String())
+	}
+	if log := mustRead(t, filepath.Join(runLogDir(cfg), "verify-full.log")); !strings.Contains(log, "OK: log_retention removed=logs/verify-full/20200101T000000Z reason=age") {
+		t.Fatalf("retention not recorded in the run log:\n%s", log)
+	}
+}
+
+func TestTrimRunLogsTrimsEvidenceBundles(t *testing.T) {
+	cfg := config{outDir: t.TempDir(), stamp: "20260310T000000Z"}
+	mustMkdirAll(t, runLogDir(cfg))
+	evidenceDir := filepath.Dir(evidencePath(cfg))
+	mustMkdirAll(t, evidenceDir)
+	for _, name := range []string{
+		"20260310T000000Z.tar.gz", // current run
+		"20260309T000000Z.tar.gz",
+		"20260308T000000Z.tar.gz",
+		"20260201T000000Z.tar.gz",
+		"notes.tar.gz",
+		"20260101T000000Z.zip",
+	} {
+		if err := os.WriteFile(filepath.Join(evidenceDir, name), []byte("bundle"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	deleted, err := trimRunLogs(cfg, retentionPolicy{keep: 2, ttlDays: 14}, now, &buf)
+	if err != nil || deleted != 2 {
+		t.Fatalf("deleted=%d err=%v\n%s", deleted, err, buf.String())
+	}
+	for _, want := range []string{
+		"removed=evidence/20260308T000000Z.tar.gz reason=count",
+		"removed=evidence/20260201T000000Z.tar.gz reason=count",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, buf.String())
+		}
+	}
+	for _, keep := range []string{"20260310T000000Z.tar.gz", "20260309T000000Z.tar.gz", "notes.tar.gz", "20260101T000000Z.zip"} {
+		if _, err := os.Stat(filepath.Join(evidenceDir, keep)); err != nil {
+			t.Errorf("%s should be kept: %v", keep, err)
+		}
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 )
@@ -25,6 +25,18 @@ type options struct {
 	githubRunID string
 	githubSHA   string
 	githubRef   string
+	retention   retentionPolicy
+	// ghaSummary only renders the job summary and step outputs from an
+	// existing status file (e.g. one written by the dry-run shell path).
+	ghaSummary bool
+	// shard is this container's slice of a sharded run; mergeShards > 0
+	// combines out/shards/<i> instead of running the pipeline.
+	shard       shardSpec
+	mergeShards int
+	// isolation is the level the container was started with; fetch runs
+	// the network-enabled phase that precedes a hermetic run.
+	isolation string
+	fetch     bool
 }
 
 func main() {
@@ -37,7 +49,7 @@ func main() {
 				githubSHA:   os.Getenv("GITHUB_SHA"),
 				githubRef:   os.Getenv("GITHUB_REF_NAME"),
 			}
-			writeErrorStatus(cfg, opts, fmt.Sprintf("panic=%v", r))
+			writeErrorStatus(cfg, opts, fmt.Sprintf("panic=%v", r), nil)
 			fmt.Printf("ERROR: verify-full panic=%v\n", r)
 			fmt.Println("STATUS: ERROR")
 		}
@@ -46,14 +58,54 @@ func main() {
 	cfg := defaultConfig()
 	opts, err := parseOptions(os.Args[1:], os.Getenv)
 	if err != nil {
-		writeErrorStatus(cfg, opts, err.Error())
+		writeErrorStatus(cfg, opts, err.Error(), nil)
+		publishGHA(cfg, os.Getenv, os.Stdout)
 		fmt.Printf("ERROR: verify-full parse_options err=%s\n", err.Error())
 		fmt.Println("STATUS: ERROR")
 		return
 	}
 
+	if opts.ghaSummary {
+		if _, err := os.Stat(filepath.Join(cfg.outDir, "verify-full.status")); err != nil {
+			publishGHA(cfg, os.Getenv, os.Stdout)
+			fmt.Println("ERROR: verify-full gha_summary reason=status_file_missing")
+			fmt.Println("STATUS: ERROR")
+			return
+		}
+		publishGHA(cfg, os.Getenv, os.Stdout)
+		fmt.Println("OK: verify-full gha_summary completed")
+		fmt.Println("STATUS: OK")
+		return
+	}
+
+	if opts.fetch {
+		if err := runFetch(cfg, os.Stdout); err != nil {
+			fmt.Printf("ERROR: verify-full fetch err=%s\n", err.Error())
+			fmt.Println("STATUS: ERROR")
+			return
+		}
+		fmt.Println("STATUS: OK")
+		return
+	}
+
 	if err := run(cfg, opts, os.Stdout); err != nil {
-		writeErrorStatus(cfg, opts, err.Error())
+		var details []string
+		var pipelineErr *pipelineError
+		var isolationErr *isolationError
+		switch {
+		case errors.As(err, &pipelineErr):
+			details = pipelineErr.details
+		case errors.As(err, &isolationErr):
+			details = isolationErr.details
+		}
+		var bundleErr *evidenceError
+		if !errors.As(err, &bundleErr) {
+			if bundle := writeErrorStatus(cfg, opts, err.Error(), details); bundle.path != "" {
+				fmt.Printf("OK: evidence bundle=%s sha256=%s files=%d\n", bundle.path, bundle.sha256, bundle.files)
+			}
+		}
+		applyLogRetention(cfg, opts.retention, os.Stdout)
+		publishGHA(cfg, os.Getenv, os.Stdout)
 		if opts.ghaSync {
 			fmt.Printf("::error::%s\n", escapeAnnotation(err.Error()))
 		}
@@ -62,6 +114,8 @@ func main() {
 		return
 	}
 
+	applyLogRetention(cfg, opts.retention, os.Stdout)
+	publishGHA(cfg, os.Getenv, os.Stdout)
 	fmt.Println("OK: verify-full completed")
 	fmt.Println("STATUS: OK")
 }
@@ -75,6 +129,17 @@ func parseOptions(args []string, getenv func(string) string) (options, error) {
 
 	dryRun := fs.Bool("dry-run", dryRunDefault, "run in dry-run mode")
 	ghaSync := fs.Bool("gha-sync", ghaSyncDefault, "emit GitHub Actions compatible annotations")
+	ghaSummary := fs.Bool("gha-summary", false, "only write $GITHUB_STEP_SUMMARY and $GITHUB_OUTPUT from the existing out/verify-full.status")
+	retention, err := retentionFromEnv(getenv)
+	if err != nil {
+		return options{}, err
+	}
+	shardRaw := fs.String("shard", getenv(envShard), "run this shard (<index>/<total>) of the go-packages steps")
+	mergeShards := fs.Int("merge-shards", 0, "merge out/shards/0..N-1 into out/verify-full.status instead of running the pipeline")
+	fetch := fs.Bool("fetch", false, "only run the fetch steps that fill /cache before a hermetic run")
+	fs.IntVar(&retention.keep, "keep-logs", retention.keep, "keep N newest out/logs/verify-full runs")
+	fs.IntVar(&retention.ttlDays, "ttl-logs-days", retention.ttlDays, "delete out/logs/verify-full runs older than N days")
+	fs.IntVar(&retention.maxMB, "max-logs-mb", retention.maxMB, "cap out/logs/verify-full at N MiB (0: no cap)")
 
 	if err := fs.Parse(args); err != nil {
 		return options{}, err
@@ -82,6 +147,23 @@ func parseOptions(args []string, getenv func(string) string) (options, error) {
 	if len(fs.Args()) > 0 {
 		return options{}, errors.New("unexpected positional arguments")
 	}
+	if retention.keep < 0 || retention.ttlDays < 0 || retention.maxMB < 0 {
+		return options{}, errors.New("log retention values must not be negative")
+	}
+	shard, err := parseShardSpec(*shardRaw)
+	if err != nil {
+		return options{}, err
+	}
+	if *mergeShards < 0 || (*mergeShards > 0 && shard.sharded()) {
+		return options{}, errors.New("--merge-shards must be positive and cannot be combined with --shard")
+	}
+	if *fetch && (*mergeShards > 0 || shard.sharded()) {
+		return options{}, errors.New("--fetch cannot be combined with --shard or --merge-shards")
+	}
+	isolation, err := requestedIsolation(getenv)
+	if err != nil {
+		return options{}, err
+	}
 
 	return options{
 		dryRun:      *dryRun,
@@ -89,6 +171,12 @@ func parseOptions(args []string, getenv func(string) string) (options, error) {
 		githubRunID: getenv("GITHUB_RUN_ID"),
 		githubSHA:   getenv("GITHUB_SHA"),
 		githubRef:   getenv("GITHUB_REF_NAME"),
+		retention:   retention,
+		ghaSummary:  *ghaSummary,
+		shard:       shard,
+		mergeShards: *mergeShards,
+		isolation:   isolation,
+		fetch:       *fetch,
 	}, nil
 }
 
@@ -105,12 +193,13 @@ func run(cfg config, opts options, stdout io.Writer) error {
 		return fmt.Errorf(missingDirFmt, cfg.cacheDir)
 	}
 
-	logDir := filepath.Join(cfg.outDir, "logs")
+	// Each run keeps its logs together so log retention trims whole runs.
+	logDir := runLogDir(cfg)
 	if err := os.MkdirAll(logDir, 0o755); err != nil {
 		return fmt.Errorf("mkdir logs: %w", err)
 	}
 
-	logFilePath := filepath.Join(logDir, "verify-full-"+cfg.stamp+".log")
+	logFilePath := filepath.Join(logDir, "verify-full.log")
 	logFile, err := os.Create(logFilePath)
 	if err != nil {
 		return fmt.Errorf("create log file: %w", err)
@@ -128,23 +217,76 @@ func run(cfg config, opts options, stdout io.Writer) error {
 	fmt.Fprintf(writer, "OK: mode=%s\n", modeValue(opts.dryRun))
 	fmt.Fprintf(writer, "OK: gha_sync=%t\n", opts.ghaSync)
 
-	if !opts.dryRun {
-		if _, err := os.Stat(filepath.Join(cfg.repoDir, "README.md")); err != nil {
-			return fmt.Errorf("README.md not found in %s", cfg.repoDir)
+	isolation := probeIsolation(opts.isolation, envOr("VERIFY_FULL_PROC_DIR", "/proc"), net.Interfaces)
+	details := isolationDetails(isolation)
+	if opts.isolation == isolationHermetic {
+		details = append(details, "isolation_fetch="+fetchStatus(cfg))
+		if err := copyFetchLogs(cfg, logDir); err != nil {
+			return fmt.Errorf("copy fetch logs: %w", err)
 		}
-	} else {
-		fmt.Fprintln(writer, "SKIP: readme_check reason=dry_run (dry-run enabled)")
 	}
+	if missing := isolation.violations(); len(missing) > 0 {
+		fmt.Fprintf(writer, "ERROR: %s\n", isolation)
+		return &isolationError{missing: missing, details: details}
+	}
+	fmt.Fprintf(writer, "OK: %s\n", isolation)
 
-	if err := writeStatus(cfg, opts, "OK", ""); err != nil {
-		return fmt.Errorf("write status: %w", err)
+	configPath := pipelineConfigPath(cfg.repoDir)
+	pipeline, err := loadPipelineConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("pipeline config: %w", err)
+	}
+	switch {
+	case opts.mergeShards > 0:
+		fmt.Fprintf(writer, "OK: merge_shards total=%d\n", opts.mergeShards)
+		merged, err := mergeShards(cfg, opts.mergeShards, writer)
+		details = append(details, merged...)
+		if err != nil {
+			var pipelineErr *pipelineError
+			if errors.As(err, &pipelineErr) {
+				pipelineErr.details = details
+			}
+			return err
+		}
+	case pipeline == nil:
+		// Without a declared pipeline only the repo mount is checked.
+		fmt.Fprintf(writer, "SKIP: pipeline reason=config_missing path=%s\n", configPath)
+		if !opts.dryRun {
+			if _, err := os.Stat(filepath.Join(cfg.repoDir, "README.md")); err != nil {
+				return fmt.Errorf("README.md not found in %s", cfg.repoDir)
+			}
+		} else {
+			fmt.Fprintln(writer, "SKIP: readme_check reason=dry_run (dry-run enabled)")
+		}
+	case opts.dryRun:
+		fmt.Fprintf(writer, "OK: pipeline config=%s steps=%d\n", configPath, len(pipeline.Steps))
+		details = append(details, "pipeline="+configPath, fmt.Sprintf("steps_total=%d", len(pipeline.Steps)))
+		for _, step := range pipeline.Steps {
+			fmt.Fprintf(writer, "SKIP: step=%s reason=dry_run run=%s\n", step.Name, step.Run)
+			details = append(details, fmt.Sprintf("step=%s status=SKIP reason=dry_run", step.Name))
+		}
+	default:
+		fmt.Fprintf(writer, "OK: pipeline config=%s steps=%d\n", configPath, len(pipeline.Steps))
+		stepDetails, err := runPipeline(cfg, *pipeline, opts.shard, logDir, writer)
+		details = append(append(details, "pipeline="+configPath), stepDetails...)
+		if err != nil {
+			var pipelineErr *pipelineError
+			if errors.As(err, &pipelineErr) {
+				pipelineErr.details = details
+			}
+			return err
+		}
 	}
-	deleted, gcErr := trimLogs(filepath.Join(cfg.outDir, "logs"), 5)
-	if gcErr != nil {
-		fmt.Fprintf(writer, "ERROR: auto_gc_logs err=%s\n", gcErr.Error())
-	} else {
-		fmt.Fprintf(writer, "OK: auto_gc_logs deleted=%d keep=5\n", deleted)
+
+	bundle, err := writeStatus(cfg, opts, "OK", "", details)
+	if err != nil {
+		var bundleErr *evidenceError
+		if errors.As(err, &bundleErr) {
+			return err
+		}
+		return fmt.Errorf("write status: %w", err)
 	}
+	fmt.Fprintf(writer, "OK: evidence bundle=%s sha256=%s files=%d\n", bundle.path, bundle.sha256, bundle.files)
 
 	fmt.Fprintln(writer, "OK: verify-full completed")
 	if opts.ghaSync {
@@ -162,11 +304,13 @@ func defaultConfig() config {
 	}
 }
 
-func writeErrorStatus(cfg config, opts options, reason string) {
+// writeErrorStatus returns the evidence bundle when one could be written.
+func writeErrorStatus(cfg config, opts options, reason string, details []string) evidenceBundle {
 	if _, err := os.Stat(cfg.outDir); err != nil {
-		return
+		return evidenceBundle{}
 	}
-	_ = writeStatus(cfg, opts, "ERROR", reason)
+	bundle, _ := writeStatus(cfg, opts, "ERROR", reason, details)
+	return bundle
 }
 
 func requireDir(path string) error {
@@ -204,7 +348,53 @@ func modeValue(dryRun bool) string {
 	return "full"
 }
 
-func writeStatus(cfg config, opts options, status, reason string) error {
+// writeStatus writes the status file, packs it into the evidence bundle and
+// appends bundle_sha256. verify-full.status.json mirrors the final text and
+// is appended to the run history. When the bundle fails the status is
+// rewritten as ERROR (with bundle_error=) and an *evidenceError is returned.
+func writeStatus(cfg config, opts options, status, reason string, details []string) (evidenceBundle, error) {
+	if err := writeStatusFile(cfg, opts, status, reason, details); err != nil {
+		return evidenceBundle{}, err
+	}
+	bundle, err := writeEvidenceBundle(cfg, opts)
+	if err != nil {
+		bundleErr := &evidenceError{err: err}
+		details = append(details[:len(details):len(details)], "bundle_error="+err.Error())
+		if status != "ERROR" {
+			status, reason = "ERROR", bundleErr.Error()
+		}
+		if writeErr := writeStatusFile(cfg, opts, status, reason, details); writeErr != nil {
+			return evidenceBundle{}, writeErr
+		}
+		if histErr := appendHistory(cfg); histErr != nil {
+			return evidenceBundle{}, histErr
+		}
+		return evidenceBundle{}, bundleErr
+	}
+	lines := []string{
+		fmt.Sprintf("OK: bundle=%s sha256=%s", bundle.path, bundle.sha256),
+		"bundle=" + bundle.path,
+		"bundle_sha256=" + bundle.sha256,
+		fmt.Sprintf("bundle_files=%d", bundle.files),
+	}
+	f, err := os.OpenFile(filepath.Join(cfg.outDir, "verify-full.status"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return bundle, err
+	}
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		f.Close()
+		return bundle, err
+	}
+	if err := f.Close(); err != nil {
+		return bundle, err
+	}
+	if err := syncStatusJSON(cfg); err != nil {
+		return bundle, err
+	}
+	return bundle, appendHistory(cfg)
+}
+
+func writeStatusFile(cfg config, opts options, status, reason string, details []string) error {
 	head := "OK"
 	switch strings.ToUpper(status) {
 	case "ERROR":
@@ -231,48 +421,18 @@ func writeStatus(cfg config, opts options, status, reason string) error {
 		lines = append(lines, "OK: github_ref="+opts.githubRef)
 		lines = append(lines, "github_ref="+opts.githubRef)
 	}
+	lines = append(lines, details...)
 	if reason != "" {
 		lines = append(lines, "ERROR: reason="+reason)
 		lines = append(lines, "reason="+reason)
 	}
 	content := strings.Join(lines, "\n") + "\n"
-	return os.WriteFile(filepath.Join(cfg.outDir, "verify-full.status"), []byte(content), 0o644)
+	if err := os.WriteFile(filepath.Join(cfg.outDir, "verify-full.status"), []byte(content), 0o644); err != nil {
+		return err
+	}
+	return syncStatusJSON(cfg)
 }
 
 func escapeAnnotation(message string) string {
 	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(message)
 }
-
-func trimLogs(logDir string, keep int) (int, error) {
-	entries, err := os.ReadDir(logDir)
-	if err != nil {
-		return 0, err
-	}
-	type item struct {
-		path    string
-		modTime time.Time
-	}
-	items := []item{}
-	for _, entry := range entries {
-		info, infoErr := entry.Info()
-		if infoErr != nil {
-			continue
-		}
-		items = append(items, item{
-			path:    filepath.Join(logDir, entry.Name()),
-			modTime: info.ModTime(),
-		})
-	}
-	sort.Slice(items, func(i, j int) bool { return items[i].modTime.After(items[j].modTime) })
-	if len(items) <= keep {
-		return 0, nil
-	}
-	deleted := 0
-	for _, old := range items[keep:] {
-		if removeErr := os.RemoveAll(old.path); removeErr != nil {
-			return deleted, removeErr
-		}
-		deleted++
-	}
-	return deleted, nil
-}
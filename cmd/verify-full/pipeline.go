@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPipelineConfig = ".ci-self/verify-full.json"
+	defaultStepTimeoutSec = 1800
+	failureTailLines      = 20
+)
+
+// pipelineConfig is the repo-declared list of commands verify-full runs
+// inside the container, in order.
+type pipelineConfig struct {
+	// FailFast stops at the first failed step; the rest are recorded as SKIP.
+	FailFast bool           `json:"fail_fast"`
+	Steps    []pipelineStep `json:"steps"`
+	// Fetch runs in the network-enabled fetch phase of a hermetic run to
+	// fill /cache before the isolated container starts.
+	Fetch []pipelineStep `json:"fetch"`
+}
+
+// pipelineStep runs either argv (a JSON array, executed directly) or a shell
+// line (a JSON string, executed with sh -c).
+type pipelineStep struct {
+	Name       string            `json:"name"`
+	Run        stepCommand       `json:"run"`
+	Env        map[string]string `json:"env"`
+	TimeoutSec int               `json:"timeout_sec"`
+	Dir        string            `json:"dir"`
+	// Shard "go-packages" splits the step's Go packages across the shards
+	// of a sharded run (VERIFY_FULL_SHARD_PACKAGES); other steps run on
+	// shard 0 only.
+	Shard string `json:"shard"`
+}
+
+type stepCommand struct {
+	argv  []string
+	shell string
+}
+
+func (c *stepCommand) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.shell); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &c.argv); err != nil {
+		return errors.New("run must be a string or an array of strings")
+	}
+	return nil
+}
+
+func (c stepCommand) empty() bool {
+	return strings.TrimSpace(c.shell) == "" && len(c.argv) == 0
+}
+
+func (c stepCommand) String() string {
+	if c.shell != "" {
+		return c.shell
+	}
+	return strings.Join(c.argv, " ")
+}
+
+var stepNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// pipelineConfigPath is relative to the repo unless VERIFY_FULL_CONFIG is
+// absolute.
+func pipelineConfigPath(repoDir string) string {
+	path := envOr("VERIFY_FULL_CONFIG", defaultPipelineConfig)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(repoDir, path)
+}
+
+// loadPipelineConfig returns nil when the repo declares no pipeline.
+func loadPipelineConfig(path string) (*pipelineConfig, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg pipelineConfig
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(cfg.Steps) == 0 {
+		return nil, fmt.Errorf("%s declares no steps", path)
+	}
+	seen := map[string]bool{}
+	for _, list := range []struct {
+		key   string
+		steps []pipelineStep
+	}{{"steps", cfg.Steps}, {"fetch", cfg.Fetch}} {
+		for i, step := range list.steps {
+			switch {
+			case !stepNamePattern.MatchString(step.Name):
+				return nil, fmt.Errorf("%s: %s[%d] name %q must match %s", path, list.key, i, step.Name, stepNamePattern)
+			case seen[step.Name]:
+				return nil, fmt.Errorf("%s: duplicate step name %q", path, step.Name)
+			case step.Run.empty():
+				return nil, fmt.Errorf("%s: step %s has no run command", path, step.Name)
+			case step.TimeoutSec < 0:
+				return nil, fmt.Errorf("%s: step %s timeout_sec must be positive", path, step.Name)
+			case filepath.IsAbs(step.Dir) || !filepath.IsLocal(filepath.Clean("./"+step.Dir)):
+				return nil, fmt.Errorf("%s: step %s dir must stay inside the repository", path, step.Name)
+			case step.Shard != "" && (list.key == "fetch" || step.Shard != shardGoPackages):
+				return nil, fmt.Errorf("%s: step %s shard must be %q (steps only)", path, step.Name, shardGoPackages)
+			}
+			seen[step.Name] = true
+		}
+	}
+	return &cfg, nil
+}
+
+// cacheEnv points the language caches at the /cache volume so repeat runs
+// start warm. Values already set in the container or the step win.
+func cacheEnv(cacheDir string) []string {
+	pairs := [][2]string{
+		{"GOCACHE", "go-build"},
+		{"GOMODCACHE", "go-mod"},
+		{"npm_config_cache", "npm"},
+		{"npm_config_store_dir", "pnpm-store"},
+		{"YARN_CACHE_FOLDER", "yarn"},
+		{"PIP_CACHE_DIR", "pip"},
+		{"CARGO_HOME", "cargo"},
+		{"XDG_CACHE_HOME", "xdg"},
+	}
+	env := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		if os.Getenv(p[0]) == "" {
+			env = append(env, p[0]+"="+filepath.Join(cacheDir, p[1]))
+		}
+	}
+	return env
+}
+
+type stepResult struct {
+	name       string
+	status     string
+	exitCode   int
+	durationMS int64
+	logPath    string
+	reason     string
+	usage      *stepUsage
+	goCache    *goCacheStats
+	// shardPackages is set for "go-packages" steps.
+	shardPackages *int
+}
+
+// stepProbes are the measurements taken around every step.
+type stepProbes struct {
+	usage    usageProbe
+	interval time.Duration
+	goCache  *goCacheProbe
+}
+
+func (r stepResult) detail(outDir string) string {
+	line := fmt.Sprintf("step=%s status=%s", r.name, r.status)
+	if r.status != "SKIP" {
+		line += fmt.Sprintf(" exit_code=%d duration_ms=%d", r.exitCode, r.durationMS)
+	}
+	if r.usage != nil {
+		line += r.usage.fields()
+	}
+	if r.goCache != nil {
+		line += r.goCache.fields()
+	}
+	if r.shardPackages != nil {
+		line += fmt.Sprintf(" shard_packages=%d", *r.shardPackages)
+	}
+	if r.logPath != "" {
+		if rel, err := filepath.Rel(outDir, r.logPath); err == nil {
+			line += " log=" + filepath.ToSlash(rel)
+		}
+	}
+	if r.reason != "" {
+		line += " reason=" + r.reason
+	}
+	return line
+}
+
+// pipelineError carries the per-step status lines so the ERROR status file
+// keeps them.
+type pipelineError struct {
+	failed  []string
+	details []string
+}
+
+func (e *pipelineError) Error() string {
+	return "pipeline failed: " + strings.Join(e.failed, ", ")
+}
+
+// runPipeline runs every step and returns the status lines. A failed step
+// does not stop the pipeline unless fail_fast is set.
+func runPipeline(cfg config, pipeline pipelineConfig, shard shardSpec, runLogDir string, writer io.Writer) ([]string, error) {
+	if err := os.MkdirAll(runLogDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir step logs: %w", err)
+	}
+	env := append(os.Environ(), cacheEnv(cfg.cacheDir)...)
+	probes := stepProbes{usage: newUsageProbe(), interval: sampleInterval()}
+	if probes.usage.source == "" {
+		fmt.Fprintln(writer, "SKIP: resource_usage reason=no_cgroup_v2_or_proc")
+	}
+	volumeExisted := markCacheVolume(cfg.cacheDir)
+	gocacheWarm := goCacheHasEntries(goCacheDir(cfg.cacheDir))
+	goProbe, err := newGoCacheProbe()
+	switch {
+	case err != nil:
+		fmt.Fprintf(writer, "SKIP: go_cache reason=shim_failed err=%s\n", err.Error())
+	case goProbe == nil:
+		fmt.Fprintln(writer, "SKIP: go_cache reason=go_not_found")
+	default:
+		defer goProbe.close()
+		probes.goCache = goProbe
+		env = append(env, goProbe.env()...)
+	}
+	diskBefore := map[string]int64{"cache": diskBytes(cfg.cacheDir), "out": diskBytes(cfg.outDir)}
+	start := time.Now()
+
+	details := []string{
+		fmt.Sprintf("steps_total=%d", len(pipeline.Steps)),
+		fmt.Sprintf("cpus=%d", runtime.NumCPU()),
+	}
+	if shard.sharded() {
+		details = append(details, "shard_spec="+shard.String())
+	}
+	var timings map[string]float64
+	var failed []string
+	var goTotal goCacheStats
+	for i, step := range pipeline.Steps {
+		var result stepResult
+		logPath := filepath.Join(runLogDir, fmt.Sprintf("%02d-%s.log", i+1, step.Name))
+		switch {
+		case pipeline.FailFast && len(failed) > 0:
+			result = stepResult{name: step.Name, status: "SKIP", reason: "fail_fast"}
+			fmt.Fprintf(writer, "SKIP: step=%s reason=fail_fast\n", step.Name)
+		case shard.sharded() && step.Shard == "" && shard.index != 0:
+			result = stepResult{name: step.Name, status: "SKIP", reason: "shard_0_only"}
+			fmt.Fprintf(writer, "SKIP: step=%s reason=shard_0_only\n", step.Name)
+		case step.Shard == shardGoPackages:
+			if timings == nil {
+				var err error
+				if timings, err = loadShardTimings(shardTimingsPath(cfg)); err != nil {
+					fmt.Fprintf(writer, "SKIP: shard_timings reason=unreadable err=%s\n", err.Error())
+				}
+			}
+			result = runShardedStep(cfg, step, shard, timings, env, logPath, probes, writer)
+		default:
+			result = runStep(cfg, step, env, logPath, probes, writer)
+		}
+		if result.status == "ERROR" {
+			failed = append(failed, fmt.Sprintf("%s(%s)", step.Name, result.reason))
+		}
+		if result.goCache != nil {
+			goTotal.add(*result.goCache)
+		}
+		details = append(details, result.detail(cfg.outDir))
+	}
+	details = append(details,
+		fmt.Sprintf("steps_failed=%d", len(failed)),
+		fmt.Sprintf("pipeline_duration_ms=%d", time.Since(start).Milliseconds()),
+	)
+	if !goTotal.empty() {
+		details = append(details, goCacheDetails(goTotal, coldStartReason(volumeExisted, gocacheWarm, goTotal), writer)...)
+	}
+	if measured := shardedTimings(pipeline, runLogDir); len(measured) > 0 {
+		path := filepath.Join(cfg.outDir, "metrics", shardTimingsFile)
+		if err := writeShardTimings(path, measured); err != nil {
+			fmt.Fprintf(writer, "ERROR: shard_timings err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(writer, "OK: shard_timings path=%s packages=%d\n", path, len(measured))
+		}
+	}
+	for _, disk := range []struct{ name, path string }{{"cache", cfg.cacheDir}, {"out", cfg.outDir}} {
+		after := diskBytes(disk.path)
+		details = append(details, fmt.Sprintf("disk=%s path=%s before_bytes=%d after_bytes=%d delta_bytes=%d",
+			disk.name, disk.path, diskBefore[disk.name], after, after-diskBefore[disk.name]))
+	}
+	if len(failed) > 0 {
+		return details, &pipelineError{failed: failed, details: details}
+	}
+	return details, nil
+}
+
+func runStep(cfg config, step pipelineStep, env []string, logPath string, probes stepProbes, writer io.Writer) stepResult {
+	result := stepResult{name: step.Name, exitCode: -1, logPath: logPath}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		result.status, result.reason, result.logPath = "ERROR", "log_create_failed", ""
+		fmt.Fprintf(writer, "ERROR: step=%s reason=log_create_failed err=%s\n", step.Name, err.Error())
+		return result
+	}
+	defer logFile.Close()
+
+	timeoutSec := step.TimeoutSec
+	if timeoutSec == 0 {
+		timeoutSec = defaultStepTimeoutSec
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if step.Run.shell != "" {
+		cmd = exec.CommandContext(ctx, "sh", "-c", step.Run.shell)
+	} else {
+		cmd = exec.CommandContext(ctx, step.Run.argv[0], step.Run.argv[1:]...)
+	}
+	cmd.Dir = filepath.Join(cfg.repoDir, step.Dir)
+	cmd.Env = env
+	for key, value := range step.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Children of a timed-out sh -c may keep the log open; do not wait for them.
+	cmd.WaitDelay = 10 * time.Second
+
+	fmt.Fprintf(logFile, "step=%s dir=%s timeout_sec=%d\n$ %s\n", step.Name, cmd.Dir, timeoutSec, step.Run)
+	fmt.Fprintf(writer, "OK: step=%s start\n", step.Name)
+	start := time.Now()
+	sampler := startSampler(probes.usage)
+	runErr := cmd.Start()
+	if runErr == nil {
+		sampler.watch(cmd.Process.Pid, probes.interval)
+		runErr = cmd.Wait()
+	}
+	result.durationMS = time.Since(start).Milliseconds()
+	if usage := sampler.stop(); probes.usage.source != "" {
+		result.usage = &usage
+	}
+	if probes.goCache != nil {
+		stats, err := probes.goCache.collect(logPath)
+		if err != nil {
+			fmt.Fprintf(logFile, "SKIP: go_cache graph_unreadable err=%s\n", err.Error())
+		}
+		if !stats.empty() {
+			result.goCache = &stats
+		}
+	}
+	if cmd.ProcessState != nil {
+		result.exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.status, result.reason = "ERROR", fmt.Sprintf("timeout_%ds", timeoutSec)
+	case runErr != nil && result.exitCode < 0:
+		result.status, result.reason = "ERROR", "start_failed"
+		fmt.Fprintf(logFile, "ERROR: %s\n", runErr.Error())
+	case runErr != nil:
+		result.status, result.reason = "ERROR", fmt.Sprintf("exit_%d", result.exitCode)
+	default:
+		result.status = "OK"
+	}
+	fmt.Fprintf(logFile, "%s: step=%s exit_code=%d duration_ms=%d\n", result.status, step.Name, result.exitCode, result.durationMS)
+
+	if result.status == "OK" {
+		fmt.Fprintf(writer, "OK: step=%s duration_ms=%d\n", step.Name, result.durationMS)
+		return result
+	}
+	fmt.Fprintf(writer, "ERROR: step=%s reason=%s log=%s\n", step.Name, result.reason, logPath)
+	for _, line := range tailLines(logPath, failureTailLines) {
+		fmt.Fprintf(writer, "  | %s\n", line)
+	}
+	return result
+}
+
+// tailLines returns the last n lines of a log for the console.
+func tailLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pipelineFixture(t *testing.T, configJSON string) config {
+	t.Helper()
+	tmp := t.TempDir()
+	cfg := config{
+		repoDir:  filepath.Join(tmp, "repo"),
+		outDir:   filepath.Join(tmp, "out"),
+		cacheDir: filepath.Join(tmp, "cache"),
+		stamp:    "20260301T000000Z",
+	}
+	mustMkdirAll(t, filepath.Join(cfg.repoDir, ".ci-self"), filepath.Join(cfg.repoDir, "sub"), cfg.outDir, cfg.cacheDir)
+	if err := os.WriteFile(filepath.Join(cfg.repoDir, ".ci-self", "verify-full.json"), []byte(configJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VERIFY_FULL_CONFIG", "")
+	t.Setenv("GOCACHE", "")
+	return cfg
+}
+
+func TestRunPipelineAggregatesSteps(t *testing.T) {
+	cfg := pipelineFixture(t, `{
+  "steps": [
+    {"name": "env", "run": ["sh", "-c", "echo cache=$GOCACHE greeting=$GREETING; pwd"], "env": {"GREETING": "hi"}, "dir": "sub"},
+    {"name": "broken", "run": "echo boom >&2; exit 3"},
+    {"name": "slow", "run": "sleep 5", "timeout_sec": 1},
+    {"name": "after", "run": ["true"]}
+  ]
+}`)
+
+	var buf bytes.Buffer
+	err := run(cfg, options{}, &buf)
+	if err == nil || err.Error() != "pipeline failed: broken(exit_3), slow(timeout_1s)" {
+		t.Fatalf("err=%v\n%s", err, buf.String())
+	}
+	writeErrorStatus(cfg, options{}, err.Error(), err.(*pipelineError).details)
+
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{
+		"status=ERROR",
+		"steps_total=4",
+		"step=env status=OK exit_code=0",
+		"log=logs/verify-full/20260301T000000Z/01-env.log",
+		"step=broken status=ERROR exit_code=3",
+		"step=slow status=ERROR",
+		"reason=timeout_1s",
+		"step=after status=OK",
+		"steps_failed=2",
+		"reason=pipeline failed: broken(exit_3), slow(timeout_1s)",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status missing %q:\n%s", want, status)
+		}
+	}
+
+	envLog := mustRead(t, filepath.Join(runLogDir(cfg), "01-env.log"))
+	wantCache := "cache=" + filepath.Join(cfg.cacheDir, "go-build") + " greeting=hi"
+	if !strings.Contains(envLog, wantCache) || !strings.Contains(envLog, filepath.Join(cfg.repoDir, "sub")) {
+		t.Fatalf("env log:\n%s", envLog)
+	}
+	if !strings.Contains(buf.String(), "  | boom") {
+		t.Fatalf("console should show the failing log tail:\n%s", buf.String())
+	}
+}
+
+func TestRunPipelineFailFast(t *testing.T) {
+	cfg := pipelineFixture(t, `{"fail_fast": true, "steps": [
+    {"name": "first", "run": "exit 1"},
+    {"name": "second", "run": "echo never > ran"}
+  ]}`)
+
+	err := run(cfg, options{}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected pipeline failure")
+	}
+	details := strings.Join(err.(*pipelineError).details, "\n")
+	if !strings.Contains(details, "step=second status=SKIP reason=fail_fast") {
+		t.Fatalf("details:\n%s", details)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.repoDir, "ran")); !os.IsNotExist(err) {
+		t.Fatal("step after a fail_fast failure must not run")
+	}
+}
+
+func TestRunPipelineDryRunListsSteps(t *testing.T) {
+	cfg := pipelineFixture(t, `{"steps": [{"name": "test", "run": "exit 1"}]}`)
+
+	var buf bytes.Buffer
+	if err := run(cfg, options{dryRun: true}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	if !strings.Contains(status, "status=OK") || !strings.Contains(status, "step=test status=SKIP reason=dry_run") {
+		t.Fatalf("status:\n%s", status)
+	}
+}
+
+func TestLoadPipelineConfigRejectsInvalidSteps(t *testing.T) {
+	for name, body := range map[string]string{
+		"no steps":      `{"steps": []}`,
+		"bad name":      `{"steps": [{"name": "a b", "run": "true"}]}`,
+		"duplicate":     `{"steps": [{"name": "a", "run": "true"}, {"name": "a", "run": "true"}]}`,
+		"empty run":     `{"steps": [{"name": "a", "run": []}]}`,
+		"escaping dir":  `{"steps": [{"name": "a", "run": "true", "dir": "../other"}]}`,
+		"unknown field": `{"steps": [{"name": "a", "run": "true", "timeout": 5}]}`,
+		"run type":      `{"steps": [{"name": "a", "run": 5}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "verify-full.json")
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadPipelineConfig(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if cfg, err := loadPipelineConfig(filepath.Join(t.TempDir(), "missing.json")); cfg != nil || err != nil {
+		t.Fatalf("missing config: cfg=%v err=%v", cfg, err)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSampleInterval = time.Second
+	// procClockTicks is USER_HZ, which Linux fixes at 100 for /proc/<pid>/stat.
+	procClockTicks = 100
+)
+
+// usageProbe reads CPU, memory and IO counters from cgroup v2 (the whole
+// container, which during a step is that step) or, without cgroup v2, from
+// the step's process tree in /proc.
+type usageProbe struct {
+	source    string
+	cgroupDir string
+	procDir   string
+}
+
+type usageSnapshot struct {
+	at       time.Time
+	cpuUsec  uint64
+	rssBytes uint64
+	ioRead   uint64
+	ioWrite  uint64
+}
+
+// newUsageProbe picks the first readable source. VERIFY_FULL_CGROUP_DIR and
+// VERIFY_FULL_PROC_DIR exist for tests.
+func newUsageProbe() usageProbe {
+	p := usageProbe{
+		cgroupDir: envOr("VERIFY_FULL_CGROUP_DIR", "/sys/fs/cgroup"),
+		procDir:   envOr("VERIFY_FULL_PROC_DIR", "/proc"),
+	}
+	if _, ok := p.readCgroup(); ok {
+		p.source = "cgroup"
+	} else if _, err := os.Stat(filepath.Join(p.procDir, "self", "stat")); err == nil {
+		p.source = "proc"
+	}
+	return p
+}
+
+func (p usageProbe) snapshot(pid int) (usageSnapshot, bool) {
+	switch p.source {
+	case "cgroup":
+		return p.readCgroup()
+	case "proc":
+		return p.readProcTree(pid)
+	}
+	return usageSnapshot{}, false
+}
+
+func (p usageProbe) readCgroup() (usageSnapshot, bool) {
+	s := usageSnapshot{at: time.Now()}
+	cpu, ok := readKeyedValue(filepath.Join(p.cgroupDir, "cpu.stat"), "usage_usec")
+	if !ok {
+		return s, false
+	}
+	mem, err := os.ReadFile(filepath.Join(p.cgroupDir, "memory.current"))
+	if err != nil {
+		return s, false
+	}
+	s.cpuUsec = cpu
+	s.rssBytes, _ = strconv.ParseUint(strings.TrimSpace(string(mem)), 10, 64)
+	// io.stat: "<major>:<minor> rbytes=.. wbytes=.. rios=.." per device.
+	if f, err := os.Open(filepath.Join(p.cgroupDir, "io.stat")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			for _, field := range strings.Fields(scanner.Text()) {
+				key, value, _ := strings.Cut(field, "=")
+				n, _ := strconv.ParseUint(value, 10, 64)
+				switch key {
+				case "rbytes":
+					s.ioRead += n
+				case "wbytes":
+					s.ioWrite += n
+				}
+			}
+		}
+		f.Close()
+	}
+	return s, true
+}
+
+// readProcTree sums the counters of pid and all of its descendants. Children
+// that already exited are no longer visible, so this undercounts short-lived
+// processes; cgroup v2 does not have that gap.
+func (p usageProbe) readProcTree(root int) (usageSnapshot, bool) {
+	s := usageSnapshot{at: time.Now()}
+	entries, err := os.ReadDir(p.procDir)
+	if err != nil {
+		return s, false
+	}
+	type procStat struct {
+		ppid int
+		cpu  uint64
+	}
+	stats := map[int]procStat{}
+	children := map[int][]int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		ppid, cpu, ok := readProcStat(filepath.Join(p.procDir, entry.Name(), "stat"))
+		if !ok {
+			continue
+		}
+		stats[pid] = procStat{ppid: ppid, cpu: cpu}
+		children[ppid] = append(children[ppid], pid)
+	}
+	if _, ok := stats[root]; !ok {
+		return s, false
+	}
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		queue = append(queue, children[pid]...)
+		dir := filepath.Join(p.procDir, strconv.Itoa(pid))
+		s.cpuUsec += stats[pid].cpu * 1e6 / procClockTicks
+		if kb, ok := readKeyedValue(filepath.Join(dir, "status"), "VmRSS:"); ok {
+			s.rssBytes += kb << 10
+		}
+		// /proc/<pid>/io is not readable for other users' processes; skip it.
+		if n, ok := readKeyedValue(filepath.Join(dir, "io"), "read_bytes:"); ok {
+			s.ioRead += n
+		}
+		if n, ok := readKeyedValue(filepath.Join(dir, "io"), "write_bytes:"); ok {
+			s.ioWrite += n
+		}
+	}
+	return s, true
+}
+
+// readProcStat returns ppid and utime+stime (clock ticks). comm may contain
+// spaces and parentheses, so fields are counted from the last ')'.
+func readProcStat(path string) (int, uint64, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	idx := strings.LastIndexByte(string(content), ')')
+	if idx < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(content[idx+1:]))
+	if len(fields) < 13 {
+		return 0, 0, false
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	return ppid, utime + stime, true
+}
+
+// readKeyedValue returns the first number after key in a "key value" file.
+func readKeyedValue(path, key string) (uint64, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == key {
+			n, err := strconv.ParseUint(fields[1], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// stepUsage is the peak and average resource use of one pipeline step.
+type stepUsage struct {
+	source     string
+	samples    int
+	wall       time.Duration
+	cpuSeconds float64
+	cpuPeak    float64
+	rssPeak    uint64
+	rssSum     uint64
+	ioRead     uint64
+	ioWrite    uint64
+	last       usageSnapshot
+}
+
+// add folds in a snapshot. Counters only count upwards so processes leaving
+// the /proc tree do not produce negative deltas.
+func (u *stepUsage) add(s usageSnapshot) {
+	if u.samples > 0 {
+		dt := s.at.Sub(u.last.at).Seconds()
+		if s.cpuUsec > u.last.cpuUsec {
+			cpu := float64(s.cpuUsec-u.last.cpuUsec) / 1e6
+			u.cpuSeconds += cpu
+			if dt > 0 && cpu/dt > u.cpuPeak {
+				u.cpuPeak = cpu / dt
+			}
+		}
+		if s.ioRead > u.last.ioRead {
+			u.ioRead += s.ioRead - u.last.ioRead
+		}
+		if s.ioWrite > u.last.ioWrite {
+			u.ioWrite += s.ioWrite - u.last.ioWrite
+		}
+	}
+	if s.rssBytes > u.rssPeak {
+		u.rssPeak = s.rssBytes
+	}
+	u.rssSum += s.rssBytes
+	u.samples++
+	u.last = s
+}
+
+// fields renders the usage for the step status line. CPU is in cores,
+// memory and IO in MiB.
+func (u stepUsage) fields() string {
+	if u.samples == 0 {
+		return " usage=unavailable"
+	}
+	cpuAvg := 0.0
+	if u.wall > 0 {
+		cpuAvg = u.cpuSeconds / u.wall.Seconds()
+	}
+	return fmt.Sprintf(" cpu_seconds=%.2f cpu_avg=%.2f cpu_peak=%.2f rss_avg_mb=%.1f rss_peak_mb=%.1f io_read_mb=%.1f io_write_mb=%.1f samples=%d usage=%s",
+		u.cpuSeconds, cpuAvg, u.cpuPeak, mib(u.rssSum/uint64(u.samples)), mib(u.rssPeak), mib(u.ioRead), mib(u.ioWrite), u.samples, u.source)
+}
+
+func mib(n uint64) float64 {
+	return float64(n) / (1 << 20)
+}
+
+// usageSampler samples a running step until stop is called.
+type usageSampler struct {
+	probe usageProbe
+	pid   int
+	usage stepUsage
+	done  chan struct{}
+	wg    sync.WaitGroup
+	start time.Time
+}
+
+// startSampler takes the cgroup baseline before the command starts; the
+// /proc tree needs the pid, so it begins with the first tick.
+func startSampler(probe usageProbe) *usageSampler {
+	s := &usageSampler{probe: probe, usage: stepUsage{source: probe.source}, done: make(chan struct{}), start: time.Now()}
+	if probe.source == "cgroup" {
+		if snap, ok := probe.snapshot(0); ok {
+			s.usage.add(snap)
+		}
+	}
+	return s
+}
+
+func (s *usageSampler) watch(pid int, interval time.Duration) {
+	s.pid = pid
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		if snap, ok := s.probe.snapshot(pid); ok && s.probe.source == "proc" {
+			s.usage.add(snap)
+		}
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if snap, ok := s.probe.snapshot(pid); ok {
+					s.usage.add(snap)
+				}
+			}
+		}
+	}()
+}
+
+// stop ends sampling. cgroup counters stay valid after the step exits, so
+// they get a final snapshot.
+func (s *usageSampler) stop() stepUsage {
+	close(s.done)
+	s.wg.Wait()
+	if s.probe.source == "cgroup" {
+		if snap, ok := s.probe.snapshot(0); ok {
+			s.usage.add(snap)
+		}
+	}
+	s.usage.wall = time.Since(s.start)
+	return s.usage
+}
+
+func sampleInterval() time.Duration {
+	if ms, err := strconv.Atoi(os.Getenv("VERIFY_FULL_SAMPLE_MS")); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultSampleInterval
+}
+
+// diskBytes is the apparent size of a tree; unreadable entries are skipped
+// so a partly unreadable cache still gets a number.
+func diskBytes(root string) int64 {
+	var total int64
+	_ = filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
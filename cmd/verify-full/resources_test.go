@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPipelineRecordsCgroupUsage(t *testing.T) {
+	cgroup := t.TempDir()
+	for name, content := range map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 900000\n",
+		"memory.current": "104857600\n",
+		"io.stat":        "8:0 rbytes=1048576 wbytes=0 rios=1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(cgroup, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The step advances the fake counters the way a real workload would.
+	cfg := pipelineFixture(t, `{"steps": [{"name": "work", "run": "printf 'usage_usec 4000000\n' > \"$CG/cpu.stat\"; printf '8:0 rbytes=3145728 wbytes=2097152\n' > \"$CG/io.stat\"; printf '314572800\n' > \"$CG/memory.current\""}]}`)
+	t.Setenv("CG", cgroup)
+	t.Setenv("VERIFY_FULL_CGROUP_DIR", cgroup)
+
+	if err := run(cfg, options{}, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{
+		"cpu_seconds=3.00",
+		"rss_peak_mb=300.0",
+		"io_read_mb=2.0 io_write_mb=2.0",
+		"usage=cgroup",
+		"disk=cache path=" + cfg.cacheDir,
+		"disk=out path=" + cfg.outDir,
+		"pipeline_duration_ms=",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status missing %q:\n%s", want, status)
+		}
+	}
+
+	var doc struct {
+		Status string           `json:"status"`
+		CPUs   float64          `json:"cpus"`
+		Bundle string           `json:"bundle_sha256"`
+		Steps  []map[string]any `json:"steps"`
+		Disk   []map[string]any `json:"disk"`
+	}
+	raw, err := os.ReadFile(filepath.Join(cfg.outDir, "verify-full.status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Status != "OK" || doc.CPUs != float64(runtime.NumCPU()) || doc.Bundle == "" || len(doc.Steps) != 1 || len(doc.Disk) != 2 {
+		t.Fatalf("status json: %s", raw)
+	}
+	if doc.Steps[0]["cpu_seconds"] != 3.0 || doc.Steps[0]["usage"] != "cgroup" {
+		t.Fatalf("step json: %v", doc.Steps[0])
+	}
+
+	history := mustRead(t, filepath.Join(cfg.outDir, "metrics", "verify-full.jsonl"))
+	if strings.Count(history, "\n") != 1 || !strings.Contains(history, `"bundle_sha256"`) {
+		t.Fatalf("history:\n%s", history)
+	}
+}
+
+func TestPipelineSamplesProcTree(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc not available")
+	}
+	cfg := pipelineFixture(t, `{"steps": [{"name": "spin", "run": "i=0; while [ $i -lt 300000 ]; do i=$((i+1)); done"}]}`)
+	t.Setenv("VERIFY_FULL_CGROUP_DIR", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("VERIFY_FULL_SAMPLE_MS", "20")
+
+	if err := run(cfg, options{}, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	if !strings.Contains(status, "usage=proc") || strings.Contains(status, "samples=1 ") || strings.Contains(status, "rss_peak_mb=0.0") {
+		t.Fatalf("status:\n%s", status)
+	}
+}
+
+func TestStatusJSONKeepsReasonsAndIDs(t *testing.T) {
+	raw, err := statusJSON(strings.Join([]string{
+		"OK: verify-full status=ERROR mode=full",
+		"status=ERROR",
+		"github_run_id=123",
+		"steps_total=2",
+		"step=a status=ERROR exit_code=3 duration_ms=5 log=logs/verify-full/x/01-a.log reason=exit_3",
+		"reason=pipeline failed: a(exit_3), b(timeout_1s)",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	step := doc["steps"].([]any)[0].(map[string]any)
+	if doc["github_run_id"] != "123" || doc["steps_total"] != 2.0 || doc["reason"] != "pipeline failed: a(exit_3), b(timeout_1s)" ||
+		step["exit_code"] != 3.0 || step["reason"] != "exit_3" || step["log"] != "logs/verify-full/x/01-a.log" {
+		t.Fatalf("json: %s", raw)
+	}
+}
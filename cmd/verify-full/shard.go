@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// envShard is set per container by run_verify_full.sh ("<index>/<total>",
+	// index from 0).
+	envShard = "VERIFY_FULL_SHARD"
+	// envShardTimings points at the merged package timings of earlier runs.
+	envShardTimings = "VERIFY_FULL_SHARD_TIMINGS"
+	// envShardPackages is the step env that lists this shard's packages.
+	envShardPackages = "VERIFY_FULL_SHARD_PACKAGES"
+
+	// shardGoPackages is the only sharding mode: the step's `go list ./...`
+	// packages are split across shards.
+	shardGoPackages = "go-packages"
+
+	shardTimingsFile = "verify-full-shard-timings.json"
+	shardListTimeout = 5 * time.Minute
+)
+
+// shardSpec is this container's slice of a sharded run. The zero value (and
+// total 1) means the run is not sharded.
+type shardSpec struct {
+	index int
+	total int
+}
+
+func (s shardSpec) sharded() bool {
+	return s.total > 1
+}
+
+func (s shardSpec) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.total)
+}
+
+func parseShardSpec(raw string) (shardSpec, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return shardSpec{total: 1}, nil
+	}
+	indexRaw, totalRaw, ok := strings.Cut(raw, "/")
+	index, indexErr := strconv.Atoi(indexRaw)
+	total, totalErr := strconv.Atoi(totalRaw)
+	if !ok || indexErr != nil || totalErr != nil || total < 1 || index < 0 || index >= total {
+		return shardSpec{}, fmt.Errorf("shard must be <index>/<total> with 0 <= index < total: %q", raw)
+	}
+	return shardSpec{index: index, total: total}, nil
+}
+
+// shardTimingsPath is where this run reads earlier package timings from:
+// the merged file under out/metrics unless the launcher mounted one.
+func shardTimingsPath(cfg config) string {
+	return envOr(envShardTimings, filepath.Join(cfg.outDir, "metrics", shardTimingsFile))
+}
+
+// loadShardTimings returns package -> seconds; a missing file is empty.
+func loadShardTimings(path string) (map[string]float64, error) {
+	timings := map[string]float64{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return timings, nil
+	}
+	if err != nil {
+		return timings, err
+	}
+	if err := json.Unmarshal(content, &timings); err != nil {
+		return map[string]float64{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return timings, nil
+}
+
+// writeShardTimings overlays timings on the file at path.
+func writeShardTimings(path string, timings map[string]float64) error {
+	merged, err := loadShardTimings(path)
+	if err != nil {
+		merged = map[string]float64{}
+	}
+	for pkg, seconds := range timings {
+		merged[pkg] = seconds
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(content, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// planShards splits packages into total shards with the longest-first
+// greedy rule. Packages without a timing weigh the mean of the known ones
+// (1s when nothing is known). Every container computes the same plan from
+// the same inputs, so no coordination is needed.
+func planShards(packages []string, timings map[string]float64, total int) [][]string {
+	known, sum := 0, 0.0
+	for _, pkg := range packages {
+		if t, ok := timings[pkg]; ok && t > 0 {
+			known++
+			sum += t
+		}
+	}
+	fallback := 1.0
+	if known > 0 {
+		fallback = sum / float64(known)
+	}
+	weight := func(pkg string) float64 {
+		if t, ok := timings[pkg]; ok && t > 0 {
+			return t
+		}
+		return fallback
+	}
+
+	ordered := append([]string(nil), packages...)
+	sort.Slice(ordered, func(i, j int) bool {
+		wi, wj := weight(ordered[i]), weight(ordered[j])
+		if wi != wj {
+			return wi > wj
+		}
+		return ordered[i] < ordered[j]
+	})
+	shards := make([][]string, total)
+	loads := make([]float64, total)
+	for _, pkg := range ordered {
+		target := 0
+		for i := 1; i < total; i++ {
+			if loads[i] < loads[target] {
+				target = i
+			}
+		}
+		shards[target] = append(shards[target], pkg)
+		loads[target] += weight(pkg)
+	}
+	for _, shard := range shards {
+		sort.Strings(shard)
+	}
+	return shards
+}
+
+// listGoPackages runs `go list ./...` where the step runs.
+func listGoPackages(dir string, env []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shardListTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", "list", "./...")
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("go list: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// goTestTiming matches the per-package go test summary with its elapsed
+// time; "(cached)" lines carry no timing and keep the old value.
+var goTestTiming = regexp.MustCompile(`^(?:ok|FAIL)\s+(\S+)\s+([0-9.]+)s\b`)
+
+// packageTimings reads go test package durations from a step log.
+func packageTimings(logPath string) map[string]float64 {
+	timings := map[string]float64{}
+	f, err := os.Open(logPath)
+	if err != nil {
+		return timings
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if m := goTestTiming.FindStringSubmatch(scanner.Text()); m != nil {
+			if seconds, err := strconv.ParseFloat(m[2], 64); err == nil {
+				timings[m[1]] = seconds
+			}
+		}
+	}
+	return timings
+}
+
+// runShardedStep runs a "go-packages" step on this shard's packages. With
+// no sharding the shard is every package, so the same step works both ways.
+func runShardedStep(cfg config, step pipelineStep, shard shardSpec, timings map[string]float64, env []string, logPath string, probes stepProbes, writer io.Writer) stepResult {
+	if shard.total < 1 {
+		shard = shardSpec{total: 1}
+	}
+	packages, err := listGoPackages(filepath.Join(cfg.repoDir, step.Dir), append(env[:len(env):len(env)], stepEnv(step)...))
+	if err != nil {
+		fmt.Fprintf(writer, "ERROR: step=%s reason=shard_list_failed err=%s\n", step.Name, err.Error())
+		return stepResult{name: step.Name, status: "ERROR", exitCode: -1, reason: "shard_list_failed"}
+	}
+	mine := planShards(packages, timings, shard.total)[shard.index]
+	count := len(mine)
+	if count == 0 {
+		fmt.Fprintf(writer, "SKIP: step=%s reason=shard_empty shard=%s\n", step.Name, shard)
+		return stepResult{name: step.Name, status: "SKIP", reason: "shard_empty", shardPackages: &count}
+	}
+	fmt.Fprintf(writer, "OK: step=%s shard=%s packages=%d of=%d\n", step.Name, shard, count, len(packages))
+	sharded := step
+	sharded.Env = map[string]string{envShardPackages: strings.Join(mine, " ")}
+	for key, value := range step.Env {
+		sharded.Env[key] = value
+	}
+	result := runStep(cfg, sharded, env, logPath, probes, writer)
+	result.shardPackages = &count
+	return result
+}
+
+func stepEnv(step pipelineStep) []string {
+	env := make([]string, 0, len(step.Env))
+	for key, value := range step.Env {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// shardedTimings collects the go test package timings of this run's
+// "go-packages" steps.
+func shardedTimings(pipeline pipelineConfig, runLogDir string) map[string]float64 {
+	timings := map[string]float64{}
+	for i, step := range pipeline.Steps {
+		if step.Shard != shardGoPackages {
+			continue
+		}
+		for pkg, seconds := range packageTimings(filepath.Join(runLogDir, fmt.Sprintf("%02d-%s.log", i+1, step.Name))) {
+			timings[pkg] = seconds
+		}
+	}
+	return timings
+}
+
+// shardDir is where shard i of a sharded run keeps its own out/ tree.
+func shardDir(cfg config, index int) string {
+	return filepath.Join(cfg.outDir, "shards", strconv.Itoa(index))
+}
+
+// mergeShards combines out/shards/<i>/verify-full.status into the run's
+// details and folds the shards' package timings into out/metrics. A shard
+// that failed or left no status fails the merged run.
+func mergeShards(cfg config, total int, writer io.Writer) ([]string, error) {
+	details := []string{fmt.Sprintf("shards_total=%d", total)}
+	var failed []string
+	var stepLines []string
+	maxMS, minMS := 0.0, math.Inf(1)
+	timings := map[string]float64{}
+	for i := 0; i < total; i++ {
+		dir := shardDir(cfg, i)
+		content, err := os.ReadFile(filepath.Join(dir, "verify-full.status"))
+		if err != nil {
+			fmt.Fprintf(writer, "ERROR: shard=%d reason=status_missing\n", i)
+			details = append(details, fmt.Sprintf("shard=%d status=ERROR reason=status_missing", i))
+			failed = append(failed, fmt.Sprintf("shard_%d(status_missing)", i))
+			continue
+		}
+		doc := parseSummaryStatus(string(content))
+		status := doc.values["status"]
+		if status == "" {
+			status = "ERROR"
+		}
+		line := fmt.Sprintf("shard=%d status=%s", i, status)
+		if ms, err := strconv.ParseFloat(doc.values["pipeline_duration_ms"], 64); err == nil {
+			line += fmt.Sprintf(" duration_ms=%.0f", ms)
+			maxMS, minMS = math.Max(maxMS, ms), math.Min(minMS, ms)
+		}
+		if sha := doc.values["bundle_sha256"]; sha != "" {
+			line += " bundle_sha256=" + sha
+		}
+		line += fmt.Sprintf(" status_path=shards/%d/verify-full.status", i)
+		if reason := doc.values["reason"]; reason != "" {
+			line += " reason=" + reason
+		}
+		details = append(details, line)
+		fmt.Fprintf(writer, "%s: shard=%d status=%s\n", statusHead(status), i, status)
+		if status == "ERROR" {
+			failed = append(failed, fmt.Sprintf("shard_%d(%s)", i, doc.values["reason"]))
+		}
+		for _, raw := range strings.Split(string(content), "\n") {
+			if name, rest, ok := strings.Cut(strings.TrimSpace(raw), " "); ok && strings.HasPrefix(name, "step=") {
+				stepLines = append(stepLines, fmt.Sprintf("%s shard=%d %s", name, i, rejoinShardLog(rest, i)))
+			}
+		}
+		shardTimings, err := loadShardTimings(filepath.Join(dir, "metrics", shardTimingsFile))
+		if err != nil {
+			fmt.Fprintf(writer, "SKIP: shard=%d timings reason=unreadable err=%s\n", i, err.Error())
+		}
+		for pkg, seconds := range shardTimings {
+			timings[pkg] = seconds
+		}
+	}
+	details = append(details, stepLines...)
+	details = append(details, fmt.Sprintf("shards_failed=%d", len(failed)))
+	if maxMS > 0 {
+		// The run takes as long as its slowest shard.
+		details = append(details,
+			fmt.Sprintf("pipeline_duration_ms=%.0f", maxMS),
+			fmt.Sprintf("shard_spread_ms=%.0f", maxMS-minMS))
+	}
+	if len(timings) > 0 {
+		path := filepath.Join(cfg.outDir, "metrics", shardTimingsFile)
+		if err := writeShardTimings(path, timings); err != nil {
+			fmt.Fprintf(writer, "ERROR: shard_timings err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(writer, "OK: shard_timings path=%s packages=%d\n", path, len(timings))
+			details = append(details, fmt.Sprintf("shard_timings_packages=%d", len(timings)))
+		}
+	}
+	if len(failed) > 0 {
+		return details, &pipelineError{failed: failed, details: details}
+	}
+	return details, nil
+}
+
+// rejoinShardLog makes a shard's log= path relative to the merged out/.
+func rejoinShardLog(fields string, index int) string {
+	return strings.Replace(fields, " log=logs/", fmt.Sprintf(" log=shards/%d/logs/", index), 1)
+}
+
+func statusHead(status string) string {
+	switch status {
+	case "ERROR", "SKIP":
+		return status
+	}
+	return "OK"
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanShardsBalancesByTimings(t *testing.T) {
+	timings := map[string]float64{"m/a": 10, "m/b": 6, "m/c": 4, "m/d": 1}
+	got := planShards([]string{"m/d", "m/c", "m/b", "m/a"}, timings, 2)
+	want := [][]string{{"m/a", "m/d"}, {"m/b", "m/c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("plan = %v, want %v", got, want)
+	}
+	// An unknown package weighs the mean of the known ones (5.25s).
+	got = planShards([]string{"m/a", "m/b", "m/c", "m/d", "m/new"}, timings, 2)
+	if !reflect.DeepEqual(got, [][]string{{"m/a", "m/c"}, {"m/b", "m/d", "m/new"}}) {
+		t.Fatalf("plan with new package = %v", got)
+	}
+	if got := planShards([]string{"m/a"}, nil, 3); len(got[1]) != 0 || len(got[2]) != 0 {
+		t.Fatalf("plan = %v", got)
+	}
+}
+
+func TestParseShardSpec(t *testing.T) {
+	if s, err := parseShardSpec("1/3"); err != nil || s != (shardSpec{index: 1, total: 3}) {
+		t.Fatalf("spec=%v err=%v", s, err)
+	}
+	if s, err := parseShardSpec(""); err != nil || s.sharded() {
+		t.Fatalf("spec=%v err=%v", s, err)
+	}
+	for _, raw := range []string{"3/3", "-1/2", "1", "a/2", "0/0"} {
+		if _, err := parseShardSpec(raw); err == nil {
+			t.Errorf("parseShardSpec(%q) should fail", raw)
+		}
+	}
+}
+
+func TestShardedRunsMergeIntoOneStatus(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not installed")
+	}
+	hostCache, err := exec.Command(goBin, "env", "GOCACHE").Output()
+	if err != nil {
+		t.Skip("go env GOCACHE failed")
+	}
+	cfg := pipelineFixture(t, `{"steps": [
+    {"name": "lint", "run": "true"},
+    {"name": "test", "shard": "go-packages", "run": "go test -count=1 $VERIFY_FULL_SHARD_PACKAGES", "dir": "sub"}
+  ]}`)
+	t.Setenv("GOCACHE", strings.TrimSpace(string(hostCache)))
+	files := map[string]string{"go.mod": "module shardfixture\n\ngo 1.21\n"}
+	for _, pkg := range []string{"a", "b", "c"} {
+		files[pkg+"/"+pkg+"_test.go"] = "package " + pkg + "\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) {}\n"
+	}
+	for name, content := range files {
+		path := filepath.Join(cfg.repoDir, "sub", name)
+		mustMkdirAll(t, filepath.Dir(path))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		shardCfg := cfg
+		shardCfg.outDir = shardDir(cfg, i)
+		mustMkdirAll(t, shardCfg.outDir)
+		t.Setenv(envShardTimings, filepath.Join(cfg.outDir, "metrics", shardTimingsFile))
+		var buf bytes.Buffer
+		if err := run(shardCfg, options{shard: shardSpec{index: i, total: 2}}, &buf); err != nil {
+			t.Fatalf("shard %d: %v\n%s", i, err, buf.String())
+		}
+	}
+	shard1 := mustRead(t, filepath.Join(shardDir(cfg, 1), "verify-full.status"))
+	if !strings.Contains(shard1, "step=lint status=SKIP reason=shard_0_only") || !strings.Contains(shard1, "shard_spec=1/2") {
+		t.Fatalf("shard 1 status:\n%s", shard1)
+	}
+
+	cfg.stamp = "20260301T000100Z"
+	var buf bytes.Buffer
+	if err := run(cfg, options{mergeShards: 2}, &buf); err != nil {
+		t.Fatalf("merge: %v\n%s", err, buf.String())
+	}
+	status := mustRead(t, filepath.Join(cfg.outDir, "verify-full.status"))
+	for _, want := range []string{
+		"shards_total=2",
+		"shard=0 status=OK",
+		"status_path=shards/1/verify-full.status",
+		"step=test shard=0 status=OK",
+		"step=test shard=1 status=OK",
+		"log=shards/0/logs/verify-full/20260301T000000Z/02-test.log",
+		"shards_failed=0",
+		"shard_timings_packages=3",
+		"pipeline_duration_ms=",
+	} {
+		if !strings.Contains(status, want) {
+			t.Errorf("merged status missing %q:\n%s", want, status)
+		}
+	}
+	total := strings.Count(status, "shard_packages=1") + 2*strings.Count(status, "shard_packages=2")
+	if total != 3 {
+		t.Fatalf("packages must be split across shards:\n%s", status)
+	}
+	timings, err := loadShardTimings(filepath.Join(cfg.outDir, "metrics", shardTimingsFile))
+	if err != nil || len(timings) != 3 {
+		t.Fatalf("timings=%v err=%v", timings, err)
+	}
+}
+
+func TestMergeShardsFailsOnMissingShard(t *testing.T) {
+	cfg := pipelineFixture(t, `{"steps": [{"name": "a", "run": "true"}]}`)
+	mustMkdirAll(t, shardDir(cfg, 0))
+	status := "OK: verify-full status=OK mode=full\nstatus=OK\npipeline_duration_ms=1200\nstep=a status=OK exit_code=0 duration_ms=5 log=logs/verify-full/x/01-a.log\n"
+	if err := os.WriteFile(filepath.Join(shardDir(cfg, 0), "verify-full.status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	details, err := mergeShards(cfg, 2, &bytes.Buffer{})
+	if err == nil || err.Error() != "pipeline failed: shard_1(status_missing)" {
+		t.Fatalf("err=%v", err)
+	}
+	joined := strings.Join(details, "\n")
+	if !strings.Contains(joined, "shard=1 status=ERROR reason=status_missing") || !strings.Contains(joined, "step=a shard=0 status=OK") {
+		t.Fatalf("details:\n%s", joined)
+	}
+}
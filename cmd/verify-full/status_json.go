@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// statusJSON renders verify-full.status as JSON for tools (resource_report,
+// dashboards). The text file stays the source of truth: OK:/SKIP:/ERROR:/
+// WARN: lines are dropped, step=, disk= and shard= lines become arrays of
+// objects and the remaining key=value lines become top-level fields.
+func statusJSON(content string) ([]byte, error) {
+	doc := map[string]any{}
+	var steps, disks, shards []map[string]any
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "OK:") || strings.HasPrefix(line, "SKIP:") || strings.HasPrefix(line, "ERROR:") || strings.HasPrefix(line, "WARN:") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "step":
+			steps = append(steps, statusFields(line))
+			continue
+		case "disk":
+			disks = append(disks, statusFields(line))
+			continue
+		case "shard":
+			shards = append(shards, statusFields(line))
+			continue
+		}
+		v := statusValueOf(key, value)
+		switch prev := doc[key].(type) {
+		case nil:
+			doc[key] = v
+		case []any:
+			doc[key] = append(prev, v)
+		default:
+			doc[key] = []any{prev, v}
+		}
+	}
+	if steps != nil {
+		doc["steps"] = steps
+	}
+	if disks != nil {
+		doc["disk"] = disks
+	}
+	if shards != nil {
+		doc["shards"] = shards
+	}
+	return json.Marshal(doc)
+}
+
+// statusFields splits "k=v k=v ..."; reason= is last and may contain spaces.
+func statusFields(line string) map[string]any {
+	fields := map[string]any{}
+	rest := line
+	for rest != "" {
+		if strings.HasPrefix(rest, "reason=") {
+			fields["reason"] = strings.TrimPrefix(rest, "reason=")
+			break
+		}
+		token, tail, _ := strings.Cut(rest, " ")
+		rest = strings.TrimSpace(tail)
+		if key, value, ok := strings.Cut(token, "="); ok {
+			fields[key] = statusValueOf(key, value)
+		}
+	}
+	return fields
+}
+
+// statusValueOf keeps ids and SHAs as strings and turns measurements into
+// numbers.
+func statusValueOf(key, value string) any {
+	numeric := key == "cpus" || key == "samples" || key == "exit_code" || strings.HasPrefix(key, "cpu_")
+	for _, suffix := range []string{"_ms", "_mb", "_bytes", "_total", "_failed", "_files", "_hits", "_misses", "_rate", "_cached", "_executed"} {
+		numeric = numeric || strings.HasSuffix(key, suffix)
+	}
+	if numeric {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// syncStatusJSON rewrites verify-full.status.json from the text status.
+func syncStatusJSON(cfg config) error {
+	content, err := os.ReadFile(filepath.Join(cfg.outDir, "verify-full.status"))
+	if err != nil {
+		return err
+	}
+	doc, err := statusJSON(string(content))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfg.outDir, "verify-full.status.json"), append(doc, '\n'), 0o644)
+}
+
+func historyPath(cfg config) string {
+	return filepath.Join(cfg.outDir, "metrics", "verify-full.jsonl")
+}
+
+// appendHistory keeps one status JSON line per run for resource_report.
+// Log retention trims run logs and evidence bundles but never this file.
+func appendHistory(cfg config) error {
+	doc, err := os.ReadFile(filepath.Join(cfg.outDir, "verify-full.status.json"))
+	if err != nil {
+		return err
+	}
+	path := historyPath(cfg)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(doc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
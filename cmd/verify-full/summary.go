@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// summaryFailedTestLimit caps the failing test names listed per step.
+const summaryFailedTestLimit = 20
+
+// goTestFailLine matches "--- FAIL: TestName (0.01s)" in step logs.
+var goTestFailLine = regexp.MustCompile(`^\s*--- FAIL: (\S+)`)
+
+// publishGHA writes the job summary ($GITHUB_STEP_SUMMARY) and step outputs
+// ($GITHUB_OUTPUT) from the final status file. Both are optional; a write
+// failure is logged and does not change the run status.
+func publishGHA(cfg config, getenv func(string) string, w io.Writer) {
+	summaryPath, outputPath := getenv("GITHUB_STEP_SUMMARY"), getenv("GITHUB_OUTPUT")
+	if summaryPath == "" && outputPath == "" {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(cfg.outDir, "verify-full.status"))
+	if err != nil {
+		content = []byte("status=ERROR\nreason=status_file_missing\n")
+	}
+	doc := parseSummaryStatus(string(content))
+	if summaryPath != "" {
+		if err := appendFile(summaryPath, renderSummary(cfg, doc, getenv)); err != nil {
+			fmt.Fprintf(w, "ERROR: gha_summary err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(w, "OK: gha_summary path=%s\n", summaryPath)
+		}
+	}
+	if outputPath != "" {
+		if err := appendFile(outputPath, renderOutputs(doc)); err != nil {
+			fmt.Fprintf(w, "ERROR: gha_output err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(w, "OK: gha_output path=%s\n", outputPath)
+		}
+	}
+}
+
+// summaryStatus is the part of verify-full.status the summary shows.
+type summaryStatus struct {
+	values   map[string]string
+	steps    []map[string]string
+	warnings []string
+}
+
+func parseSummaryStatus(content string) summaryStatus {
+	doc := summaryStatus{values: map[string]string{}}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "WARN: ") {
+			doc.warnings = append(doc.warnings, strings.TrimPrefix(line, "WARN: "))
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "OK:") || strings.HasPrefix(line, "SKIP:") || strings.HasPrefix(line, "ERROR:") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if key == "step" {
+			step := map[string]string{}
+			for k, v := range statusFields(line) {
+				if n, ok := v.(float64); ok {
+					step[k] = strconv.FormatFloat(n, 'f', -1, 64)
+				} else {
+					step[k] = fmt.Sprint(v)
+				}
+			}
+			doc.steps = append(doc.steps, step)
+			continue
+		}
+		// Keep the first value of a repeated key; reason takes the last.
+		if _, seen := doc.values[key]; !seen || key == "reason" {
+			doc.values[key] = value
+		}
+	}
+	return doc
+}
+
+func renderSummary(cfg config, doc summaryStatus, getenv func(string) string) string {
+	var b strings.Builder
+	status := doc.values["status"]
+	fmt.Fprintf(&b, "## verify-full: %s\n\n", status)
+	fmt.Fprintf(&b, "- mode: `%s`\n", doc.values["mode"])
+	if ms, ok := doc.values["pipeline_duration_ms"]; ok {
+		fmt.Fprintf(&b, "- duration: %s\n", formatMS(ms))
+	}
+	if rate, ok := doc.values["go_cache_hit_rate"]; ok {
+		fmt.Fprintf(&b, "- go cache: hit_rate=%s hits=%s misses=%s\n", rate, doc.values["go_cache_hits"], doc.values["go_cache_misses"])
+	}
+	if reason := doc.values["reason"]; reason != "" {
+		fmt.Fprintf(&b, "- reason: `%s`\n", reason)
+	}
+	for _, warn := range doc.warnings {
+		fmt.Fprintf(&b, "- warning: `%s`\n", warn)
+	}
+
+	if len(doc.steps) > 0 {
+		b.WriteString("\n| step | status | duration | exit | reason |\n|---|---|---|---|---|\n")
+		for _, step := range doc.steps {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", tableCell(step["step"]), step["status"],
+				formatMS(step["duration_ms"]), tableCell(step["exit_code"]), tableCell(step["reason"]))
+		}
+	}
+
+	for _, step := range doc.steps {
+		if step["status"] != "ERROR" || step["log"] == "" {
+			continue
+		}
+		logPath := filepath.Join(cfg.outDir, filepath.FromSlash(step["log"]))
+		if tests := failedTests(logPath); len(tests) > 0 {
+			fmt.Fprintf(&b, "\n**%s: failing tests**\n\n", step["step"])
+			for _, test := range tests {
+				fmt.Fprintf(&b, "- `%s`\n", test)
+			}
+		}
+		fmt.Fprintf(&b, "\n<details><summary>%s: last %d log lines (%s)</summary>\n\n```text\n", step["step"], failureTailLines, step["log"])
+		for _, line := range tailLines(logPath, failureTailLines) {
+			b.WriteString(strings.ReplaceAll(line, "```", "'''") + "\n")
+		}
+		b.WriteString("```\n\n</details>\n")
+	}
+
+	b.WriteString("\n**artifacts**\n\n")
+	b.WriteString("- status: `verify-full.status` / `verify-full.status.json`\n")
+	if bundle := doc.values["bundle"]; bundle != "" {
+		fmt.Fprintf(&b, "- evidence: `%s` (sha256 `%s`)\n", bundle, doc.values["bundle_sha256"])
+	}
+	if url := runURL(getenv); url != "" {
+		fmt.Fprintf(&b, "- run: [%s](%s#artifacts)\n", url, url)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderOutputs sets status, reason and the bundle so later steps read
+// steps.<id>.outputs.* instead of grepping the status file. Every value uses
+// the name<<delimiter form, which GitHub accepts for any content.
+func renderOutputs(doc summaryStatus) string {
+	failed := 0
+	for _, step := range doc.steps {
+		if step["status"] == "ERROR" {
+			failed++
+		}
+	}
+	pairs := [][2]string{
+		{"status", doc.values["status"]},
+		{"reason", doc.values["reason"]},
+		{"mode", doc.values["mode"]},
+		{"bundle", doc.values["bundle"]},
+		{"bundle_sha256", doc.values["bundle_sha256"]},
+		{"steps_failed", strconv.Itoa(failed)},
+	}
+	var b strings.Builder
+	for _, p := range pairs {
+		delim := outputDelimiter(p[1])
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", p[0], delim, p[1], delim)
+	}
+	return b.String()
+}
+
+// outputDelimiter picks a heredoc delimiter that does not occur in value, so
+// a reason spanning lines reaches the output unchanged.
+func outputDelimiter(value string) string {
+	delim := "CI_SELF_EOF"
+	for strings.Contains(value, delim) {
+		delim += "_"
+	}
+	return delim
+}
+
+func failedTests(logPath string) []string {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var tests []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() && len(tests) < summaryFailedTestLimit {
+		if m := goTestFailLine.FindStringSubmatch(scanner.Text()); m != nil {
+			tests = append(tests, m[1])
+		}
+	}
+	return tests
+}
+
+func runURL(getenv func(string) string) string {
+	server, repo, id := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, id)
+}
+
+func formatMS(raw string) string {
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return "-"
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+}
+
+func tableCell(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, "|", `\|`)
+}
+
+func appendFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishGHAWritesSummaryAndOutputs(t *testing.T) {
+	cfg := pipelineFixture(t, `{"steps": [
+    {"name": "lint", "run": "true"},
+    {"name": "test", "run": "echo '--- FAIL: TestParse (0.00s)'; echo '    parse_test.go:12: got 1'; echo FAIL; exit 1"}
+  ]}`)
+	err := run(cfg, options{}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected pipeline failure")
+	}
+	writeErrorStatus(cfg, options{}, err.Error(), err.(*pipelineError).details)
+
+	gha := t.TempDir()
+	env := map[string]string{
+		"GITHUB_STEP_SUMMARY": filepath.Join(gha, "summary.md"),
+		"GITHUB_OUTPUT":       filepath.Join(gha, "output"),
+		"GITHUB_SERVER_URL":   "https://github.com",
+		"GITHUB_REPOSITORY":   "owner/repo",
+		"GITHUB_RUN_ID":       "42",
+	}
+	var buf bytes.Buffer
+	publishGHA(cfg, func(key string) string { return env[key] }, &buf)
+	if !strings.Contains(buf.String(), "OK: gha_summary path=") || !strings.Contains(buf.String(), "OK: gha_output path=") {
+		t.Fatalf("console:\n%s", buf.String())
+	}
+
+	summary := mustRead(t, env["GITHUB_STEP_SUMMARY"])
+	for _, want := range []string{
+		"## verify-full: ERROR",
+		"- reason: `pipeline failed: test(exit_1)`",
+		"| lint | OK |",
+		"| test | ERROR |",
+		"- `TestParse`",
+		"<details><summary>test: last 20 log lines (logs/verify-full/20260301T000000Z/02-test.log)</summary>",
+		"parse_test.go:12: got 1",
+		"- evidence: `evidence/20260301T000000Z.tar.gz`",
+		"(https://github.com/owner/repo/actions/runs/42#artifacts)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+
+	output := mustRead(t, env["GITHUB_OUTPUT"])
+	for _, want := range []string{
+		"status<<CI_SELF_EOF\nERROR\nCI_SELF_EOF\n",
+		"reason<<CI_SELF_EOF\npipeline failed: test(exit_1)\nCI_SELF_EOF\n",
+		"steps_failed<<CI_SELF_EOF\n1\nCI_SELF_EOF\n",
+		"bundle_sha256<<CI_SELF_EOF\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "bundle_sha256<<CI_SELF_EOF\n\n") {
+		t.Fatalf("bundle sha missing:\n%s", output)
+	}
+}
+
+func TestPublishGHAWithoutStatusFile(t *testing.T) {
+	gha := t.TempDir()
+	output := filepath.Join(gha, "output")
+	publishGHA(config{outDir: t.TempDir()}, func(key string) string {
+		if key == "GITHUB_OUTPUT" {
+			return output
+		}
+		return ""
+	}, &bytes.Buffer{})
+	if got := mustRead(t, output); !strings.Contains(got, "status<<CI_SELF_EOF\nERROR\nCI_SELF_EOF\nreason<<CI_SELF_EOF\nstatus_file_missing\nCI_SELF_EOF\n") {
+		t.Fatalf("output:\n%s", got)
+	}
+}
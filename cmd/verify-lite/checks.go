@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// check is one independent verify-lite step. Checks run concurrently unless
+// --fail-fast is set, and each gets its own timeout.
+type check struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) checkOutcome
+}
+
+type checkOutcome struct {
+	details  []string
+	findings []finding
+	// skip marks a check that did not apply, with the reason.
+	skip string
+	err  error
+}
+
+type checkResult struct {
+	name     string
+	status   string
+	duration time.Duration
+	checkOutcome
+}
+
+// statusLine is the per-check line written to the status file.
+func (r checkResult) statusLine() string {
+	return fmt.Sprintf("check=%s status=%s duration_ms=%d", r.name, r.status, r.duration.Milliseconds())
+}
+
+// checkTimeout reads VERIFY_LITE_TIMEOUT_<NAME>_SEC, e.g.
+// VERIFY_LITE_TIMEOUT_GO_TEST_SEC, falling back to the given default.
+func checkTimeout(name string, fallbackSec int) time.Duration {
+	key := "VERIFY_LITE_TIMEOUT_" + strings.ToUpper(name) + "_SEC"
+	sec, err := envOrInt(key, fallbackSec)
+	if err != nil {
+		fmt.Printf("SKIP: verify-lite check_timeout reason=invalid_env key=%s default=%d\n", key, fallbackSec)
+		sec = fallbackSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// verifyChecks lists the working tree checks in report order: the scanners,
+// then the checks of every enabled ecosystem.
+func verifyChecks(cfg config, opts options, enabled []ecosystem) []check {
+	checks := []check{
+		{name: "secret_scan", timeout: checkTimeout("secret_scan", 120), run: func(context.Context) checkOutcome {
+			findings, details, err := runSecretPatternScan()
+			if err == nil && len(findings) > 0 {
+				err = findingsError(findings)
+			}
+			return checkOutcome{details: details, findings: findings, err: err}
+		}},
+		{name: "workflow_policy_scan", timeout: checkTimeout("workflow_policy_scan", 120), run: func(context.Context) checkOutcome {
+			findings, err := runWorkflowPolicyScan()
+			if err == nil && len(findings) > 0 {
+				err = findingsError(findings)
+			}
+			return checkOutcome{findings: findings, err: err}
+		}},
+		{name: "shell_policy_scan", timeout: checkTimeout("shell_policy_scan", 120), run: func(context.Context) checkOutcome {
+			findings, err := runShellPolicyScan()
+			if err == nil && len(findings) > 0 {
+				err = findingsError(findings)
+			}
+			return checkOutcome{findings: findings, err: err}
+		}},
+		{name: "dockerfile_policy_scan", timeout: checkTimeout("dockerfile_policy_scan", 120), run: func(context.Context) checkOutcome {
+			findings, err := runDockerfilePolicyScan()
+			if err == nil && len(findings) > 0 {
+				err = findingsError(findings)
+			}
+			return checkOutcome{findings: findings, err: err}
+		}},
+		{name: "markdown_link_scan", timeout: checkTimeout("markdown_link_scan", 120), run: func(context.Context) checkOutcome {
+			findings, err := runMarkdownLinkScan()
+			if err == nil && len(findings) > 0 {
+				err = findingsError(findings)
+			}
+			return checkOutcome{findings: findings, err: err}
+		}},
+		{name: "toolchain_consistency", timeout: checkTimeout("toolchain_consistency", 120), run: runToolchainCheck},
+	}
+	for _, e := range enabled {
+		checks = append(checks, e.checks(cfg, opts)...)
+	}
+	return checks
+}
+
+// runChecks executes checks and returns one result per check in list order.
+// With failFast the checks run one by one and the rest are skipped after the
+// first failure, which matches the original sequential behavior.
+func runChecks(ctx context.Context, checks []check, failFast bool) []checkResult {
+	results := make([]checkResult, len(checks))
+	if failFast {
+		failed := false
+		for i, c := range checks {
+			if failed {
+				results[i] = checkResult{name: c.name, status: "SKIP"}
+				fmt.Printf("SKIP: verify-lite check=%s reason=fail_fast\n", c.name)
+				continue
+			}
+			results[i] = runCheck(ctx, c)
+			failed = results[i].err != nil
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runCheck runs one check under its own timeout. A check that does not return
+// after its deadline is reported as timed out; its goroutine is abandoned.
+func runCheck(parent context.Context, c check) checkResult {
+	ctx, cancel := context.WithTimeout(parent, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan checkOutcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- checkOutcome{err: fmt.Errorf("%s panic=%v", c.name, r)}
+			}
+		}()
+		done <- c.run(ctx)
+	}()
+
+	var outcome checkOutcome
+	select {
+	case outcome = <-done:
+	case <-ctx.Done():
+		outcome = checkOutcome{err: ctx.Err()}
+	}
+	if outcome.err != nil && ctx.Err() != nil {
+		outcome.err = fmt.Errorf("%s timeout exceeded (%s): %w", c.name, c.timeout, outcome.err)
+	}
+
+	result := checkResult{name: c.name, status: "OK", duration: time.Since(start), checkOutcome: outcome}
+	switch {
+	case outcome.err != nil:
+		result.status = "ERROR"
+	case outcome.skip != "":
+		result.status = "SKIP"
+		fmt.Printf("SKIP: verify-lite check=%s reason=%s\n", c.name, outcome.skip)
+	}
+	fmt.Printf("%s: verify-lite check=%s duration_ms=%d\n", result.status, c.name, result.duration.Milliseconds())
+	return result
+}
+
+// checksError joins the errors of every failed check in list order.
+func checksError(results []checkResult) error {
+	var msgs []string
+	for _, r := range results {
+		if r.err != nil {
+			msgs = append(msgs, r.err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// runGofmtCheck runs gofmt -l on the tree, or only on changed packages when
+// idx is set (--incremental).
+func runGofmtCheck(ctx context.Context, idx *goPackageIndex) checkOutcome {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		return checkOutcome{err: errors.New("gofmt command not found")}
+	}
+	if idx != nil {
+		return runIncrementalGofmt(ctx, idx)
+	}
+	unformatted, err := runCommandCapture(ctx, "gofmt", "-l", ".")
+	if err != nil {
+		return checkOutcome{err: fmt.Errorf("gofmt -l failed: %w", err)}
+	}
+	unformatted = strings.TrimSpace(unformatted)
+	if unformatted != "" {
+		return checkOutcome{err: fmt.Errorf("gofmt check failed; unformatted files:\n%s", unformatted)}
+	}
+	return checkOutcome{}
+}
+
+func runGoVetCheck(ctx context.Context, idx *goPackageIndex) checkOutcome {
+	if _, err := exec.LookPath("go"); err != nil {
+		return checkOutcome{err: errors.New("go command not found")}
+	}
+	if idx != nil {
+		return runIncrementalGoVet(ctx, idx)
+	}
+	if err := runCommand(ctx, "go", "vet", "./..."); err != nil {
+		return checkOutcome{err: fmt.Errorf("go vet failed: %w", err)}
+	}
+	return checkOutcome{}
+}
+
+// runGoTestCheck runs go test -json and, when the repo declares one, the
+// coverage gate on the resulting profile. With idx set only packages without
+// a cached pass are tested; the coverage gate needs every package, so it
+// disables the cache.
+func runGoTestCheck(ctx context.Context, cfg config, opts options, idx *goPackageIndex) checkOutcome {
+	if _, err := exec.LookPath("go"); err != nil {
+		return checkOutcome{err: errors.New("go command not found")}
+	}
+	coverageCfg, err := loadCoverageConfig(coverageConfigPath())
+	if err != nil {
+		return checkOutcome{err: fmt.Errorf("coverage config: %w", err)}
+	}
+	var testArgs []string
+	profilePath := filepath.Join(cfg.outDir, "coverage.out")
+	if coverageCfg != nil {
+		if err := os.MkdirAll(cfg.outDir, 0o755); err != nil {
+			return checkOutcome{err: err}
+		}
+		absProfile, err := filepath.Abs(profilePath)
+		if err != nil {
+			return checkOutcome{err: err}
+		}
+		testArgs = append(testArgs, "-coverprofile="+absProfile)
+	} else if opts.updateCoverageBaseline {
+		return checkOutcome{err: fmt.Errorf("--update-coverage-baseline requires %s", coverageConfigPath())}
+	}
+	if idx != nil && coverageCfg != nil {
+		fmt.Println("SKIP: verify-lite go_test cache reason=coverage_gate_needs_full_run")
+		idx = nil
+	}
+
+	var pkgs, misses []goPackage
+	var cacheDetails []string
+	if idx != nil {
+		all, err := idx.packages(ctx)
+		if err != nil {
+			return checkOutcome{err: err}
+		}
+		var hits []goPackage
+		hits, misses = idx.partition("go_test", all, buildKey)
+		cacheDetails = append(cacheDetails, cacheDetail("go_test", len(hits), len(misses)))
+		if len(misses) == 0 {
+			return checkOutcome{details: cacheDetails}
+		}
+		pkgs = misses
+	}
+
+	report, testErr := runGoTest(ctx, importPaths(pkgs), testArgs...)
+	if idx != nil {
+		idx.storeAll("go_test", passedGoTestPackages(report, misses), buildKey)
+	}
+	tablePath := filepath.Join(cfg.outDir, "verify-lite-go-test.md")
+	if err := writeGoTestDurations(tablePath, report); err != nil {
+		fmt.Printf("SKIP: verify-lite go_test_durations reason=write_failed err=%s\n", err.Error())
+		tablePath = ""
+	}
+	details := append(cacheDetails, goTestDetails(report, tablePath)...)
+	if testErr != nil {
+		for _, f := range report.failures {
+			fmt.Printf("ERROR: verify-lite go_test package=%s test=%s\n", f.pkg, f.test)
+		}
+		if strings.EqualFold(os.Getenv("GITHUB_ACTIONS"), "true") {
+			printGoTestAnnotations(os.Stdout, report, readModulePath())
+		}
+		if reason := goTestFailureReason(report); reason != "" {
+			return checkOutcome{details: details, err: fmt.Errorf("go test failed: %s", reason)}
+		}
+		return checkOutcome{details: details, err: fmt.Errorf("go test failed: %w", testErr)}
+	}
+	if coverageCfg != nil {
+		coverage, err := runCoverageGate(*coverageCfg, profilePath, opts.updateCoverageBaseline)
+		details = append(details, coverage...)
+		if err != nil {
+			return checkOutcome{details: details, err: err}
+		}
+	}
+	return checkOutcome{details: details}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunChecksRunsConcurrentlyAndReportsEveryCheck(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	waitForPeer := func(ctx context.Context) checkOutcome {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return checkOutcome{}
+		case <-ctx.Done():
+			return checkOutcome{err: ctx.Err()}
+		}
+	}
+	go func() {
+		<-started
+		<-started
+		close(release)
+	}()
+
+	results := runChecks(context.Background(), []check{
+		{name: "fails", timeout: time.Second, run: func(context.Context) checkOutcome {
+			return checkOutcome{err: errors.New("boom")}
+		}},
+		{name: "a", timeout: 5 * time.Second, run: waitForPeer},
+		{name: "b", timeout: 5 * time.Second, run: waitForPeer},
+	}, false)
+
+	var lines []string
+	for _, r := range results {
+		lines = append(lines, r.name+"="+r.status)
+	}
+	if got := strings.Join(lines, ","); got != "fails=ERROR,a=OK,b=OK" {
+		t.Fatalf("unexpected results: %s", got)
+	}
+	if err := checksError(results); err == nil || err.Error() != "boom" {
+		t.Fatalf("unexpected joined error: %v", err)
+	}
+	if !strings.HasPrefix(results[0].statusLine(), "check=fails status=ERROR duration_ms=") {
+		t.Fatalf("unexpected status line: %s", results[0].statusLine())
+	}
+}
+
+func TestRunChecksFailFastSkipsRemainingChecks(t *testing.T) {
+	ran := false
+	results := runChecks(context.Background(), []check{
+		{name: "first", timeout: time.Second, run: func(context.Context) checkOutcome {
+			return checkOutcome{err: errors.New("boom")}
+		}},
+		{name: "second", timeout: time.Second, run: func(context.Context) checkOutcome {
+			ran = true
+			return checkOutcome{}
+		}},
+	}, true)
+	if ran {
+		t.Fatal("second check should not run with fail-fast")
+	}
+	if results[1].status != "SKIP" || results[1].statusLine() != "check=second status=SKIP duration_ms=0" {
+		t.Fatalf("unexpected skipped result: %+v", results[1])
+	}
+}
+
+func TestRunCheckEnforcesPerCheckTimeout(t *testing.T) {
+	result := runCheck(context.Background(), check{name: "slow", timeout: 20 * time.Millisecond, run: func(context.Context) checkOutcome {
+		time.Sleep(time.Second)
+		return checkOutcome{}
+	}})
+	if result.status != "ERROR" || !strings.Contains(result.err.Error(), "slow timeout exceeded") {
+		t.Fatalf("expected timeout error, got %+v", result)
+	}
+	if result.duration >= time.Second {
+		t.Fatalf("timeout should not wait for the check: %s", result.duration)
+	}
+}
+
+func TestCheckTimeoutReadsPerCheckEnv(t *testing.T) {
+	t.Setenv("VERIFY_LITE_TIMEOUT_GO_TEST_SEC", "42")
+	if got := checkTimeout("go_test", 600); got != 42*time.Second {
+		t.Fatalf("unexpected timeout: %s", got)
+	}
+	if got := checkTimeout("go_vet", 300); got != 300*time.Second {
+		t.Fatalf("unexpected default timeout: %s", got)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultCoverageConfig enables the coverage gate when present in the repo.
+const defaultCoverageConfig = ".ci-self/coverage.json"
+
+// coverageConfig is the repo-declared coverage policy. Percentages are 0-100.
+type coverageConfig struct {
+	// TotalMin is the minimum statement coverage across all packages.
+	TotalMin float64 `json:"total_min"`
+	// PackageMin applies to every package without an entry in Packages.
+	PackageMin float64 `json:"package_min"`
+	// Packages overrides PackageMin per import path.
+	Packages map[string]float64 `json:"packages"`
+	// Exclude lists import paths left out of per-package checks.
+	Exclude []string `json:"exclude"`
+	// Baseline is a coverage snapshot (see coverageSnapshot) to compare with.
+	Baseline string `json:"baseline"`
+	// MaxDrop is the allowed drop in percentage points against Baseline.
+	MaxDrop float64 `json:"max_drop"`
+}
+
+// coverageSnapshot is both the computed result and the baseline file format.
+type coverageSnapshot struct {
+	Total    float64            `json:"total"`
+	Packages map[string]float64 `json:"packages"`
+}
+
+func coverageConfigPath() string {
+	return envOr("VERIFY_LITE_COVERAGE_CONFIG", defaultCoverageConfig)
+}
+
+// loadCoverageConfig returns nil when the config file does not exist, which
+// keeps the gate optional.
+func loadCoverageConfig(path string) (*coverageConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var cfg coverageConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.MaxDrop < 0 {
+		return nil, fmt.Errorf("parse %s: max_drop must not be negative", path)
+	}
+	return &cfg, nil
+}
+
+type coverageCounts struct {
+	covered int
+	total   int
+}
+
+func (c coverageCounts) percent() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.covered) * 100 / float64(c.total)
+}
+
+// parseCoverProfile computes per-package and total statement coverage from a
+// -coverprofile file. A block listed more than once (./... runs merge several
+// test binaries) counts as covered if any run covered it.
+func parseCoverProfile(r io.Reader) (coverageSnapshot, error) {
+	type block struct {
+		stmts   int
+		covered bool
+	}
+	blocks := map[string]*block{}
+	var order []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			first = false
+			if !strings.HasPrefix(line, "mode:") {
+				return coverageSnapshot{}, errors.New("coverprofile: missing mode line")
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		// example.com/m/pkg/file.go:12.34,15.2 3 1
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return coverageSnapshot{}, fmt.Errorf("coverprofile: malformed line %q", line)
+		}
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return coverageSnapshot{}, fmt.Errorf("coverprofile: statements %q: %w", fields[1], err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return coverageSnapshot{}, fmt.Errorf("coverprofile: count %q: %w", fields[2], err)
+		}
+		b, ok := blocks[fields[0]]
+		if !ok {
+			b = &block{stmts: stmts}
+			blocks[fields[0]] = b
+			order = append(order, fields[0])
+		}
+		b.covered = b.covered || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return coverageSnapshot{}, fmt.Errorf("read coverprofile: %w", err)
+	}
+
+	perPackage := map[string]*coverageCounts{}
+	var total coverageCounts
+	for _, key := range order {
+		b := blocks[key]
+		file, _, _ := strings.Cut(key, ":")
+		pkg := path.Dir(file)
+		counts, ok := perPackage[pkg]
+		if !ok {
+			counts = &coverageCounts{}
+			perPackage[pkg] = counts
+		}
+		counts.total += b.stmts
+		total.total += b.stmts
+		if b.covered {
+			counts.covered += b.stmts
+			total.covered += b.stmts
+		}
+	}
+	snapshot := coverageSnapshot{Total: roundCoverage(total.percent()), Packages: map[string]float64{}}
+	for pkg, counts := range perPackage {
+		snapshot.Packages[pkg] = roundCoverage(counts.percent())
+	}
+	return snapshot, nil
+}
+
+// roundCoverage keeps one decimal so status lines, baselines and comparisons
+// agree with each other.
+func roundCoverage(pct float64) float64 {
+	return float64(int64(pct*10+0.5)) / 10
+}
+
+func readCoverProfile(path string) (coverageSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return coverageSnapshot{}, err
+	}
+	defer f.Close()
+	return parseCoverProfile(f)
+}
+
+func loadCoverageBaseline(path string) (*coverageSnapshot, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var baseline coverageSnapshot
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &baseline, nil
+}
+
+func writeCoverageBaseline(path string, snapshot coverageSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// evaluateCoverage returns one violation per failing threshold or regressed
+// package, in a stable order.
+func evaluateCoverage(cfg coverageConfig, current coverageSnapshot, baseline *coverageSnapshot) []string {
+	var violations []string
+	if cfg.TotalMin > 0 && current.Total < cfg.TotalMin {
+		violations = append(violations, fmt.Sprintf("total %.1f%% < min %.1f%%", current.Total, cfg.TotalMin))
+	}
+	if baseline != nil {
+		if drop := baseline.Total - current.Total; drop > cfg.MaxDrop+coverageEpsilon {
+			violations = append(violations, fmt.Sprintf("total %.1f%% -> %.1f%% (max_drop %.1f)", baseline.Total, current.Total, cfg.MaxDrop))
+		}
+	}
+
+	excluded := map[string]bool{}
+	for _, pkg := range cfg.Exclude {
+		excluded[pkg] = true
+	}
+	for _, pkg := range sortedCoveragePackages(current) {
+		if excluded[pkg] {
+			continue
+		}
+		pct := current.Packages[pkg]
+		min, ok := cfg.Packages[pkg]
+		if !ok {
+			min = cfg.PackageMin
+		}
+		if min > 0 && pct < min {
+			violations = append(violations, fmt.Sprintf("%s %.1f%% < min %.1f%%", pkg, pct, min))
+		}
+		if baseline == nil {
+			continue
+		}
+		if before, ok := baseline.Packages[pkg]; ok && before-pct > cfg.MaxDrop+coverageEpsilon {
+			violations = append(violations, fmt.Sprintf("%s %.1f%% -> %.1f%% (max_drop %.1f)", pkg, before, pct, cfg.MaxDrop))
+		}
+	}
+	return violations
+}
+
+// coverageEpsilon absorbs float error in one-decimal comparisons.
+const coverageEpsilon = 1e-9
+
+func sortedCoveragePackages(snapshot coverageSnapshot) []string {
+	pkgs := make([]string, 0, len(snapshot.Packages))
+	for pkg := range snapshot.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// coverageDetails renders the measured numbers as status file lines.
+func coverageDetails(snapshot coverageSnapshot, profilePath string) []string {
+	details := []string{
+		"coverage_profile=" + profilePath,
+		fmt.Sprintf("coverage_total=%.1f", snapshot.Total),
+	}
+	for _, pkg := range sortedCoveragePackages(snapshot) {
+		details = append(details, fmt.Sprintf("coverage_package=%s pct=%.1f", pkg, snapshot.Packages[pkg]))
+	}
+	return details
+}
+
+// runCoverageGate evaluates the profile written by go test against the repo
+// config. With updateBaseline the measured numbers replace the baseline file,
+// which the config must name.
+func runCoverageGate(cfg coverageConfig, profilePath string, updateBaseline bool) ([]string, error) {
+	if updateBaseline && cfg.Baseline == "" {
+		return nil, fmt.Errorf("--update-coverage-baseline requires %q in %s", "baseline", coverageConfigPath())
+	}
+	fmt.Println("OK: verify-lite coverage_gate start")
+	current, err := readCoverProfile(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("coverage gate failed: %w", err)
+	}
+	details := coverageDetails(current, profilePath)
+
+	var baseline *coverageSnapshot
+	if cfg.Baseline != "" {
+		if updateBaseline {
+			if err := writeCoverageBaseline(cfg.Baseline, current); err != nil {
+				return details, fmt.Errorf("coverage gate failed: write baseline: %w", err)
+			}
+			fmt.Printf("OK: verify-lite coverage_baseline updated path=%s\n", cfg.Baseline)
+		}
+		baseline, err = loadCoverageBaseline(cfg.Baseline)
+		if err != nil {
+			return details, fmt.Errorf("coverage gate failed: %w", err)
+		}
+		if baseline == nil {
+			fmt.Printf("SKIP: verify-lite coverage_baseline reason=missing path=%s\n", cfg.Baseline)
+		} else {
+			details = append(details, "coverage_baseline="+cfg.Baseline)
+		}
+	}
+
+	violations := evaluateCoverage(cfg, current, baseline)
+	details = append(details, fmt.Sprintf("coverage_violations=%d", len(violations)))
+	if len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Printf("ERROR: verify-lite coverage_gate %s\n", v)
+		}
+		return details, fmt.Errorf("coverage gate failed: %s", strings.Join(violations, "; "))
+	}
+	fmt.Printf("OK: verify-lite coverage_gate done total=%.1f\n", current.Total)
+	return details, nil
+}
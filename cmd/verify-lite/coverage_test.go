@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleCoverProfile = `mode: set
+example.com/m/a/a.go:3.10,5.2 2 1
+example.com/m/a/a.go:7.10,9.2 2 0
+example.com/m/a/a.go:7.10,9.2 2 1
+example.com/m/a/b.go:3.10,5.2 4 0
+example.com/m/c/c.go:3.10,5.2 2 1
+`
+
+func TestParseCoverProfileMergesDuplicateBlocks(t *testing.T) {
+	snapshot, err := parseCoverProfile(strings.NewReader(sampleCoverProfile))
+	if err != nil {
+		t.Fatalf("parseCoverProfile returned error: %v", err)
+	}
+	if snapshot.Packages["example.com/m/a"] != 50 || snapshot.Packages["example.com/m/c"] != 100 {
+		t.Fatalf("unexpected package coverage: %+v", snapshot.Packages)
+	}
+	if snapshot.Total != 60 {
+		t.Fatalf("unexpected total: %v", snapshot.Total)
+	}
+
+	if _, err := parseCoverProfile(strings.NewReader("example.com/m/a/a.go:3.10,5.2 2 1\n")); err == nil {
+		t.Fatal("expected error for profile without mode line")
+	}
+}
+
+func TestEvaluateCoverageThresholdsAndBaseline(t *testing.T) {
+	current := coverageSnapshot{Total: 60, Packages: map[string]float64{
+		"example.com/m/a": 50,
+		"example.com/m/c": 100,
+		"example.com/m/d": 10,
+	}}
+	cfg := coverageConfig{
+		TotalMin:   70,
+		PackageMin: 40,
+		Packages:   map[string]float64{"example.com/m/c": 90},
+		Exclude:    []string{"example.com/m/d"},
+		MaxDrop:    1,
+	}
+	baseline := &coverageSnapshot{Total: 60.5, Packages: map[string]float64{
+		"example.com/m/a": 52,
+		"example.com/m/c": 100,
+	}}
+	got := evaluateCoverage(cfg, current, baseline)
+	want := []string{
+		"total 60.0% < min 70.0%",
+		"example.com/m/a 52.0% -> 50.0% (max_drop 1.0)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	cfg.TotalMin = 0
+	cfg.MaxDrop = 2
+	if got := evaluateCoverage(cfg, current, baseline); len(got) != 0 {
+		t.Fatalf("expected no violations within max_drop, got %v", got)
+	}
+}
+
+func TestRunCoverageGateUpdatesBaseline(t *testing.T) {
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "coverage.out")
+	if err := os.WriteFile(profile, []byte(sampleCoverProfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	baselinePath := filepath.Join(dir, ".ci-self", "coverage-baseline.json")
+	cfg := coverageConfig{Baseline: baselinePath, MaxDrop: 0.5}
+
+	details, err := runCoverageGate(cfg, profile, true)
+	if err != nil {
+		t.Fatalf("runCoverageGate returned error: %v", err)
+	}
+	joined := strings.Join(details, "\n")
+	for _, want := range []string{"coverage_total=60.0", "coverage_package=example.com/m/a pct=50.0", "coverage_baseline=" + baselinePath} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("details missing %q:\n%s", want, joined)
+		}
+	}
+	baseline, err := loadCoverageBaseline(baselinePath)
+	if err != nil || baseline == nil || baseline.Total != 60 {
+		t.Fatalf("baseline not written: %+v err=%v", baseline, err)
+	}
+
+	// Raise the stored baseline so the same profile now regresses.
+	baseline.Packages["example.com/m/c"] = 100
+	baseline.Packages["example.com/m/a"] = 80
+	if err := writeCoverageBaseline(baselinePath, *baseline); err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCoverageGate(cfg, profile, false)
+	if err == nil || !strings.Contains(err.Error(), "example.com/m/a 80.0% -> 50.0%") {
+		t.Fatalf("expected regression error, got %v", err)
+	}
+}
+
+func TestRunCoverageGateUpdateNeedsBaselinePath(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "coverage.out")
+	if err := os.WriteFile(profile, []byte(sampleCoverProfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runCoverageGate(coverageConfig{TotalMin: 10}, profile, true)
+	if err == nil || !strings.Contains(err.Error(), `--update-coverage-baseline requires "baseline" in`) {
+		t.Fatalf("expected missing baseline error, got %v", err)
+	}
+}
+
+func TestLoadCoverageConfigIsOptional(t *testing.T) {
+	cfg, err := loadCoverageConfig(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || cfg != nil {
+		t.Fatalf("missing config should disable the gate: cfg=%v err=%v", cfg, err)
+	}
+}
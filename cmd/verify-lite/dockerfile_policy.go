@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dockerRule is a ci/image/versions.lock convention checked on Dockerfiles.
+type dockerRule struct {
+	id          string
+	description string
+}
+
+const (
+	dockerRuleUnpinned        = "docker-unpinned-base-image"
+	dockerRuleDigestNotLocked = "docker-digest-not-locked"
+	dockerRuleAptRecommends   = "docker-apt-install-recommends"
+	dockerRuleAddURL          = "docker-add-remote-url"
+)
+
+var dockerRules = []dockerRule{
+	{id: dockerRuleUnpinned, description: "FROM images must be pinned with an @sha256: digest"},
+	{id: dockerRuleDigestNotLocked, description: "FROM digests must match the image entry in versions.lock"},
+	{id: dockerRuleAptRecommends, description: "apt-get install must use --no-install-recommends"},
+	{id: dockerRuleAddURL, description: "ADD must not fetch remote URLs; download with a verified checksum instead"},
+}
+
+func dockerRuleMetas() []ruleMeta {
+	metas := make([]ruleMeta, 0, len(dockerRules))
+	for _, rule := range dockerRules {
+		severity := "4.0"
+		if rule.id != dockerRuleAptRecommends {
+			severity = "6.5"
+		}
+		metas = append(metas, ruleMeta{id: rule.id, description: rule.description, securitySeverity: severity})
+	}
+	return metas
+}
+
+// isDockerfile matches Dockerfile, Dockerfile.<variant>, <name>.Dockerfile
+// and Containerfile. The variant form is case-sensitive so source files such
+// as dockerfile.go are not picked up.
+func isDockerfile(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "dockerfile" || lower == "containerfile" ||
+		strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(lower, ".dockerfile")
+}
+
+// dockerInstruction is one instruction with continuation lines joined.
+type dockerInstruction struct {
+	line    int
+	keyword string
+	args    string
+}
+
+// parseDockerfile joins backslash continuations and drops comment lines,
+// including comments inside a continued instruction.
+func parseDockerfile(content string) []dockerInstruction {
+	var out []dockerInstruction
+	var current *dockerInstruction
+	for i, raw := range strings.Split(content, "\n") {
+		text := strings.TrimSpace(raw)
+		if strings.HasPrefix(text, "#") || (text == "" && current == nil) {
+			continue
+		}
+		continued := strings.HasSuffix(text, "\\")
+		text = strings.TrimSpace(strings.TrimSuffix(text, "\\"))
+		if current == nil {
+			keyword, args, _ := strings.Cut(text, " ")
+			current = &dockerInstruction{line: i + 1, keyword: strings.ToUpper(keyword), args: strings.TrimSpace(args)}
+		} else if text != "" {
+			current.args += " " + text
+		}
+		if !continued {
+			out = append(out, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		out = append(out, *current)
+	}
+	return out
+}
+
+var (
+	lockImageRefPattern = regexp.MustCompile(`^([^@\s]+)@(sha256:[0-9a-f]{64})$`)
+	aptCommandSplit     = regexp.MustCompile(`&&|\|\||;|\|`)
+)
+
+// lockedImages reads image digests from versions.lock: full image@digest
+// values anywhere, and sections that pair an image key with a digest key.
+func lockedImages(content string) map[string]string {
+	out := map[string]string{}
+	section := map[string]string{}
+	flush := func() {
+		if section["image"] != "" && section["digest"] != "" {
+			out[normalizeImageName(section["image"])] = section["digest"]
+		}
+		section = map[string]string{}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if tomlSectionPattern.MatchString(line) {
+			flush()
+			continue
+		}
+		m := tomlStringValuePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		section[m[1]] = m[2]
+		if ref := lockImageRefPattern.FindStringSubmatch(m[2]); ref != nil {
+			out[normalizeImageName(ref[1])] = ref[2]
+		}
+	}
+	flush()
+	return out
+}
+
+// normalizeImageName drops the implicit Docker Hub prefix and :latest tag so
+// "docker.io/library/debian" and "debian:latest" compare equal.
+func normalizeImageName(name string) string {
+	name = strings.TrimPrefix(name, "docker.io/")
+	name = strings.TrimPrefix(name, "library/")
+	if !strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		name += ":latest"
+	}
+	return name
+}
+
+// evaluateDockerfile applies the Dockerfile rules. locked is nil when no
+// versions.lock sits next to the Dockerfile, which disables the lock check.
+func evaluateDockerfile(path, content string, locked map[string]string) []finding {
+	var findings []finding
+	stages := map[string]bool{}
+	add := func(rule string, line int, message string) {
+		findings = append(findings, finding{rule: rule, file: path, line: line, message: message})
+	}
+	for _, ins := range parseDockerfile(content) {
+		switch ins.keyword {
+		case "FROM":
+			fields := strings.Fields(ins.args)
+			var image string
+			for i, f := range fields {
+				if strings.HasPrefix(f, "--") {
+					continue
+				}
+				image = f
+				if i+2 < len(fields) && strings.EqualFold(fields[i+1], "AS") {
+					stages[strings.ToLower(fields[i+2])] = true
+				}
+				break
+			}
+			if image == "" || image == "scratch" || stages[strings.ToLower(image)] {
+				continue
+			}
+			name, digest, pinned := strings.Cut(image, "@")
+			if !pinned || !strings.HasPrefix(digest, "sha256:") {
+				add(dockerRuleUnpinned, ins.line, fmt.Sprintf("FROM %s is not pinned by @sha256: digest", image))
+				continue
+			}
+			if locked == nil {
+				continue
+			}
+			want, ok := locked[normalizeImageName(name)]
+			switch {
+			case !ok:
+				add(dockerRuleDigestNotLocked, ins.line, fmt.Sprintf("FROM %s has no entry in versions.lock", name))
+			case want != digest:
+				add(dockerRuleDigestNotLocked, ins.line, fmt.Sprintf("FROM %s digest %s does not match versions.lock %s", name, digest, want))
+			}
+		case "RUN":
+			for _, segment := range aptCommandSplit.Split(ins.args, -1) {
+				fields := strings.Fields(segment)
+				apt := -1
+				for i, f := range fields {
+					if f == "apt-get" {
+						apt = i
+						break
+					}
+				}
+				if apt < 0 || !containsField(fields[apt+1:], "install") || containsField(fields, "--no-install-recommends") {
+					continue
+				}
+				add(dockerRuleAptRecommends, ins.line, "apt-get install without --no-install-recommends")
+			}
+		case "ADD":
+			fields := strings.Fields(ins.args)
+			var sources []string
+			for _, f := range fields {
+				if !strings.HasPrefix(f, "--") {
+					sources = append(sources, f)
+				}
+			}
+			if len(sources) > 1 {
+				sources = sources[:len(sources)-1]
+			}
+			for _, src := range sources {
+				lower := strings.ToLower(src)
+				if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "git@") {
+					add(dockerRuleAddURL, ins.line, "ADD fetches remote URL "+src)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func containsField(fields []string, want string) bool {
+	for _, f := range fields {
+		if f == want {
+			return true
+		}
+	}
+	return false
+}
+
+func runDockerfilePolicyScan() ([]finding, error) {
+	fmt.Println("OK: verify-lite dockerfile_policy_scan start")
+	var findings []finding
+	files := 0
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if scanSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isDockerfile(d.Name()) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var locked map[string]string
+		if lock, err := os.ReadFile(filepath.Join(filepath.Dir(path), "versions.lock")); err == nil {
+			locked = lockedImages(string(lock))
+		}
+		files++
+		findings = append(findings, evaluateDockerfile(filepath.ToSlash(path), string(content), locked)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dockerfile policy scan failed: %w", err)
+	}
+	for _, f := range findings {
+		fmt.Printf("ERROR: verify-lite dockerfile_policy_scan %s\n", f)
+	}
+	if len(findings) == 0 {
+		fmt.Printf("OK: verify-lite dockerfile_policy_scan done files=%d\n", files)
+	}
+	return findings, nil
+}
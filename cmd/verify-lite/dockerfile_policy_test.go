@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testDigestA = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testDigestB = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+func TestEvaluateDockerfileAcceptsRepoImage(t *testing.T) {
+	dir := filepath.Join("..", "..", "ci", "image")
+	content, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := os.ReadFile(filepath.Join(dir, "versions.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := evaluateDockerfile("ci/image/Dockerfile", string(content), lockedImages(string(lock))); len(findings) > 0 {
+		t.Fatalf("ci/image/Dockerfile should pass the dockerfile policy:\n%s", shellRuleLines(findings))
+	}
+}
+
+func TestEvaluateDockerfileRules(t *testing.T) {
+	dockerfile := strings.Join([]string{
+		"# syntax=docker/dockerfile:1",
+		"FROM --platform=$BUILDPLATFORM golang:1.25.6-bookworm@" + testDigestA + " AS build",
+		"FROM debian:stable-slim@" + testDigestA + " AS base",
+		"FROM alpine:3.20",
+		"FROM build AS test",
+		"FROM scratch",
+		"RUN apt-get update && apt-get install -y \\",
+		"    # comment inside a continuation",
+		"    curl \\",
+		"  && apt-get -y install --no-install-recommends git",
+		"RUN apt-get install -y --no-install-recommends ca-certificates",
+		"ADD --checksum=sha256:abc https://example.com/tool.tgz /opt/",
+		"ADD ./local /opt/local",
+	}, "\n")
+	locked := lockedImages(strings.Join([]string{
+		"[base]",
+		`image = "debian:stable-slim"`,
+		`digest = "` + testDigestB + `"`,
+		"",
+		"[notes]",
+		`go_builder_image = "golang:1.25.6-bookworm@` + testDigestA + `"`,
+	}, "\n"))
+
+	got := shellRuleLines(evaluateDockerfile("Dockerfile", dockerfile, locked))
+	want := strings.Join([]string{
+		"Dockerfile:3 docker-digest-not-locked: FROM debian:stable-slim digest " + testDigestA + " does not match versions.lock " + testDigestB,
+		"Dockerfile:4 docker-unpinned-base-image: FROM alpine:3.20 is not pinned by @sha256: digest",
+		"Dockerfile:7 docker-apt-install-recommends: apt-get install without --no-install-recommends",
+		"Dockerfile:12 docker-add-remote-url: ADD fetches remote URL https://example.com/tool.tgz",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected findings:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEvaluateDockerfileRequiresLockEntry(t *testing.T) {
+	dockerfile := "FROM docker.io/library/node:22@" + testDigestA + "\n"
+	locked := lockedImages("[notes]\nnode_image = \"node:22@" + testDigestA + "\"\n")
+	if findings := evaluateDockerfile("Dockerfile", dockerfile, locked); len(findings) != 0 {
+		t.Fatalf("docker.io/library prefix should match the lock entry: %s", shellRuleLines(findings))
+	}
+	got := shellRuleLines(evaluateDockerfile("Dockerfile", dockerfile, map[string]string{}))
+	if got != "Dockerfile:1 docker-digest-not-locked: FROM docker.io/library/node:22 has no entry in versions.lock" {
+		t.Fatalf("got %s", got)
+	}
+	if findings := evaluateDockerfile("Dockerfile", dockerfile, nil); len(findings) != 0 {
+		t.Fatalf("without versions.lock only the digest is required: %s", shellRuleLines(findings))
+	}
+}
+
+func TestIsDockerfile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Dockerfile":         true,
+		"Dockerfile.dev":     true,
+		"app.Dockerfile":     true,
+		"Containerfile":      true,
+		"dockerfile.go":      false,
+		"docker-compose.yml": false,
+		"Dockerfiles":        false,
+	} {
+		if got := isDockerfile(name); got != want {
+			t.Errorf("isDockerfile(%q)=%v want %v", name, got, want)
+		}
+	}
+}
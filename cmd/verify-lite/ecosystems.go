@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultVerifyLiteConfig holds repo-level verify-lite settings.
+const defaultVerifyLiteConfig = ".ci-self/verify-lite.json"
+
+// verifyLiteConfig is the repo config read from defaultVerifyLiteConfig.
+type verifyLiteConfig struct {
+	// Ecosystems forces an ecosystem on (true) or off (false). Ecosystems
+	// not listed are enabled when their marker file is present.
+	Ecosystems map[string]bool `json:"ecosystems"`
+}
+
+func verifyLiteConfigPath() string {
+	return envOr("VERIFY_LITE_CONFIG", defaultVerifyLiteConfig)
+}
+
+func loadVerifyLiteConfig(path string) (verifyLiteConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return verifyLiteConfig{}, nil
+		}
+		return verifyLiteConfig{}, err
+	}
+	var cfg verifyLiteConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return verifyLiteConfig{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name := range cfg.Ecosystems {
+		if findEcosystem(name) == nil {
+			return verifyLiteConfig{}, fmt.Errorf("parse %s: unknown ecosystem %q", path, name)
+		}
+	}
+	return cfg, nil
+}
+
+// ecosystem detects a language toolchain at the repo root and contributes its
+// checks. Register new languages in ecosystems.
+type ecosystem struct {
+	name    string
+	markers []string
+	checks  func(cfg config, opts options) []check
+}
+
+var ecosystems = []ecosystem{
+	{name: "go", markers: []string{"go.mod", "go.work"}, checks: goChecks},
+	{name: "node", markers: []string{"package.json"}, checks: func(config, options) []check {
+		return []check{{name: "node", timeout: checkTimeout("node", 600), run: runNodeCheck}}
+	}},
+	{name: "rust", markers: []string{"Cargo.toml"}, checks: func(config, options) []check {
+		return []check{{name: "rust", timeout: checkTimeout("rust", 900), run: runRustCheck}}
+	}},
+	{name: "python", markers: []string{"pyproject.toml"}, checks: func(config, options) []check {
+		return []check{{name: "python", timeout: checkTimeout("python", 600), run: runPythonCheck}}
+	}},
+}
+
+func findEcosystem(name string) *ecosystem {
+	for i := range ecosystems {
+		if ecosystems[i].name == name {
+			return &ecosystems[i]
+		}
+	}
+	return nil
+}
+
+func (e ecosystem) detected() bool {
+	for _, marker := range e.markers {
+		if fileExists(marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledEcosystems applies the repo config on top of marker detection.
+func enabledEcosystems(cfg verifyLiteConfig) []ecosystem {
+	var out []ecosystem
+	for _, e := range ecosystems {
+		enabled, configured := cfg.Ecosystems[e.name]
+		if !configured {
+			enabled = e.detected()
+		}
+		if enabled {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func ecosystemNames(list []ecosystem) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(list))
+	for _, e := range list {
+		names = append(names, e.name)
+	}
+	return strings.Join(names, ",")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// goChecks shares one package index between the go checks when
+// --incremental is set, so go list runs once per verify-lite run.
+func goChecks(cfg config, opts options) []check {
+	var idx *goPackageIndex
+	if opts.incremental {
+		idx = newGoPackageIndex(verifyLiteCacheDir())
+	}
+	return []check{
+		{name: "gofmt", timeout: checkTimeout("gofmt", 120), run: func(ctx context.Context) checkOutcome {
+			return runGofmtCheck(ctx, idx)
+		}},
+		{name: "go_vet", timeout: checkTimeout("go_vet", 300), run: func(ctx context.Context) checkOutcome {
+			return runGoVetCheck(ctx, idx)
+		}},
+		{name: "go_test", timeout: checkTimeout("go_test", 600), run: func(ctx context.Context) checkOutcome {
+			return runGoTestCheck(ctx, cfg, opts, idx)
+		}},
+		{name: "go_vuln", timeout: checkTimeout("go_vuln", 120), run: runGoVulnCheck},
+	}
+}
+
+// nodeLockfiles maps lockfiles to the package manager that owns them, in
+// precedence order. Without a lockfile npm is used.
+var nodeLockfiles = []struct {
+	file    string
+	manager string
+}{
+	{file: "pnpm-lock.yaml", manager: "pnpm"},
+	{file: "yarn.lock", manager: "yarn"},
+	{file: "bun.lockb", manager: "bun"},
+	{file: "bun.lock", manager: "bun"},
+	{file: "package-lock.json", manager: "npm"},
+}
+
+type nodePackage struct {
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+func nodePackageManager() string {
+	for _, lock := range nodeLockfiles {
+		if fileExists(lock.file) {
+			return lock.manager
+		}
+	}
+	return "npm"
+}
+
+// nodeScripts returns the lint/test scripts package.json defines, skipping
+// npm's placeholder test script.
+func nodeScripts(pkg nodePackage) []string {
+	var scripts []string
+	for _, name := range []string{"lint", "test"} {
+		script := strings.TrimSpace(pkg.Scripts[name])
+		if script == "" || strings.Contains(script, "no test specified") {
+			continue
+		}
+		scripts = append(scripts, name)
+	}
+	return scripts
+}
+
+// runNodeCheck runs the lint and test scripts with the lockfile's package
+// manager. It never installs dependencies; a repo with dependencies but no
+// node_modules is skipped.
+func runNodeCheck(ctx context.Context) checkOutcome {
+	content, err := os.ReadFile("package.json")
+	if err != nil {
+		return checkOutcome{err: fmt.Errorf("node check failed: %w", err)}
+	}
+	var pkg nodePackage
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return checkOutcome{err: fmt.Errorf("node check failed: parse package.json: %w", err)}
+	}
+	scripts := nodeScripts(pkg)
+	if len(scripts) == 0 {
+		return checkOutcome{skip: "no_lint_or_test_script"}
+	}
+	if len(pkg.Dependencies)+len(pkg.DevDependencies) > 0 && !dirExists("node_modules") {
+		return checkOutcome{skip: "node_modules_missing"}
+	}
+	manager := nodePackageManager()
+	if _, err := exec.LookPath(manager); err != nil {
+		return checkOutcome{err: fmt.Errorf("%s command not found", manager)}
+	}
+	details := []string{"node_package_manager=" + manager}
+	for _, script := range scripts {
+		fmt.Printf("OK: verify-lite node step=%s manager=%s\n", script, manager)
+		if err := runCommand(ctx, manager, "run", script); err != nil {
+			return checkOutcome{details: details, err: fmt.Errorf("node %s failed: %w", script, err)}
+		}
+	}
+	return checkOutcome{details: details}
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// runRustCheck runs cargo fmt, clippy and test in order; they share the
+// target directory, so running them concurrently would only contend on locks.
+func runRustCheck(ctx context.Context) checkOutcome {
+	if _, err := exec.LookPath("cargo"); err != nil {
+		return checkOutcome{err: errors.New("cargo command not found")}
+	}
+	steps := [][]string{
+		{"fmt", "--all", "--check"},
+		{"clippy", "--all-targets", "--", "-D", "warnings"},
+		{"test"},
+	}
+	for _, args := range steps {
+		fmt.Printf("OK: verify-lite rust step=cargo_%s\n", args[0])
+		if err := runCommand(ctx, "cargo", args...); err != nil {
+			return checkOutcome{err: fmt.Errorf("cargo %s failed: %w", args[0], err)}
+		}
+	}
+	return checkOutcome{}
+}
+
+// pytestNoTestsCollected is pytest's exit code when nothing was collected.
+const pytestNoTestsCollected = 5
+
+// runPythonCheck runs ruff and pytest when they are installed.
+func runPythonCheck(ctx context.Context) checkOutcome {
+	var ran []string
+	if _, err := exec.LookPath("ruff"); err == nil {
+		fmt.Println("OK: verify-lite python step=ruff")
+		if err := runCommand(ctx, "ruff", "check", "."); err != nil {
+			return checkOutcome{err: fmt.Errorf("ruff check failed: %w", err)}
+		}
+		ran = append(ran, "ruff")
+	} else {
+		fmt.Println("SKIP: verify-lite python step=ruff reason=ruff_not_found")
+	}
+	if _, err := exec.LookPath("pytest"); err == nil {
+		fmt.Println("OK: verify-lite python step=pytest")
+		err := runCommand(ctx, "pytest", "-q")
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			ran = append(ran, "pytest")
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == pytestNoTestsCollected:
+			fmt.Println("SKIP: verify-lite python step=pytest reason=no_tests_collected")
+		default:
+			return checkOutcome{err: fmt.Errorf("pytest failed: %w", err)}
+		}
+	} else {
+		fmt.Println("SKIP: verify-lite python step=pytest reason=pytest_not_found")
+	}
+	if len(ran) == 0 {
+		return checkOutcome{skip: "no_python_tools"}
+	}
+	return checkOutcome{details: []string{"python_tools=" + strings.Join(ran, ",")}}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeToolPath puts logging stand-ins for the given commands first on PATH.
+func fakeToolPath(t *testing.T, logPath string, names ...string) {
+	t.Helper()
+	bin := t.TempDir()
+	for _, name := range names {
+		script := "#!/bin/sh\necho \"" + name + " $*\" >> " + logPath + "\n"
+		writeFiles(t, bin, map[string]string{name: script})
+	}
+	t.Setenv("PATH", bin)
+}
+
+func TestEnabledEcosystemsDetectsMarkersAndHonorsConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                    "module example.com/m\n",
+		"package.json":              "{}\n",
+		"Cargo.toml":                "[package]\n",
+		".ci-self/verify-lite.json": `{"ecosystems": {"rust": false, "python": true}}`,
+	})
+	t.Chdir(dir)
+
+	if got := ecosystemNames(enabledEcosystems(verifyLiteConfig{})); got != "go,node,rust" {
+		t.Fatalf("unexpected detected ecosystems: %s", got)
+	}
+	cfg, err := loadVerifyLiteConfig(defaultVerifyLiteConfig)
+	if err != nil {
+		t.Fatalf("loadVerifyLiteConfig returned error: %v", err)
+	}
+	if got := ecosystemNames(enabledEcosystems(cfg)); got != "go,node,python" {
+		t.Fatalf("unexpected configured ecosystems: %s", got)
+	}
+
+	writeFiles(t, dir, map[string]string{".ci-self/verify-lite.json": `{"ecosystems": {"cobol": true}}`})
+	if _, err := loadVerifyLiteConfig(defaultVerifyLiteConfig); err == nil {
+		t.Fatal("expected error for unknown ecosystem")
+	}
+}
+
+func TestRunNodeCheckUsesLockfilePackageManager(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":   `{"scripts": {"lint": "eslint .", "test": "vitest run", "build": "tsc"}}`,
+		"pnpm-lock.yaml": "lockfileVersion: '9.0'\n",
+	})
+	t.Chdir(dir)
+	logPath := filepath.Join(dir, "calls.log")
+	fakeToolPath(t, logPath, "pnpm", "npm")
+
+	outcome := runNodeCheck(context.Background())
+	if outcome.err != nil || outcome.skip != "" {
+		t.Fatalf("unexpected outcome: %+v", outcome)
+	}
+	calls, _ := os.ReadFile(logPath)
+	if string(calls) != "pnpm run lint\npnpm run test\n" {
+		t.Fatalf("unexpected calls:\n%s", calls)
+	}
+}
+
+func TestRunNodeCheckSkipsWithoutInstalledDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json": `{"scripts": {"test": "jest"}, "devDependencies": {"jest": "^29.0.0"}}`,
+	})
+	t.Chdir(dir)
+	if outcome := runNodeCheck(context.Background()); outcome.skip != "node_modules_missing" {
+		t.Fatalf("expected node_modules_missing skip, got %+v", outcome)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"package.json": `{"scripts": {"test": "echo \"Error: no test specified\" && exit 1"}}`,
+	})
+	if outcome := runNodeCheck(context.Background()); outcome.skip != "no_lint_or_test_script" {
+		t.Fatalf("expected placeholder test script to be skipped, got %+v", outcome)
+	}
+}
+
+func TestRunRustCheckRunsFmtClippyTest(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	logPath := filepath.Join(dir, "calls.log")
+	fakeToolPath(t, logPath, "cargo")
+
+	if outcome := runRustCheck(context.Background()); outcome.err != nil {
+		t.Fatalf("unexpected error: %v", outcome.err)
+	}
+	calls, _ := os.ReadFile(logPath)
+	want := "cargo fmt --all --check\ncargo clippy --all-targets -- -D warnings\ncargo test\n"
+	if string(calls) != want {
+		t.Fatalf("unexpected calls:\n%s", calls)
+	}
+}
+
+func TestRunPythonCheckSkipsMissingTools(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	if outcome := runPythonCheck(context.Background()); outcome.skip != "no_python_tools" {
+		t.Fatalf("expected skip without ruff/pytest, got %+v", outcome)
+	}
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	fakeToolPath(t, logPath, "ruff")
+	outcome := runPythonCheck(context.Background())
+	if outcome.err != nil || !strings.Contains(strings.Join(outcome.details, "\n"), "python_tools=ruff") {
+		t.Fatalf("unexpected outcome: %+v", outcome)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// finding is a single scanner result with its source location. line is 0
+// when the finding applies to the whole file (e.g. a signing file name).
+type finding struct {
+	rule    string
+	file    string
+	line    int
+	message string
+	// commit and author are set by the history scan.
+	commit string
+	author string
+}
+
+func (f finding) String() string {
+	location := f.file
+	if f.line > 0 {
+		location = fmt.Sprintf("%s:%d", f.file, f.line)
+	}
+	return fmt.Sprintf("%s %s: %s", location, f.rule, f.message)
+}
+
+// ruleMeta describes a rule for reports such as SARIF.
+type ruleMeta struct {
+	id               string
+	description      string
+	securitySeverity string
+}
+
+func allRuleMetas() []ruleMeta {
+	metas := append([]ruleMeta{}, secretRules...)
+	metas = append(metas, workflowRuleMetas()...)
+	metas = append(metas, shellRuleMetas()...)
+	metas = append(metas, dockerRuleMetas()...)
+	return append(metas, docRuleMetas()...)
+}
+
+// findingsError summarizes findings as the single status reason. The secret
+// and workflow prefixes keep the wording of the earlier fail-fast scans.
+func findingsError(findings []finding) error {
+	var secrets, workflows, shells, dockerfiles, docs []string
+	workflowIDs := map[string]bool{}
+	for _, meta := range workflowRuleMetas() {
+		workflowIDs[meta.id] = true
+	}
+	shellIDs := map[string]bool{}
+	for _, meta := range shellRuleMetas() {
+		shellIDs[meta.id] = true
+	}
+	dockerIDs := map[string]bool{}
+	for _, meta := range dockerRuleMetas() {
+		dockerIDs[meta.id] = true
+	}
+	docIDs := map[string]bool{}
+	for _, meta := range docRuleMetas() {
+		docIDs[meta.id] = true
+	}
+	for _, f := range findings {
+		switch {
+		case workflowIDs[f.rule]:
+			workflows = append(workflows, f.String())
+		case shellIDs[f.rule]:
+			shells = append(shells, f.String())
+		case dockerIDs[f.rule]:
+			dockerfiles = append(dockerfiles, f.String())
+		case docIDs[f.rule]:
+			docs = append(docs, f.String())
+		default:
+			secrets = append(secrets, fmt.Sprintf("file=%s %s", f.file, f.message))
+		}
+	}
+	var parts []string
+	if len(secrets) > 0 {
+		parts = append(parts, "secret scan matched "+strings.Join(secrets, ", "))
+	}
+	if len(workflows) > 0 {
+		parts = append(parts, "workflow policy violations: "+strings.Join(workflows, "; "))
+	}
+	if len(shells) > 0 {
+		parts = append(parts, "shell policy violations: "+strings.Join(shells, "; "))
+	}
+	if len(dockerfiles) > 0 {
+		parts = append(parts, "dockerfile policy violations: "+strings.Join(dockerfiles, "; "))
+	}
+	if len(docs) > 0 {
+		parts = append(parts, "markdown link errors: "+strings.Join(docs, "; "))
+	}
+	return fmt.Errorf("%s", strings.Join(parts, "; "))
+}
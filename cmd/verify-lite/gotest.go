@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// goTestOutputTail is how many output lines are kept per failing test.
+const goTestOutputTail = 10
+
+// goTestEvent is one line of `go test -json` (cmd/test2json) output.
+type goTestEvent struct {
+	Action     string
+	Package    string
+	Test       string
+	Elapsed    float64
+	Output     string
+	ImportPath string
+}
+
+type goTestFailure struct {
+	pkg    string
+	test   string
+	file   string
+	line   int
+	output []string
+}
+
+type goTestPackage struct {
+	name    string
+	result  string
+	elapsed float64
+}
+
+// goTestReport is the parsed result of one `go test -json ./...` run.
+type goTestReport struct {
+	packages []goTestPackage
+	failures []goTestFailure
+}
+
+func (r goTestReport) failedPackages() []string {
+	var out []string
+	for _, pkg := range r.packages {
+		if pkg.result == "fail" {
+			out = append(out, pkg.name)
+		}
+	}
+	return out
+}
+
+type goTestKey struct {
+	pkg  string
+	test string
+}
+
+// goTestLocationPattern matches the "file_test.go:42: message" prefix that
+// t.Error and t.Fatal put on failure output.
+var goTestLocationPattern = regexp.MustCompile(`^\s*([^\s:]+\.go):(\d+): `)
+
+// parseGoTestEvents reads a test2json stream. Package output and the output of
+// failing tests are echoed, which keeps the console close to plain `go test`.
+// Plain text lines (for example build errors printed by older toolchains) are
+// passed through unchanged.
+func parseGoTestEvents(r io.Reader, echo io.Writer) (goTestReport, error) {
+	outputs := map[goTestKey][]string{}
+	packages := map[string]*goTestPackage{}
+	var order []string
+	var failures []goTestFailure
+
+	pkgEntry := func(name string) *goTestPackage {
+		if pkg, ok := packages[name]; ok {
+			return pkg
+		}
+		pkg := &goTestPackage{name: name}
+		packages[name] = pkg
+		order = append(order, name)
+		return pkg
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		var ev goTestEvent
+		if len(raw) == 0 || raw[0] != '{' || json.Unmarshal(raw, &ev) != nil {
+			fmt.Fprintln(echo, string(raw))
+			continue
+		}
+		switch ev.Action {
+		case "output", "build-output":
+			pkg := ev.Package
+			if ev.Action == "build-output" {
+				// ImportPath looks like "pkg [pkg.test]" for test binaries.
+				pkg, _, _ = strings.Cut(ev.ImportPath, " ")
+			}
+			if ev.Test == "" {
+				fmt.Fprint(echo, ev.Output)
+			}
+			key := goTestKey{pkg: pkg, test: ev.Test}
+			lines := append(outputs[key], strings.TrimRight(ev.Output, "\n"))
+			if len(lines) > goTestOutputTail {
+				lines = lines[len(lines)-goTestOutputTail:]
+			}
+			outputs[key] = lines
+		case "pass", "fail", "skip":
+			if ev.Test != "" {
+				if ev.Action == "fail" {
+					output := outputs[goTestKey{pkg: ev.Package, test: ev.Test}]
+					for _, line := range output {
+						fmt.Fprintln(echo, line)
+					}
+					failures = append(failures, newGoTestFailure(ev.Package, ev.Test, output))
+				}
+				continue
+			}
+			pkg := pkgEntry(ev.Package)
+			pkg.result = ev.Action
+			pkg.elapsed = ev.Elapsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return goTestReport{}, fmt.Errorf("read go test -json: %w", err)
+	}
+
+	report := goTestReport{}
+	for _, name := range order {
+		pkg := packages[name]
+		report.packages = append(report.packages, *pkg)
+		if pkg.result == "fail" && !hasTestFailure(failures, name) {
+			// Build failures and TestMain/init panics fail the package
+			// without any test event, so report the package output itself.
+			failures = append(failures, newGoTestFailure(name, "", outputs[goTestKey{pkg: name}]))
+		}
+	}
+	report.failures = dropFailedParents(failures)
+	return report, nil
+}
+
+func newGoTestFailure(pkg, test string, output []string) goTestFailure {
+	failure := goTestFailure{pkg: pkg, test: test, output: append([]string(nil), output...)}
+	for _, line := range output {
+		if m := goTestLocationPattern.FindStringSubmatch(line); m != nil {
+			failure.file = m[1]
+			fmt.Sscanf(m[2], "%d", &failure.line)
+			break
+		}
+	}
+	return failure
+}
+
+func hasTestFailure(failures []goTestFailure, pkg string) bool {
+	for _, f := range failures {
+		if f.pkg == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// dropFailedParents removes a parent test when one of its subtests failed,
+// since the parent only fails because of the subtest.
+func dropFailedParents(failures []goTestFailure) []goTestFailure {
+	var out []goTestFailure
+	for _, f := range failures {
+		parent := false
+		for _, other := range failures {
+			if f.test != "" && other.pkg == f.pkg && strings.HasPrefix(other.test, f.test+"/") {
+				parent = true
+				break
+			}
+		}
+		if !parent {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// runGoTest runs `go test -json [extraArgs] pkgs` (./... when pkgs is empty)
+// and returns the parsed report along with the command error.
+func runGoTest(ctx context.Context, pkgs []string, extraArgs ...string) (goTestReport, error) {
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	args := append([]string{"test", "-json"}, extraArgs...)
+	args = append(args, pkgs...)
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return goTestReport{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return goTestReport{}, err
+	}
+	report, parseErr := parseGoTestEvents(stdout, os.Stdout)
+	waitErr := cmd.Wait()
+	if parseErr != nil {
+		return report, parseErr
+	}
+	if waitErr != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return report, fmt.Errorf("timeout exceeded (go %s)", strings.Join(args, " "))
+	}
+	return report, waitErr
+}
+
+// goTestDetails renders failing packages and tests as status file lines.
+func goTestDetails(report goTestReport, tablePath string) []string {
+	failedPackages := report.failedPackages()
+	details := []string{
+		fmt.Sprintf("go_test_packages=%d", len(report.packages)),
+		fmt.Sprintf("go_test_failed_packages=%d", len(failedPackages)),
+		fmt.Sprintf("go_test_failed_tests=%d", len(report.failures)),
+	}
+	if tablePath != "" {
+		details = append(details, "go_test_durations="+tablePath)
+	}
+	for _, pkg := range failedPackages {
+		details = append(details, "go_test_failed_package="+pkg)
+	}
+	for _, f := range report.failures {
+		test := f.test
+		if test == "" {
+			test = "-"
+		}
+		line := fmt.Sprintf("go_test_failure=package=%s test=%s", f.pkg, test)
+		if f.file != "" {
+			line += fmt.Sprintf(" file=%s line=%d", f.file, f.line)
+		}
+		details = append(details, line)
+		for _, out := range f.output {
+			details = append(details, fmt.Sprintf("go_test_failure_output=package=%s test=%s %s", f.pkg, test, strings.TrimSpace(out)))
+		}
+	}
+	return details
+}
+
+// goTestFailureReason replaces the bare "exit status 1" with the failing
+// packages and test names.
+func goTestFailureReason(report goTestReport) string {
+	var names []string
+	for _, f := range report.failures {
+		if f.test == "" {
+			names = append(names, f.pkg)
+			continue
+		}
+		names = append(names, f.pkg+"."+f.test)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("failed_tests=%d %s", len(report.failures), strings.Join(names, ","))
+}
+
+// printGoTestAnnotations emits GitHub Actions ::error annotations. modulePath
+// maps package import paths back to repository-relative directories.
+func printGoTestAnnotations(w io.Writer, report goTestReport, modulePath string) {
+	for _, f := range report.failures {
+		title := f.pkg
+		if f.test != "" {
+			title = f.pkg + "." + f.test
+		}
+		message := strings.Join(f.output, "\n")
+		if message == "" {
+			message = "go test failed"
+		}
+		props := []string{}
+		if f.file != "" {
+			props = append(props,
+				"file="+escapeAnnotationProperty(goTestFilePath(modulePath, f.pkg, f.file)),
+				fmt.Sprintf("line=%d", f.line),
+			)
+		}
+		props = append(props, "title="+escapeAnnotationProperty(title))
+		fmt.Fprintf(w, "::error %s::%s\n", strings.Join(props, ","), escapeAnnotation(message))
+	}
+}
+
+func goTestFilePath(modulePath, pkg, file string) string {
+	if modulePath == "" || strings.Contains(file, "/") {
+		return file
+	}
+	if pkg == modulePath {
+		return file
+	}
+	if rel, ok := strings.CutPrefix(pkg, modulePath+"/"); ok {
+		return path.Join(rel, file)
+	}
+	return file
+}
+
+// readModulePath returns the module path declared in ./go.mod, or "".
+func readModulePath() string {
+	content, err := os.ReadFile("go.mod")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+func escapeAnnotation(message string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(message)
+}
+
+func escapeAnnotationProperty(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(value)
+}
+
+// writeGoTestDurations writes a per-package duration table, slowest first.
+func writeGoTestDurations(path string, report goTestReport) error {
+	packages := append([]goTestPackage(nil), report.packages...)
+	sort.SliceStable(packages, func(i, j int) bool { return packages[i].elapsed > packages[j].elapsed })
+
+	var b strings.Builder
+	b.WriteString("| package | result | seconds |\n|---|---|---:|\n")
+	for _, pkg := range packages {
+		result := pkg.result
+		if result == "" {
+			result = "unknown"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %.3f |\n", pkg.name, result, pkg.elapsed)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGoTestEventsReportsFailingTests(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"Action":"start","Package":"example.com/m/a"}`,
+		`{"Action":"run","Package":"example.com/m/a","Test":"TestParent"}`,
+		`{"Action":"run","Package":"example.com/m/a","Test":"TestParent/sub"}`,
+		`{"Action":"output","Package":"example.com/m/a","Test":"TestParent/sub","Output":"    a_test.go:17: want 1, got 2\n"}`,
+		`{"Action":"fail","Package":"example.com/m/a","Test":"TestParent/sub","Elapsed":0}`,
+		`{"Action":"fail","Package":"example.com/m/a","Test":"TestParent","Elapsed":0}`,
+		`{"Action":"pass","Package":"example.com/m/a","Test":"TestOK","Elapsed":0}`,
+		`{"Action":"output","Package":"example.com/m/a","Output":"FAIL\n"}`,
+		`{"Action":"fail","Package":"example.com/m/a","Elapsed":1.5}`,
+		`{"Action":"pass","Package":"example.com/m/b","Elapsed":0.25}`,
+		`{"ImportPath":"example.com/m/c [example.com/m/c.test]","Action":"build-output","Output":"c/c.go:3:1: syntax error\n"}`,
+		`{"Action":"fail","Package":"example.com/m/c","Elapsed":0,"FailedBuild":"example.com/m/c [example.com/m/c.test]"}`,
+		`# plain text from an older toolchain`,
+	}, "\n")
+	var echo bytes.Buffer
+	report, err := parseGoTestEvents(strings.NewReader(stream), &echo)
+	if err != nil {
+		t.Fatalf("parseGoTestEvents returned error: %v", err)
+	}
+	if got := strings.Join(report.failedPackages(), ","); got != "example.com/m/a,example.com/m/c" {
+		t.Fatalf("unexpected failed packages: %s", got)
+	}
+	if len(report.failures) != 2 {
+		t.Fatalf("parent test should be folded into its subtest: %+v", report.failures)
+	}
+	sub := report.failures[0]
+	if sub.test != "TestParent/sub" || sub.file != "a_test.go" || sub.line != 17 {
+		t.Fatalf("unexpected subtest failure: %+v", sub)
+	}
+	build := report.failures[1]
+	if build.pkg != "example.com/m/c" || build.test != "" || len(build.output) != 1 {
+		t.Fatalf("build failure should carry build output: %+v", build)
+	}
+	if !strings.Contains(echo.String(), "plain text from an older toolchain") {
+		t.Fatalf("non-JSON lines should be echoed: %q", echo.String())
+	}
+
+	details := strings.Join(goTestDetails(report, ""), "\n")
+	for _, want := range []string{
+		"go_test_failed_packages=2",
+		"go_test_failed_package=example.com/m/a",
+		"go_test_failure=package=example.com/m/a test=TestParent/sub file=a_test.go line=17",
+		"go_test_failure_output=package=example.com/m/a test=TestParent/sub a_test.go:17: want 1, got 2",
+	} {
+		if !strings.Contains(details, want) {
+			t.Fatalf("details missing %q:\n%s", want, details)
+		}
+	}
+	if reason := goTestFailureReason(report); reason != "failed_tests=2 example.com/m/a.TestParent/sub,example.com/m/c" {
+		t.Fatalf("unexpected reason: %s", reason)
+	}
+
+	var annotations bytes.Buffer
+	printGoTestAnnotations(&annotations, report, "example.com/m")
+	if !strings.HasPrefix(annotations.String(), "::error file=a/a_test.go,line=17,title=example.com/m/a.TestParent/sub::") {
+		t.Fatalf("unexpected annotation: %q", annotations.String())
+	}
+}
+
+func TestWriteGoTestDurationsSortsSlowestFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "durations.md")
+	report := goTestReport{packages: []goTestPackage{
+		{name: "fast", result: "pass", elapsed: 0.1},
+		{name: "slow", result: "fail", elapsed: 2},
+	}}
+	if err := writeGoTestDurations(path, report); err != nil {
+		t.Fatalf("writeGoTestDurations returned error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read table: %v", err)
+	}
+	if !strings.Contains(string(content), "| slow | fail | 2.000 |\n| fast | pass | 0.100 |") {
+		t.Fatalf("unexpected table:\n%s", content)
+	}
+}
+
+func TestRunGoTestReportsRealFailure(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/fixture\n\ngo 1.21\n",
+		"pkg/pkg_test.go":     "package pkg\n\nimport \"testing\"\n\nfunc TestBroken(t *testing.T) {\n\tt.Fatal(\"boom\")\n}\n",
+		"other/other_test.go": "package other\n\nimport \"testing\"\n\nfunc TestFine(t *testing.T) {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	t.Setenv("GOFLAGS", "-mod=mod")
+
+	report, err := runGoTest(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected go test failure")
+	}
+	if len(report.failures) != 1 || report.failures[0].test != "TestBroken" || report.failures[0].line != 6 {
+		t.Fatalf("unexpected failures: %+v", report.failures)
+	}
+	if len(report.packages) != 2 {
+		t.Fatalf("unexpected packages: %+v", report.packages)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// historyBlob is the first commit/path/author that introduced a blob.
+type historyBlob struct {
+	sha    string
+	commit string
+	author string
+	path   string
+}
+
+// runHistoryMode audits every blob reachable from git history with the same
+// rules as the working tree scan. Deleted secrets still live in history, so
+// this is the audit step of the leak response in docs/ci/SECRETS_POLICY.md.
+func runHistoryMode(ctx context.Context, since string) ([]finding, []string, error) {
+	fmt.Println("OK: verify-lite secret_history_scan start")
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil, errors.New("git command not found")
+	}
+
+	blobs, pathFindings, err := listHistoryBlobs(ctx, since)
+	if err != nil {
+		return nil, nil, fmt.Errorf("secret history scan failed: %w", err)
+	}
+	contentFindings, err := scanHistoryBlobs(ctx, blobs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("secret history scan failed: %w", err)
+	}
+	findings := append(pathFindings, contentFindings...)
+
+	details := []string{
+		"mode=history",
+	}
+	if since != "" {
+		details = append(details, "since="+since)
+	}
+	details = append(details,
+		fmt.Sprintf("history_blobs_scanned=%d", len(blobs)),
+		fmt.Sprintf("history_findings=%d", len(findings)),
+	)
+	for _, f := range findings {
+		line := formatHistoryFinding(f)
+		fmt.Printf("ERROR: verify-lite secret_history_scan %s\n", line)
+		details = append(details, "history_finding="+line)
+	}
+	if len(findings) > 0 {
+		return findings, details, fmt.Errorf("secret history scan matched findings=%d", len(findings))
+	}
+	fmt.Printf("OK: verify-lite secret_history_scan done blobs=%d\n", len(blobs))
+	return findings, details, nil
+}
+
+func formatHistoryFinding(f finding) string {
+	return fmt.Sprintf("commit=%s path=%s author=%q %s", f.commit, f.file, f.author, f.message)
+}
+
+// historyLogArgs walks every ref (or only commits not reachable from since)
+// oldest first, so the first occurrence of a blob is the commit that added it.
+func historyLogArgs(since string) []string {
+	args := []string{
+		"-c", "core.quotePath=false",
+		"log", "--all",
+		"--reverse", "--no-renames", "--raw", "--no-abbrev",
+		"--diff-merges=first-parent",
+		"--format=%x00%H%x09%an <%ae>",
+	}
+	if since != "" {
+		args = append(args, "--not", since)
+	}
+	return args
+}
+
+// listHistoryBlobs returns each distinct blob once, attributed to the commit
+// that introduced it. Mobile signing file names are matched per path here
+// because they do not depend on blob content.
+func listHistoryBlobs(ctx context.Context, since string) ([]historyBlob, []finding, error) {
+	cmd := exec.CommandContext(ctx, "git", historyLogArgs(since)...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	blobs, findings, parseErr := parseHistoryLog(stdout)
+	if parseErr != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, nil, parseErr
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, nil, fmt.Errorf("git log: %w", err)
+	}
+	return blobs, findings, nil
+}
+
+func parseHistoryLog(r io.Reader) ([]historyBlob, []finding, error) {
+	var blobs []historyBlob
+	var findings []finding
+	seenBlobs := map[string]bool{}
+	seenPaths := map[string]bool{}
+	commit, author := "", ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x00") {
+			header := strings.TrimPrefix(line, "\x00")
+			commit, author, _ = strings.Cut(header, "\t")
+			continue
+		}
+		sha, path, ok := parseRawDiffLine(line)
+		if !ok {
+			continue
+		}
+		if isMobileSensitivePath(path) && !seenPaths[path] {
+			seenPaths[path] = true
+			findings = append(findings, finding{rule: "mobile-signing-file", file: path, message: "pattern=" + mobileSigningPattern, commit: commit, author: author})
+		}
+		if seenBlobs[sha] {
+			continue
+		}
+		seenBlobs[sha] = true
+		blobs = append(blobs, historyBlob{sha: sha, commit: commit, author: author, path: path})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read git log: %w", err)
+	}
+	return blobs, findings, nil
+}
+
+// parseRawDiffLine reads ":<old mode> <new mode> <old sha> <new sha> <status>\t<path>"
+// and returns the new blob. Deletions, submodules and empty shas are ignored.
+func parseRawDiffLine(line string) (string, string, bool) {
+	if !strings.HasPrefix(line, ":") {
+		return "", "", false
+	}
+	meta, path, ok := strings.Cut(line, "\t")
+	if !ok {
+		return "", "", false
+	}
+	fields := strings.Fields(strings.TrimPrefix(meta, ":"))
+	if len(fields) < 5 {
+		return "", "", false
+	}
+	newMode, newSHA, status := fields[1], fields[3], fields[4]
+	if strings.HasPrefix(status, "D") || newMode == "160000" || strings.Trim(newSHA, "0") == "" {
+		return "", "", false
+	}
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+	}
+	return newSHA, path, true
+}
+
+// scanHistoryBlobs streams blob contents through a single git cat-file --batch
+// process and applies the secret content patterns to each one.
+func scanHistoryBlobs(ctx context.Context, blobs []historyBlob) ([]finding, error) {
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		w := bufio.NewWriter(stdin)
+		for _, blob := range blobs {
+			fmt.Fprintln(w, blob.sha)
+		}
+		_ = w.Flush()
+		_ = stdin.Close()
+	}()
+
+	findings, readErr := readBatchBlobs(bufio.NewReader(stdout), blobs)
+	if readErr != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, readErr
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return findings, nil
+}
+
+// readBatchBlobs streams each blob through the content scanner, so large
+// blobs and archives get the same treatment as working tree files.
+func readBatchBlobs(r *bufio.Reader, blobs []historyBlob) ([]finding, error) {
+	scanner := newContentScanner()
+	for _, blob := range blobs {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("cat-file header for %s: %w", blob.sha, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) == 2 && fields[1] == "missing" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("cat-file unexpected header %q", strings.TrimSpace(header))
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cat-file size %q: %w", fields[2], err)
+		}
+		body := io.LimitReader(r, size)
+		if fields[1] == "blob" {
+			before := len(scanner.findings)
+			scanner.scanContent(blob.path, body)
+			for i := before; i < len(scanner.findings); i++ {
+				scanner.findings[i].commit = blob.commit
+				scanner.findings[i].author = blob.author
+			}
+		}
+		// Body is followed by a single LF.
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return nil, err
+		}
+		if _, err := r.Discard(1); err != nil {
+			return nil, err
+		}
+	}
+	return scanner.findings, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	base := []string{"-c", "user.name=Leak Author", "-c", "user.email=leak@example.com", "-c", "commit.gpgsign=false"}
+	cmd := exec.Command("git", append(base, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestHistoryModeFindsDeletedSecret(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q")
+
+	secret := "webhook=https://discord.com/api/" + "webhooks/123/abc\n"
+	if err := os.WriteFile(filepath.Join(repo, "notify.env"), []byte(secret), 0o644); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	// Same blob under a second name must only be reported once.
+	if err := os.WriteFile(filepath.Join(repo, "copy.env"), []byte(secret), 0o644); err != nil {
+		t.Fatalf("write copy: %v", err)
+	}
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "add webhook")
+	leakCommit := runGit(t, repo, "rev-parse", "HEAD")
+
+	runGit(t, repo, "rm", "-q", "notify.env", "copy.env")
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("clean\n"), 0o644); err != nil {
+		t.Fatalf("write readme: %v", err)
+	}
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "remove webhook")
+
+	t.Chdir(repo)
+	findings, details, err := runHistoryMode(context.Background(), "")
+	if err == nil {
+		t.Fatal("expected deleted secret to be found in history")
+	}
+	joined := strings.Join(details, "\n")
+	if len(findings) != 1 || strings.Count(joined, "history_finding=") != 1 {
+		t.Fatalf("expected exactly one deduplicated finding:\n%s", joined)
+	}
+	for _, want := range []string{"commit=" + leakCommit, `author="Leak Author <leak@example.com>"`, "pattern=discord.com/api/"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("details missing %q:\n%s", want, joined)
+		}
+	}
+
+	_, details, err = runHistoryMode(context.Background(), leakCommit)
+	if err != nil {
+		t.Fatalf("expected commits after the leak to be clean: %v\n%s", err, strings.Join(details, "\n"))
+	}
+}
+
+func TestHistoryModeReportsMobileSigningPath(t *testing.T) {
+	blobs, findings, err := parseHistoryLog(strings.NewReader(
+		"\x00aaaa\tDev <dev@example.com>\n" +
+			"\n" +
+			":000000 100644 0000000000000000000000000000000000000000 1111111111111111111111111111111111111111 A\tandroid/release.jks\n" +
+			":100644 000000 2222222222222222222222222222222222222222 0000000000000000000000000000000000000000 D\tgone.txt\n",
+	))
+	if err != nil {
+		t.Fatalf("parseHistoryLog returned error: %v", err)
+	}
+	if len(blobs) != 1 || blobs[0].path != "android/release.jks" {
+		t.Fatalf("unexpected blobs: %+v", blobs)
+	}
+	if len(findings) != 1 || findings[0].rule != "mobile-signing-file" || findings[0].commit != "aaaa" {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestParseOptionsSinceRequiresHistory(t *testing.T) {
+	if _, err := parseOptions([]string{"--since", "main"}); err == nil {
+		t.Fatal("expected --since without --history to fail")
+	}
+	opts, err := parseOptions([]string{"--history", "--since", "main"})
+	if err != nil {
+		t.Fatalf("parseOptions returned error: %v", err)
+	}
+	if !opts.history || opts.since != "main" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheMaxAgeDays is how long an unused cache entry is kept.
+const defaultCacheMaxAgeDays = 14
+
+// verifyLiteCacheDir holds the --incremental result cache.
+func verifyLiteCacheDir() string {
+	if dir := strings.TrimSpace(os.Getenv("VERIFY_LITE_CACHE_DIR")); dir != "" {
+		return dir
+	}
+	cacheHome := strings.TrimSpace(os.Getenv("XDG_CACHE_HOME"))
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "ci-self", "verify-lite")
+}
+
+// resultCache records passing results as empty marker files named by key.
+// A hit refreshes the file time so pruning only drops unused entries.
+type resultCache struct {
+	dir string
+}
+
+func (c resultCache) path(check, key string) string {
+	return filepath.Join(c.dir, check, key[:2], key)
+}
+
+func (c resultCache) hit(check, key string) bool {
+	path := c.path(check, key)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+func (c resultCache) store(check, key, importPath string) error {
+	path := c.path(check, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(importPath+"\n"), 0o644)
+}
+
+// prune removes entries not used within maxAge and returns how many.
+func (c resultCache) prune(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, os.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// goPackage is one package of the main module with its cache keys.
+type goPackage struct {
+	importPath string
+	goFiles    []string
+	// sourceKey covers the package's own files and the toolchain (gofmt).
+	sourceKey string
+	// buildKey adds the dependency closure of the test binary (vet, test).
+	buildKey string
+}
+
+// goPackageIndex lists packages once for all incremental go checks.
+type goPackageIndex struct {
+	cache  resultCache
+	mu     sync.Mutex
+	listed bool
+	pkgs   []goPackage
+	err    error
+}
+
+func newGoPackageIndex(dir string) *goPackageIndex {
+	return &goPackageIndex{cache: resultCache{dir: dir}}
+}
+
+// packages lists on first use and shares the result. A listing cut short by
+// the caller's context is not kept, so one check timing out does not fail
+// the others.
+func (idx *goPackageIndex) packages(ctx context.Context) ([]goPackage, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.listed {
+		return idx.pkgs, idx.err
+	}
+	pkgs, err := listGoPackages(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	idx.listed, idx.pkgs, idx.err = true, pkgs, err
+	return pkgs, err
+}
+
+// partition splits packages into cache hits and misses for one check.
+func (idx *goPackageIndex) partition(check string, pkgs []goPackage, key func(goPackage) string) (hits, misses []goPackage) {
+	for _, p := range pkgs {
+		if idx.cache.hit(check, key(p)) {
+			hits = append(hits, p)
+		} else {
+			misses = append(misses, p)
+		}
+	}
+	return hits, misses
+}
+
+func (idx *goPackageIndex) storeAll(check string, pkgs []goPackage, key func(goPackage) string) {
+	for _, p := range pkgs {
+		if err := idx.cache.store(check, key(p), p.importPath); err != nil {
+			fmt.Printf("SKIP: verify-lite cache store check=%s reason=%s\n", check, err.Error())
+			return
+		}
+	}
+}
+
+func cacheDetail(check string, hits, misses int) string {
+	return fmt.Sprintf("cache=check=%s hits=%d misses=%d", check, hits, misses)
+}
+
+func sourceKey(p goPackage) string { return p.sourceKey }
+func buildKey(p goPackage) string  { return p.buildKey }
+
+func importPaths(pkgs []goPackage) []string {
+	out := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		out = append(out, p.importPath)
+	}
+	return out
+}
+
+// goListEntry is the part of `go list -deps -test -json` the keys need.
+type goListEntry struct {
+	ImportPath      string        `json:"ImportPath"`
+	Name            string        `json:"Name"`
+	Dir             string        `json:"Dir"`
+	Standard        bool          `json:"Standard"`
+	DepOnly         bool          `json:"DepOnly"`
+	ForTest         string        `json:"ForTest"`
+	Module          *goListModule `json:"Module"`
+	Deps            []string      `json:"Deps"`
+	GoFiles         []string      `json:"GoFiles"`
+	CgoFiles        []string      `json:"CgoFiles"`
+	TestGoFiles     []string      `json:"TestGoFiles"`
+	XTestGoFiles    []string      `json:"XTestGoFiles"`
+	IgnoredGoFiles  []string      `json:"IgnoredGoFiles"`
+	EmbedFiles      []string      `json:"EmbedFiles"`
+	TestEmbedFiles  []string      `json:"TestEmbedFiles"`
+	XTestEmbedFiles []string      `json:"XTestEmbedFiles"`
+}
+
+func listGoPackages(ctx context.Context) ([]goPackage, error) {
+	toolchain, err := runCommandCapture(ctx, "go", "env", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED")
+	if err != nil {
+		return nil, fmt.Errorf("go env failed: %w", err)
+	}
+	listing, err := runCommandCapture(ctx, "go", "list", "-deps", "-test", "-json", "./...")
+	if err != nil {
+		return nil, fmt.Errorf("go list -deps -test failed: %w", err)
+	}
+	entries, err := decodeJSONStream[goListEntry](strings.NewReader(listing))
+	if err != nil {
+		return nil, fmt.Errorf("parse go list -deps -test: %w", err)
+	}
+	return goPackageKeys(entries, toolchain, hashPackageFiles)
+}
+
+// goPackageKeys derives the cache keys. Local dependencies contribute their
+// own source hash, external modules their version; the standard library is
+// covered by the toolchain version.
+func goPackageKeys(entries []goListEntry, toolchain string, hashFiles func(goListEntry) (string, error)) ([]goPackage, error) {
+	byPath := map[string]goListEntry{}
+	testMain := map[string]goListEntry{}
+	var local []goListEntry
+	for _, e := range entries {
+		switch {
+		case e.ForTest != "" || strings.Contains(e.ImportPath, " "):
+			continue
+		case e.Name == "main" && strings.HasSuffix(e.ImportPath, ".test"):
+			// The generated test main depends on everything the test binary links.
+			testMain[strings.TrimSuffix(e.ImportPath, ".test")] = e
+			continue
+		}
+		byPath[e.ImportPath] = e
+		if !e.DepOnly && !e.Standard {
+			local = append(local, e)
+		}
+	}
+
+	sources := map[string]string{}
+	for _, e := range local {
+		h, err := hashFiles(e)
+		if err != nil {
+			return nil, err
+		}
+		sources[e.ImportPath] = hashStrings(toolchain, h)
+	}
+
+	pkgs := make([]goPackage, 0, len(local))
+	for _, e := range local {
+		deps := e.Deps
+		if main, ok := testMain[e.ImportPath]; ok {
+			deps = main.Deps
+		}
+		parts := []string{sources[e.ImportPath]}
+		seen := map[string]bool{}
+		for _, dep := range deps {
+			dep, _, _ = strings.Cut(dep, " ")
+			if dep == e.ImportPath || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			if src, ok := sources[dep]; ok {
+				parts = append(parts, dep+"="+src)
+				continue
+			}
+			d := byPath[dep]
+			if d.Standard {
+				continue
+			}
+			if d.Module != nil {
+				version := d.Module.Version
+				if d.Module.Replace != nil {
+					version = d.Module.Replace.Path + "@" + d.Module.Replace.Version
+				}
+				parts = append(parts, dep+"@"+version)
+			}
+		}
+		sort.Strings(parts[1:])
+		var goFiles []string
+		for _, group := range [][]string{e.GoFiles, e.CgoFiles, e.TestGoFiles, e.XTestGoFiles, e.IgnoredGoFiles} {
+			for _, name := range group {
+				if strings.HasSuffix(name, ".go") {
+					goFiles = append(goFiles, filepath.Join(e.Dir, name))
+				}
+			}
+		}
+		sort.Strings(goFiles)
+		pkgs = append(pkgs, goPackage{
+			importPath: e.ImportPath,
+			goFiles:    goFiles,
+			sourceKey:  sources[e.ImportPath],
+			buildKey:   hashStrings(parts...),
+		})
+	}
+	return pkgs, nil
+}
+
+func hashStrings(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		io.WriteString(h, p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashPackageFiles hashes every file directly in the package directory, its
+// embedded files and testdata/. Files a test reads from elsewhere are not
+// tracked, so CI should keep running without --incremental.
+func hashPackageFiles(e goListEntry) (string, error) {
+	files := map[string]bool{}
+	entries, err := os.ReadDir(e.Dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			files[entry.Name()] = true
+		}
+	}
+	for _, group := range [][]string{e.EmbedFiles, e.TestEmbedFiles, e.XTestEmbedFiles} {
+		for _, name := range group {
+			files[filepath.ToSlash(name)] = true
+		}
+	}
+	testdata := filepath.Join(e.Dir, "testdata")
+	_ = filepath.WalkDir(testdata, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(e.Dir, path)
+		if relErr == nil {
+			files[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(e.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(h, "%s %x\n", name, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// runIncrementalGofmt runs gofmt -l only on the files of changed packages.
+func runIncrementalGofmt(ctx context.Context, idx *goPackageIndex) checkOutcome {
+	pkgs, err := idx.packages(ctx)
+	if err != nil {
+		return checkOutcome{err: err}
+	}
+	hits, misses := idx.partition("gofmt", pkgs, sourceKey)
+	details := []string{cacheDetail("gofmt", len(hits), len(misses))}
+	var files []string
+	for _, p := range misses {
+		files = append(files, p.goFiles...)
+	}
+	if len(files) > 0 {
+		unformatted, err := runCommandCapture(ctx, "gofmt", append([]string{"-l"}, files...)...)
+		if err != nil {
+			return checkOutcome{details: details, err: fmt.Errorf("gofmt -l failed: %w", err)}
+		}
+		if unformatted = strings.TrimSpace(unformatted); unformatted != "" {
+			return checkOutcome{details: details, err: fmt.Errorf("gofmt check failed; unformatted files:\n%s", unformatted)}
+		}
+	}
+	idx.storeAll("gofmt", misses, sourceKey)
+	return checkOutcome{details: details}
+}
+
+// runIncrementalGoVet vets only changed packages. go vet does not report
+// results per package, so a failure caches nothing.
+func runIncrementalGoVet(ctx context.Context, idx *goPackageIndex) checkOutcome {
+	pkgs, err := idx.packages(ctx)
+	if err != nil {
+		return checkOutcome{err: err}
+	}
+	hits, misses := idx.partition("go_vet", pkgs, buildKey)
+	details := []string{cacheDetail("go_vet", len(hits), len(misses))}
+	if len(misses) > 0 {
+		if err := runCommand(ctx, "go", append([]string{"vet"}, importPaths(misses)...)...); err != nil {
+			return checkOutcome{details: details, err: fmt.Errorf("go vet failed: %w", err)}
+		}
+	}
+	idx.storeAll("go_vet", misses, buildKey)
+	return checkOutcome{details: details}
+}
+
+// passedGoTestPackages returns the misses go test reported as pass or skip
+// (no test files).
+func passedGoTestPackages(report goTestReport, misses []goPackage) []goPackage {
+	passed := map[string]bool{}
+	for _, p := range report.packages {
+		if p.result == "pass" || p.result == "skip" {
+			passed[p.name] = true
+		}
+	}
+	var out []goPackage
+	for _, p := range misses {
+		if passed[p.importPath] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// pruneIncrementalCache drops entries unused for VERIFY_LITE_CACHE_MAX_AGE_DAYS.
+func pruneIncrementalCache(dir string) string {
+	days, err := envOrInt("VERIFY_LITE_CACHE_MAX_AGE_DAYS", defaultCacheMaxAgeDays)
+	if err != nil {
+		fmt.Printf("SKIP: verify-lite cache_prune reason=invalid_env key=VERIFY_LITE_CACHE_MAX_AGE_DAYS default=%d\n", defaultCacheMaxAgeDays)
+		days = defaultCacheMaxAgeDays
+	}
+	removed, err := resultCache{dir: dir}.prune(time.Duration(days) * 24 * time.Hour)
+	if err != nil {
+		fmt.Printf("SKIP: verify-lite cache_prune reason=%s\n", err.Error())
+	}
+	return fmt.Sprintf("cache_pruned=%d", removed)
+}
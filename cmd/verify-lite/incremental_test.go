@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoPackageKeysPropagateDependencyChanges(t *testing.T) {
+	entries := []goListEntry{
+		{ImportPath: "fmt", Standard: true, DepOnly: true},
+		{ImportPath: "example.com/ext", DepOnly: true, Module: &goListModule{Path: "example.com/ext", Version: "v1.2.0"}},
+		{ImportPath: "example.com/m/a", Dir: "/m/a", GoFiles: []string{"a.go"}, Deps: []string{"fmt"}},
+		{ImportPath: "example.com/m/b", Dir: "/m/b", GoFiles: []string{"b.go"}, Deps: []string{"example.com/ext", "example.com/m/a", "fmt"}},
+		{ImportPath: "example.com/m/b [example.com/m/b.test]", ForTest: "example.com/m/b"},
+		{ImportPath: "example.com/m/b.test", Name: "main", Deps: []string{"example.com/ext", "example.com/m/a", "example.com/m/b", "example.com/m/b [example.com/m/b.test]", "testing"}},
+	}
+	hashes := map[string]string{"/m/a": "a1", "/m/b": "b1"}
+	keys := func(toolchain string) map[string]goPackage {
+		pkgs, err := goPackageKeys(entries, toolchain, func(e goListEntry) (string, error) { return hashes[e.Dir], nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]goPackage{}
+		for _, p := range pkgs {
+			out[p.importPath] = p
+		}
+		return out
+	}
+
+	base := keys("go1.25.6")
+	if len(base) != 2 {
+		t.Fatalf("expected only main module packages, got %+v", base)
+	}
+	if got := base["example.com/m/b"].goFiles; !slices.Equal(got, []string{filepath.Join("/m/b", "b.go")}) {
+		t.Fatalf("goFiles=%v", got)
+	}
+
+	hashes["/m/a"] = "a2"
+	changed := keys("go1.25.6")
+	if changed["example.com/m/a"].sourceKey == base["example.com/m/a"].sourceKey {
+		t.Fatal("source change must change sourceKey")
+	}
+	if changed["example.com/m/b"].sourceKey != base["example.com/m/b"].sourceKey {
+		t.Fatal("dependency change must not change the dependent's sourceKey")
+	}
+	if changed["example.com/m/b"].buildKey == base["example.com/m/b"].buildKey {
+		t.Fatal("dependency change must change the dependent's buildKey")
+	}
+
+	hashes["/m/a"] = "a1"
+	entries[1].Module.Version = "v1.3.0"
+	if keys("go1.25.6")["example.com/m/b"].buildKey == base["example.com/m/b"].buildKey {
+		t.Fatal("module version bump must change buildKey")
+	}
+	entries[1].Module.Version = "v1.2.0"
+	if keys("go1.26.0")["example.com/m/a"].buildKey == base["example.com/m/a"].buildKey {
+		t.Fatal("toolchain change must change every key")
+	}
+}
+
+func TestResultCacheHitStorePrune(t *testing.T) {
+	cache := resultCache{dir: t.TempDir()}
+	key := hashStrings("pkg")
+	if cache.hit("go_vet", key) {
+		t.Fatal("empty cache must miss")
+	}
+	if err := cache.store("go_vet", key, "example.com/m/a"); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.hit("go_vet", key) || cache.hit("go_test", key) {
+		t.Fatal("entries are per check")
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(cache.path("go_vet", key), old, old); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := cache.prune(24 * time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("removed=%d err=%v", removed, err)
+	}
+	if cache.hit("go_vet", key) {
+		t.Fatal("pruned entry must miss")
+	}
+	if removed, err := (resultCache{dir: filepath.Join(cache.dir, "missing")}).prune(time.Hour); err != nil || removed != 0 {
+		t.Fatalf("missing dir: removed=%d err=%v", removed, err)
+	}
+}
+
+// TestIncrementalChecksReuseResults runs the real go tool twice over a small
+// module and edits one package in between.
+func TestIncrementalChecksReuseResults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet and go test")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":           "module example.com/m\n\ngo 1.21\n",
+		"a/a.go":           "package a\n\nfunc A() int { return 1 }\n",
+		"b/b.go":           "package b\n\nimport \"example.com/m/a\"\n\nfunc B() int { return a.A() + 1 }\n",
+		"b/b_test.go":      "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {\n\tif B() != 2 {\n\t\tt.Fatal(B())\n\t}\n}\n",
+		"c/c.go":           "package c\n\nfunc C() {}\n",
+		"c/c_test.go":      "package c\n\nimport \"testing\"\n\nfunc TestC(t *testing.T) {}\n",
+		"d/nothing/doc.go": "// Package nothing has no tests.\npackage nothing\n",
+	})
+	t.Chdir(dir)
+	t.Setenv("GOFLAGS", "-mod=mod")
+	cacheDir := t.TempDir()
+	cfg := config{outDir: t.TempDir()}
+
+	run := func() []string {
+		t.Helper()
+		idx := newGoPackageIndex(cacheDir)
+		ctx := context.Background()
+		var details []string
+		for _, outcome := range []checkOutcome{
+			runIncrementalGofmt(ctx, idx),
+			runIncrementalGoVet(ctx, idx),
+			runGoTestCheck(ctx, cfg, options{}, idx),
+		} {
+			if outcome.err != nil {
+				t.Fatalf("err=%v details=%v", outcome.err, outcome.details)
+			}
+			details = append(details, outcome.details...)
+		}
+		return details
+	}
+	cacheLines := func(details []string) []string {
+		var out []string
+		for _, d := range details {
+			if strings.HasPrefix(d, "cache=") {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+
+	first := cacheLines(run())
+	want := []string{
+		"cache=check=gofmt hits=0 misses=4",
+		"cache=check=go_vet hits=0 misses=4",
+		"cache=check=go_test hits=0 misses=4",
+	}
+	if !slices.Equal(first, want) {
+		t.Fatalf("first run: %v", first)
+	}
+
+	// a changes: its source key and the build keys of a and b (imports a).
+	writeFiles(t, dir, map[string]string{"a/a.go": "package a\n\n// A returns one.\nfunc A() int { return 1 }\n"})
+	second := cacheLines(run())
+	want = []string{
+		"cache=check=gofmt hits=3 misses=1",
+		"cache=check=go_vet hits=2 misses=2",
+		"cache=check=go_test hits=2 misses=2",
+	}
+	if !slices.Equal(second, want) {
+		t.Fatalf("second run: %v", second)
+	}
+}
+
+func TestIncrementalGoTestDoesNotCacheFailures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":            "module example.com/m\n\ngo 1.21\n",
+		"bad/bad_test.go":   "package bad\n\nimport \"testing\"\n\nfunc TestBad(t *testing.T) { t.Fatal(\"boom\") }\n",
+		"good/good_test.go": "package good\n\nimport \"testing\"\n\nfunc TestGood(t *testing.T) {}\n",
+	})
+	t.Chdir(dir)
+	t.Setenv("GOFLAGS", "-mod=mod")
+	cacheDir := t.TempDir()
+	cfg := config{outDir: t.TempDir()}
+
+	for i, want := range []string{"hits=0 misses=2", "hits=1 misses=1"} {
+		outcome := runGoTestCheck(context.Background(), cfg, options{}, newGoPackageIndex(cacheDir))
+		if outcome.err == nil {
+			t.Fatalf("run %d: expected failure", i)
+		}
+		if !slices.Contains(outcome.details, "cache=check=go_test "+want) {
+			t.Fatalf("run %d: details=%v", i, outcome.details)
+		}
+	}
+}
+
+func TestGoPackageIndexDoesNotKeepCancelledListing(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nfunc A() int { return 1 }\n",
+	})
+	t.Chdir(dir)
+	idx := newGoPackageIndex(t.TempDir())
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := idx.packages(cancelled); err == nil {
+		t.Fatal("expected the cancelled listing to fail")
+	}
+	pkgs, err := idx.packages(context.Background())
+	if err != nil || len(pkgs) != 1 || pkgs[0].importPath != "example.com/m/a" {
+		t.Fatalf("pkgs=%+v err=%v", pkgs, err)
+	}
+}
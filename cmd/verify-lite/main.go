@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -19,20 +20,33 @@ func main() {
 	defer func() {
 		if r := recover(); r != nil {
 			cfg := loadConfig()
-			_ = writeStatus(cfg, "ERROR", fmt.Sprintf("panic=%v", r))
+			_ = writeStatus(cfg, "ERROR", fmt.Sprintf("panic=%v", r), nil)
+			publishGHA(cfg, os.Getenv, os.Stdout)
 			fmt.Printf("ERROR: verify-lite panic=%v\n", r)
 			fmt.Println("STATUS: ERROR")
 		}
 	}()
 
 	cfg := loadConfig()
-	if err := run(cfg); err != nil {
-		_ = writeStatus(cfg, "ERROR", err.Error())
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		_ = writeStatus(cfg, "ERROR", err.Error(), nil)
+		publishGHA(cfg, os.Getenv, os.Stdout)
+		fmt.Printf("ERROR: verify-lite parse_options err=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		return
+	}
+
+	details, err := run(cfg, opts)
+	if err != nil {
+		_ = writeStatus(cfg, "ERROR", err.Error(), details)
+		publishGHA(cfg, os.Getenv, os.Stdout)
 		fmt.Printf("ERROR: verify-lite %s\n", err.Error())
 		fmt.Println("STATUS: ERROR")
 		return
 	}
-	_ = writeStatus(cfg, "OK", "")
+	_ = writeStatus(cfg, "OK", "", details)
+	publishGHA(cfg, os.Getenv, os.Stdout)
 	fmt.Println("OK: verify-lite completed")
 	fmt.Println("STATUS: OK")
 }
@@ -44,6 +58,61 @@ type config struct {
 	timeoutSec int
 }
 
+type options struct {
+	history bool
+	since   string
+	// staged checks only the git index (pre-commit fast mode).
+	staged    bool
+	sarifPath string
+	// updateCoverageBaseline rewrites the coverage baseline after tests pass.
+	updateCoverageBaseline bool
+	// failFast runs checks sequentially and stops at the first failure.
+	failFast bool
+	// incremental reuses cached gofmt/vet/test results of unchanged packages.
+	incremental bool
+}
+
+func parseOptions(args []string) (options, error) {
+	fs := flag.NewFlagSet("verify-lite", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	history := fs.Bool("history", false, "scan git history blobs instead of the working tree")
+	since := fs.String("since", "", "with --history, only scan commits not reachable from this ref")
+	staged := fs.Bool("staged", false, "only scan staged files for secrets and gofmt (pre-commit fast mode)")
+	sarifPath := fs.String("sarif", "", "write secret/workflow findings as SARIF 2.1.0 to this path")
+	failFast := fs.Bool("fail-fast", false, "run checks one by one and stop at the first failure")
+	incremental := fs.Bool("incremental", false, "skip gofmt/go vet/go test for packages whose files, dependencies and toolchain are unchanged")
+	updateCoverageBaseline := fs.Bool("update-coverage-baseline", false, "write measured coverage to the baseline declared in the coverage config")
+
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	if len(fs.Args()) > 0 {
+		return options{}, errors.New("unexpected positional arguments")
+	}
+	if *since != "" && !*history {
+		return options{}, errors.New("--since requires --history")
+	}
+	if *staged && *history {
+		return options{}, errors.New("--staged cannot be combined with --history")
+	}
+	if *incremental && (*history || *staged) {
+		return options{}, errors.New("--incremental cannot be combined with --history or --staged")
+	}
+	if *updateCoverageBaseline && (*history || *staged) {
+		return options{}, errors.New("--update-coverage-baseline cannot be combined with --history or --staged")
+	}
+	return options{
+		history:                *history,
+		since:                  *since,
+		staged:                 *staged,
+		sarifPath:              *sarifPath,
+		updateCoverageBaseline: *updateCoverageBaseline,
+		failFast:               *failFast,
+		incremental:            *incremental,
+	}, nil
+}
+
 func loadConfig() config {
 	timeoutSec, err := envOrInt("VERIFY_LITE_TIMEOUT_SEC", 600)
 	if err != nil {
@@ -57,81 +126,134 @@ func loadConfig() config {
 	}
 }
 
-func run(cfg config) error {
+func run(cfg config, opts options) ([]string, error) {
 	if err := os.Chdir(cfg.repoDir); err != nil {
-		return fmt.Errorf("chdir %s: %w", cfg.repoDir, err)
+		return nil, fmt.Errorf("chdir %s: %w", cfg.repoDir, err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.timeoutSec)*time.Second)
 	defer cancel()
 
-	if err := runSecretPatternScan(); err != nil {
-		return err
-	}
-	if err := runWorkflowPolicyScan(); err != nil {
-		return err
-	}
-	if err := runGoOfficialChecks(ctx); err != nil {
-		return err
+	if opts.history || opts.staged {
+		var findings []finding
+		var details []string
+		var err error
+		if opts.history {
+			findings, details, err = runHistoryMode(ctx, opts.since)
+		} else {
+			findings, details, err = runStagedMode(ctx)
+		}
+		if sarifErr := reportSARIF(opts.sarifPath, findings, &details); sarifErr != nil && err == nil {
+			err = sarifErr
+		}
+		return details, err
 	}
-	return nil
-}
 
-func runGoOfficialChecks(ctx context.Context) error {
-	fmt.Println("OK: verify-lite go_checks start")
+	repoCfg, err := loadVerifyLiteConfig(verifyLiteConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("verify-lite config: %w", err)
+	}
+	enabled := enabledEcosystems(repoCfg)
+	fmt.Printf("OK: verify-lite ecosystems=%s\n", ecosystemNames(enabled))
 
-	if _, err := exec.LookPath("go"); err != nil {
-		return errors.New("go command not found")
+	results := runChecks(ctx, verifyChecks(cfg, opts, enabled), opts.failFast)
+	var findings []finding
+	for _, r := range results {
+		findings = append(findings, r.findings...)
 	}
-	if _, err := exec.LookPath("gofmt"); err != nil {
-		return errors.New("gofmt command not found")
+	details := []string{
+		"ecosystems=" + ecosystemNames(enabled),
+		fmt.Sprintf("findings=%d", len(findings)),
 	}
-
-	unformatted, err := runCommandCapture(ctx, "gofmt", "-l", ".")
-	if err != nil {
-		return fmt.Errorf("gofmt -l failed: %w", err)
+	sarifErr := reportSARIF(opts.sarifPath, findings, &details)
+	for _, r := range results {
+		details = append(details, r.statusLine())
+	}
+	for _, r := range results {
+		details = append(details, r.details...)
+	}
+	if opts.incremental {
+		dir := verifyLiteCacheDir()
+		details = append(details, "cache_dir="+dir, pruneIncrementalCache(dir))
 	}
-	unformatted = strings.TrimSpace(unformatted)
-	if unformatted != "" {
-		return fmt.Errorf("gofmt check failed; unformatted files:\n%s", unformatted)
+	if err := checksError(results); err != nil {
+		return details, err
 	}
+	return details, sarifErr
+}
 
-	if err := runCommand(ctx, "go", "vet", "./..."); err != nil {
-		return fmt.Errorf("go vet failed: %w", err)
+func reportSARIF(path string, findings []finding, details *[]string) error {
+	if path == "" {
+		return nil
 	}
-	if err := runCommand(ctx, "go", "test", "./..."); err != nil {
-		return fmt.Errorf("go test failed: %w", err)
+	if err := writeSARIF(path, findings); err != nil {
+		return fmt.Errorf("write sarif %s: %w", path, err)
 	}
-	fmt.Println("OK: verify-lite go_checks done")
+	fmt.Printf("OK: verify-lite sarif path=%s results=%d\n", path, len(findings))
+	*details = append(*details, "sarif="+path)
 	return nil
 }
 
-func runSecretPatternScan() error {
+// secretPattern maps a literal marker to the rule it is reported under. The
+// literals are split so this file does not match itself.
+type secretPattern struct {
+	rule    string
+	literal string
+}
+
+var secretPatterns = []secretPattern{
+	{rule: "discord-webhook", literal: "discord.com/api/" + "webhooks/"},
+	{rule: "discord-webhook", literal: "discordapp.com/api/" + "webhooks/"},
+	{rule: "slack-webhook", literal: "hooks.slack.com/" + "services/"},
+	{rule: "private-key-block", literal: "-----BEGIN " + "PRIVATE KEY-----"},
+	{rule: "private-key-block", literal: "-----BEGIN " + "RSA PRIVATE KEY-----"},
+	{rule: "private-key-block", literal: "-----BEGIN " + "EC PRIVATE KEY-----"},
+	{rule: "private-key-block", literal: "-----BEGIN " + "OPENSSH PRIVATE KEY-----"},
+}
+
+var secretRules = []ruleMeta{
+	{id: "discord-webhook", description: "Discord webhook URL committed to the repository", securitySeverity: "9.0"},
+	{id: "slack-webhook", description: "Slack incoming webhook URL committed to the repository", securitySeverity: "9.0"},
+	{id: "private-key-block", description: "PEM private key block committed to the repository", securitySeverity: "9.0"},
+	{id: "google-service-account-key", description: "Google service account JSON with an embedded private key", securitySeverity: "9.0"},
+	{id: "mobile-signing-file", description: "Mobile signing material (certificate, keystore, provisioning profile or fastlane env) committed to the repository", securitySeverity: "9.0"},
+}
+
+const mobileSigningPattern = "mobile_signing_file"
+
+type secretMatch struct {
+	rule    string
+	pattern string
+	line    int
+}
+
+func secretFinding(path string, m secretMatch) finding {
+	return finding{rule: m.rule, file: path, line: m.line, message: "pattern=" + m.pattern}
+}
+
+// scanSkipDirs are never walked by the working tree scanners.
+var scanSkipDirs = map[string]bool{
+	".git":         true,
+	"out":          true,
+	"cache":        true,
+	"tmp":          true,
+	"target":       true,
+	"node_modules": true,
+}
+
+// runSecretPatternScan walks the working tree and collects every secret
+// finding instead of stopping at the first one. Files are streamed, so size
+// is no limit, and archives are opened (see contentScanner).
+func runSecretPatternScan() ([]finding, []string, error) {
 	fmt.Println("OK: verify-lite secret_scan start")
-	patterns := []string{
-		"discord.com/api/" + "webhooks/",
-		"discordapp.com/api/" + "webhooks/",
-		"hooks.slack.com/" + "services/",
-		"-----BEGIN " + "PRIVATE KEY-----",
-		"-----BEGIN " + "RSA PRIVATE KEY-----",
-		"-----BEGIN " + "EC PRIVATE KEY-----",
-		"-----BEGIN " + "OPENSSH PRIVATE KEY-----",
-	}
-	skipDirs := map[string]bool{
-		".git":         true,
-		"out":          true,
-		"cache":        true,
-		"tmp":          true,
-		"target":       true,
-		"node_modules": true,
-	}
-	found := ""
+	scanner := newContentScanner()
+	var findings []finding
 	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
 		if d.IsDir() {
-			if skipDirs[d.Name()] {
+			if scanSkipDirs[d.Name()] {
 				return filepath.SkipDir
 			}
 			return nil
@@ -139,45 +261,28 @@ func runSecretPatternScan() error {
 		if strings.HasPrefix(path, ".git/") {
 			return nil
 		}
+		path = filepath.ToSlash(path)
 		if isMobileSensitivePath(path) {
-			found = fmt.Sprintf("file=%s pattern=mobile_signing_file", path)
-			return errors.New("secret pattern matched")
-		}
-		info, err := d.Info()
-		if err != nil {
-			return nil
-		}
-		if info.Size() > 1024*1024 {
-			return nil
-		}
-		content, err := os.ReadFile(path)
-		if err != nil {
+			findings = append(findings, finding{rule: "mobile-signing-file", file: path, message: "pattern=" + mobileSigningPattern})
 			return nil
 		}
-		if bytes.IndexByte(content, 0) >= 0 {
+		if !d.Type().IsRegular() {
 			return nil
 		}
-		text := string(content)
-		for _, pattern := range patterns {
-			if strings.Contains(text, pattern) {
-				found = fmt.Sprintf("file=%s pattern=%s", path, pattern)
-				return errors.New("secret pattern matched")
-			}
-		}
-		if containsGoogleServiceAccountPrivateKey(text) {
-			found = fmt.Sprintf("file=%s pattern=google_service_account_private_key", path)
-			return errors.New("secret pattern matched")
-		}
+		_ = scanner.scanFile(path)
 		return nil
 	})
 	if err != nil {
-		if found != "" {
-			return fmt.Errorf("secret scan matched %s", found)
-		}
-		return fmt.Errorf("secret scan failed: %w", err)
+		return nil, nil, fmt.Errorf("secret scan failed: %w", err)
 	}
-	fmt.Println("OK: verify-lite secret_scan done")
-	return nil
+	findings = append(findings, scanner.findings...)
+	for _, f := range findings {
+		fmt.Printf("ERROR: verify-lite secret_scan %s\n", f)
+	}
+	if len(findings) == 0 {
+		fmt.Printf("OK: verify-lite secret_scan done files=%d archives=%d\n", scanner.files, scanner.archives)
+	}
+	return findings, scanner.details(), nil
 }
 
 func isMobileSensitivePath(path string) bool {
@@ -212,83 +317,6 @@ func containsGoogleServiceAccountPrivateKey(text string) bool {
 		strings.Contains(text, "-----BEGIN "+"PRIVATE KEY-----")
 }
 
-func runWorkflowPolicyScan() error {
-	fmt.Println("OK: verify-lite workflow_policy_scan start")
-	root := filepath.Join(".github", "workflows")
-	info, err := os.Stat(root)
-	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("SKIP: verify-lite workflow_policy_scan reason=missing_.github/workflows")
-			return nil
-		}
-		return fmt.Errorf("workflow policy scan stat failed: %w", err)
-	}
-	if !info.IsDir() {
-		return errors.New(".github/workflows is not a directory")
-	}
-
-	usesRefPattern := regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
-	var violations []string
-
-	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if !strings.HasSuffix(d.Name(), ".yml") && !strings.HasSuffix(d.Name(), ".yaml") {
-			return nil
-		}
-		content, err := os.ReadFile(path)
-		if err != nil {
-			violations = append(violations, fmt.Sprintf("%s: read_failed", path))
-			return nil
-		}
-		text := string(content)
-		if strings.Contains(text, "pull_request_target:") {
-			violations = append(violations, fmt.Sprintf("%s: forbidden pull_request_target", path))
-		}
-
-		lines := strings.Split(text, "\n")
-		for idx, line := range lines {
-			trim := strings.TrimSpace(line)
-			if strings.HasPrefix(trim, "- ") {
-				trim = strings.TrimSpace(strings.TrimPrefix(trim, "- "))
-			}
-			if !strings.HasPrefix(trim, "uses:") {
-				continue
-			}
-			ref := strings.TrimSpace(strings.TrimPrefix(trim, "uses:"))
-			ref = strings.Trim(ref, `"'`)
-			if ref == "" {
-				violations = append(violations, fmt.Sprintf("%s:%d empty uses ref", path, idx+1))
-				continue
-			}
-			if strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "docker://") {
-				continue
-			}
-			parts := strings.Split(ref, "@")
-			if len(parts) != 2 {
-				violations = append(violations, fmt.Sprintf("%s:%d unpinned uses (%s)", path, idx+1, ref))
-				continue
-			}
-			if !usesRefPattern.MatchString(parts[1]) {
-				violations = append(violations, fmt.Sprintf("%s:%d non-SHA uses (%s)", path, idx+1, ref))
-			}
-		}
-		return nil
-	})
-	if walkErr != nil {
-		return fmt.Errorf("workflow policy scan failed: %w", walkErr)
-	}
-	if len(violations) > 0 {
-		return fmt.Errorf("workflow policy violations: %s", strings.Join(violations, "; "))
-	}
-	fmt.Println("OK: verify-lite workflow_policy_scan done")
-	return nil
-}
-
 func runCommand(ctx context.Context, name string, args ...string) error {
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdout = os.Stdout
@@ -329,7 +357,7 @@ func envOrInt(key string, fallback int) (int, error) {
 	return value, nil
 }
 
-func writeStatus(cfg config, status, reason string) error {
+func writeStatus(cfg config, status, reason string, details []string) error {
 	if err := os.MkdirAll(cfg.outDir, 0o755); err != nil {
 		return err
 	}
@@ -346,6 +374,7 @@ func writeStatus(cfg config, status, reason string) error {
 		fmt.Sprintf("status=%s", status),
 		fmt.Sprintf("repo_dir=%s", cfg.repoDir),
 	}
+	lines = append(lines, details...)
 	if reason != "" {
 		lines = append(lines, "ERROR: reason="+reason)
 		lines = append(lines, "reason="+reason)
@@ -49,6 +49,40 @@ func TestContainsGoogleServiceAccountPrivateKey(t *testing.T) {
 	}
 }
 
+func TestSecretPatternScanCollectsAllFindings(t *testing.T) {
+	repo := t.TempDir()
+	files := map[string]string{
+		"a.txt":               "ok\nhttps://hooks.slack.com/" + "services/T/B/x\n",
+		"b.txt":               "-----BEGIN " + "RSA PRIVATE KEY-----\n",
+		"android/release.jks": "binary",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	t.Chdir(repo)
+	findings, _, err := runSecretPatternScan()
+	if err != nil {
+		t.Fatalf("secret scan failed: %v", err)
+	}
+	rules := map[string]finding{}
+	for _, f := range findings {
+		rules[f.rule] = f
+	}
+	if len(findings) != 3 || rules["slack-webhook"].line != 2 || rules["private-key-block"].file != "b.txt" || rules["mobile-signing-file"].file != "android/release.jks" {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+	if reason := findingsError(findings).Error(); !strings.Contains(reason, "secret scan matched file=") {
+		t.Fatalf("unexpected reason: %s", reason)
+	}
+}
+
 func TestWorkflowPolicyScanRejectsUnpinnedStepUses(t *testing.T) {
 	repo := t.TempDir()
 	workflowDir := filepath.Join(repo, ".github", "workflows")
@@ -57,6 +91,8 @@ func TestWorkflowPolicyScanRejectsUnpinnedStepUses(t *testing.T) {
 	}
 	workflow := `name: verify
 on: workflow_dispatch
+permissions:
+  contents: read
 jobs:
   verify:
     runs-on: ubuntu-latest
@@ -68,12 +104,15 @@ jobs:
 	}
 
 	t.Chdir(repo)
-	err := runWorkflowPolicyScan()
-	if err == nil {
+	findings, err := runWorkflowPolicyScan()
+	if err != nil {
+		t.Fatalf("workflow policy scan failed: %v", err)
+	}
+	if len(findings) == 0 {
 		t.Fatal("expected unpinned step uses to fail")
 	}
-	if !strings.Contains(err.Error(), "non-SHA uses") {
-		t.Fatalf("unexpected error: %v", err)
+	if !strings.Contains(findingsError(findings).Error(), "non-SHA uses") {
+		t.Fatalf("unexpected findings: %v", findings)
 	}
 }
 
@@ -85,6 +124,8 @@ func TestWorkflowPolicyScanAcceptsPinnedStepUses(t *testing.T) {
 	}
 	workflow := `name: verify
 on: workflow_dispatch
+permissions:
+  contents: read
 jobs:
   verify:
     runs-on: ubuntu-latest
@@ -96,8 +137,8 @@ jobs:
 	}
 
 	t.Chdir(repo)
-	if err := runWorkflowPolicyScan(); err != nil {
-		t.Fatalf("expected pinned step uses to pass: %v", err)
+	if findings, err := runWorkflowPolicyScan(); err != nil || len(findings) > 0 {
+		t.Fatalf("expected pinned step uses to pass: %v %v", err, findings)
 	}
 }
 
@@ -109,6 +150,8 @@ func TestWorkflowPolicyScanAcceptsUppercasePinnedStepUses(t *testing.T) {
 	}
 	workflow := `name: verify
 on: workflow_dispatch
+permissions:
+  contents: read
 jobs:
   verify:
     runs-on: ubuntu-latest
@@ -120,7 +163,7 @@ jobs:
 	}
 
 	t.Chdir(repo)
-	if err := runWorkflowPolicyScan(); err != nil {
-		t.Fatalf("expected uppercase pinned step uses to pass: %v", err)
+	if findings, err := runWorkflowPolicyScan(); err != nil || len(findings) > 0 {
+		t.Fatalf("expected uppercase pinned step uses to pass: %v %v", err, findings)
 	}
 }
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// docRule is a Markdown link convention checked offline on every *.md file.
+type docRule struct {
+	id          string
+	description string
+}
+
+const (
+	docRuleBrokenLink     = "md-broken-link"
+	docRuleBrokenAnchor   = "md-broken-anchor"
+	docRuleMissingCommand = "md-missing-command"
+	docRuleUnknownFlag    = "md-unknown-flag"
+)
+
+var docRules = []docRule{
+	{id: docRuleBrokenLink, description: "Relative Markdown links must point to an existing file"},
+	{id: docRuleBrokenAnchor, description: "#fragments must match a heading or anchor of the linked document"},
+	{id: docRuleMissingCommand, description: "go run ./cmd/<x> and ops/ci/<x>.sh references must exist in the repository"},
+	{id: docRuleUnknownFlag, description: "Commands in code must only use flags the command still accepts"},
+}
+
+func docRuleMetas() []ruleMeta {
+	metas := make([]ruleMeta, 0, len(docRules))
+	for _, rule := range docRules {
+		metas = append(metas, ruleMeta{id: rule.id, description: rule.description, securitySeverity: "1.0"})
+	}
+	return metas
+}
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextPattern        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	inlineCodePattern    = regexp.MustCompile("`+[^`]*`+")
+	inlineLinkPattern    = regexp.MustCompile(`!?\[((?:[^\[\]]|\[[^\]]*\])*)\]\(\s*(<[^>]*>|[^()\s]*(?:\([^()\s]*\)[^()\s]*)*)(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+	referenceDefPattern  = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*(<[^>]*>|\S+)`)
+	htmlAnchorPattern    = regexp.MustCompile(`<a\s[^>]*(?:name|id)\s*=\s*["']([^"']+)["']`)
+	goRunCommandPattern  = regexp.MustCompile(`\bgo run (\./cmd/[\w.-]+)/?`)
+	opsScriptPattern     = regexp.MustCompile("(?:^|[\\s`\"'(=])(?:\\./)?(ops/ci/[\\w.-]+\\.sh)\\b")
+	flagTokenPattern     = regexp.MustCompile(`^(--?[A-Za-z0-9][\w-]*)`)
+	goFlagDefPattern     = regexp.MustCompile(`\.(?:String|Bool|Int|Int64|Uint|Uint64|Float64|Duration|Func|BoolFunc|TextVar)(?:Var)?\([^"\n]*"([^"]+)"`)
+	goFlagLiteralPattern = regexp.MustCompile(`"(--?[A-Za-z][\w-]*)"`)
+	shellCaseFlagPattern = regexp.MustCompile(`(?m)^\s*((?:-[\w-]+\|)*-[\w-]+)\)`)
+	linkSchemePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:`)
+	commandStopTokens    = map[string]bool{"|": true, "||": true, "&&": true, ";": true, ">": true, ">>": true, "2>": true, "2>&1": true, "--": true}
+)
+
+// markdownDoc is the part of a Markdown file the checks need. Lines inside
+// fenced code blocks are kept separately so links are only read from prose.
+type markdownDoc struct {
+	path    string
+	anchors map[string]bool
+	// prose holds non-code lines with inline code spans blanked out.
+	prose map[int]string
+	// code holds fenced block lines (joined across trailing backslashes)
+	// and inline code spans, keyed by line.
+	code map[int][]string
+	// raw keeps every line for command path references.
+	raw []string
+}
+
+func parseMarkdown(docPath, content string) markdownDoc {
+	doc := markdownDoc{path: docPath, anchors: map[string]bool{}, prose: map[int]string{}, code: map[int][]string{}}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	doc.raw = lines
+	slugCounts := map[string]int{}
+	addHeading := func(text string) {
+		slug := headingSlug(text)
+		if n := slugCounts[slug]; n > 0 {
+			doc.anchors[fmt.Sprintf("%s-%d", slug, n)] = true
+		} else {
+			doc.anchors[slug] = true
+		}
+		slugCounts[slug]++
+	}
+
+	fence := ""
+	pending, pendingLine := "", 0
+	prevProse := ""
+	for i, line := range lines {
+		lineNo := i + 1
+		if fence != "" {
+			if m := fencePattern.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(line[strings.Index(line, m[1])+len(m[1]):]) == "" {
+				fence = ""
+				continue
+			}
+			if pending == "" {
+				pendingLine = lineNo
+			}
+			trimmed := strings.TrimRight(line, " \t")
+			if strings.HasSuffix(trimmed, "\\") {
+				pending += strings.TrimSuffix(trimmed, "\\") + " "
+				continue
+			}
+			doc.code[pendingLine] = append(doc.code[pendingLine], pending+line)
+			pending = ""
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			prevProse = ""
+			continue
+		}
+
+		for _, span := range inlineCodePattern.FindAllString(line, -1) {
+			doc.code[lineNo] = append(doc.code[lineNo], strings.Trim(span, "` "))
+		}
+		prose := inlineCodePattern.ReplaceAllStringFunc(line, func(s string) string { return strings.Repeat(" ", len(s)) })
+		doc.prose[lineNo] = prose
+		for _, m := range htmlAnchorPattern.FindAllStringSubmatch(prose, -1) {
+			doc.anchors[m[1]] = true
+		}
+		if m := atxHeadingPattern.FindStringSubmatch(line); m != nil {
+			addHeading(m[2])
+			prevProse = ""
+			continue
+		}
+		if setextPattern.MatchString(line) && strings.TrimSpace(prevProse) != "" && !strings.HasPrefix(strings.TrimSpace(prevProse), "-") {
+			addHeading(strings.TrimSpace(prevProse))
+			prevProse = ""
+			continue
+		}
+		prevProse = line
+	}
+	if pending != "" {
+		doc.code[pendingLine] = append(doc.code[pendingLine], pending)
+	}
+	return doc
+}
+
+var (
+	headingLinkPattern   = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	headingInlineMarkup  = strings.NewReplacer("`", "", "*", "", "~~", "")
+	headingHTMLTagsRegex = regexp.MustCompile(`<[^>]+>`)
+)
+
+// headingSlug follows GitHub: markup is dropped, letters and digits of any
+// script are kept lowercased, spaces become hyphens and other punctuation
+// (including full-width 「（）：」) is removed.
+func headingSlug(text string) string {
+	text = headingLinkPattern.ReplaceAllString(text, "$1")
+	text = headingHTMLTagsRegex.ReplaceAllString(text, "")
+	text = headingInlineMarkup.Replace(strings.TrimSpace(text))
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' || r == '-':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// markdownLinks returns the link targets of one prose line.
+func markdownLinks(line string) []string {
+	var targets []string
+	for _, m := range inlineLinkPattern.FindAllStringSubmatch(line, -1) {
+		targets = append(targets, strings.Trim(m[2], "<>"))
+	}
+	if m := referenceDefPattern.FindStringSubmatch(line); m != nil {
+		targets = append(targets, strings.Trim(m[1], "<>"))
+	}
+	return targets
+}
+
+// commandFlags is the set of flags a command accepts. goFlags commands use
+// the flag package, which treats -name and --name alike.
+type commandFlags struct {
+	flags   map[string]bool
+	goFlags bool
+}
+
+func (c commandFlags) accepts(flag string) bool {
+	if flag == "-h" || flag == "--help" || c.flags[flag] {
+		return true
+	}
+	return c.goFlags && c.flags["-"+strings.TrimLeft(flag, "-")]
+}
+
+// loadCommandFlags collects flag names from flag definitions and "--x"
+// literals in cmd/<x>/*.go, or from case patterns in a shell script. ok is
+// false when none are found; such commands parse arguments in a way this
+// check cannot follow, so their flags are not checked.
+func loadCommandFlags(target string) (commandFlags, bool) {
+	flags := commandFlags{flags: map[string]bool{}}
+	if strings.HasSuffix(target, ".sh") {
+		content, err := os.ReadFile(filepath.FromSlash(target))
+		if err != nil {
+			return flags, false
+		}
+		for _, m := range shellCaseFlagPattern.FindAllStringSubmatch(string(content), -1) {
+			for _, alt := range strings.Split(m[1], "|") {
+				flags.flags[alt] = true
+			}
+		}
+		return flags, len(flags.flags) > 0
+	}
+	flags.goFlags = true
+	files, _ := filepath.Glob(filepath.Join(filepath.FromSlash(target), "*.go"))
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, m := range goFlagDefPattern.FindAllStringSubmatch(string(content), -1) {
+			flags.flags["-"+m[1]] = true
+		}
+		for _, m := range goFlagLiteralPattern.FindAllStringSubmatch(string(content), -1) {
+			flags.flags["-"+strings.TrimLeft(m[1], "-")] = true
+		}
+	}
+	return flags, len(flags.flags) > 0
+}
+
+// commandInvocations finds `go run ./cmd/<x>` and `ops/ci/<x>.sh` in a code
+// line and returns each target with the flags passed to it.
+func commandInvocations(line string) map[string][]string {
+	out := map[string][]string{}
+	type hit struct {
+		target string
+		end    int
+	}
+	var hits []hit
+	for _, m := range goRunCommandPattern.FindAllStringSubmatchIndex(line, -1) {
+		hits = append(hits, hit{target: strings.TrimPrefix(line[m[2]:m[3]], "./"), end: m[1]})
+	}
+	for _, m := range opsScriptPattern.FindAllStringSubmatchIndex(line, -1) {
+		hits = append(hits, hit{target: line[m[2]:m[3]], end: m[1]})
+	}
+	for _, h := range hits {
+		flags := []string{}
+		for _, token := range strings.Fields(line[h.end:]) {
+			if commandStopTokens[token] || strings.HasPrefix(token, "#") {
+				break
+			}
+			if m := flagTokenPattern.FindStringSubmatch(token); m != nil {
+				flags = append(flags, m[1])
+			}
+		}
+		out[h.target] = append(out[h.target], flags...)
+	}
+	return out
+}
+
+// commandReferences lists every command path mentioned in the document.
+func commandReferences(line string) []string {
+	var targets []string
+	for _, m := range goRunCommandPattern.FindAllStringSubmatch(line, -1) {
+		targets = append(targets, strings.TrimPrefix(m[1], "./"))
+	}
+	for _, m := range opsScriptPattern.FindAllStringSubmatch(line, -1) {
+		targets = append(targets, m[1])
+	}
+	return targets
+}
+
+// markdownChecker caches parsed targets and command flags across files.
+type markdownChecker struct {
+	docs  map[string]*markdownDoc
+	flags map[string]*commandFlags
+}
+
+func newMarkdownChecker() *markdownChecker {
+	return &markdownChecker{docs: map[string]*markdownDoc{}, flags: map[string]*commandFlags{}}
+}
+
+func (c *markdownChecker) doc(docPath string) (*markdownDoc, bool) {
+	if doc, ok := c.docs[docPath]; ok {
+		return doc, doc != nil
+	}
+	content, err := os.ReadFile(filepath.FromSlash(docPath))
+	if err != nil {
+		c.docs[docPath] = nil
+		return nil, false
+	}
+	doc := parseMarkdown(docPath, string(content))
+	c.docs[docPath] = &doc
+	return &doc, true
+}
+
+func (c *markdownChecker) commandFlags(target string) (commandFlags, bool) {
+	if flags, ok := c.flags[target]; ok {
+		return *flags, flags.flags != nil
+	}
+	flags, ok := loadCommandFlags(target)
+	if !ok {
+		flags.flags = nil
+	}
+	c.flags[target] = &flags
+	return flags, ok
+}
+
+// commandExists reports whether a referenced command is present. References
+// under a top-level directory the repo does not have (e.g. ops/ci in a repo
+// that only documents ci-self usage) are not checked.
+func commandExists(target string) (exists, checked bool) {
+	root := strings.SplitN(target, "/", 2)[0]
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return false, false
+	}
+	_, err := os.Stat(filepath.FromSlash(target))
+	return err == nil, true
+}
+
+func (c *markdownChecker) evaluate(doc *markdownDoc) []finding {
+	var findings []finding
+	add := func(rule string, line int, message string) {
+		findings = append(findings, finding{rule: rule, file: doc.path, line: line, message: message})
+	}
+
+	lineNos := make([]int, 0, len(doc.prose))
+	for n := range doc.prose {
+		lineNos = append(lineNos, n)
+	}
+	sort.Ints(lineNos)
+	for _, n := range lineNos {
+		for _, target := range markdownLinks(doc.prose[n]) {
+			if target == "" || linkSchemePattern.MatchString(target) || strings.HasPrefix(target, "//") {
+				continue
+			}
+			rawPath, fragment, _ := strings.Cut(target, "#")
+			rawPath, _, _ = strings.Cut(rawPath, "?")
+			linkPath, err := url.PathUnescape(rawPath)
+			if err != nil {
+				linkPath = rawPath
+			}
+			resolved := doc.path
+			if linkPath != "" {
+				if strings.HasPrefix(linkPath, "/") {
+					resolved = path.Clean(strings.TrimPrefix(linkPath, "/"))
+				} else {
+					resolved = path.Join(path.Dir(doc.path), linkPath)
+				}
+				if _, err := os.Stat(filepath.FromSlash(resolved)); err != nil {
+					add(docRuleBrokenLink, n, "link target not found "+target)
+					continue
+				}
+			}
+			if fragment == "" || !strings.EqualFold(path.Ext(resolved), ".md") {
+				continue
+			}
+			targetDoc, ok := c.doc(resolved)
+			if !ok {
+				continue
+			}
+			anchor, err := url.PathUnescape(fragment)
+			if err != nil {
+				anchor = fragment
+			}
+			if !targetDoc.anchors[anchor] && !targetDoc.anchors[strings.ToLower(anchor)] {
+				add(docRuleBrokenAnchor, n, "anchor not found "+target)
+			}
+		}
+	}
+
+	reported := map[string]bool{}
+	for i, line := range doc.raw {
+		for _, target := range commandReferences(line) {
+			if exists, checked := commandExists(target); checked && !exists && !reported[target] {
+				reported[target] = true
+				add(docRuleMissingCommand, i+1, "referenced command not found "+target)
+			}
+		}
+	}
+
+	codeLines := make([]int, 0, len(doc.code))
+	for n := range doc.code {
+		codeLines = append(codeLines, n)
+	}
+	sort.Ints(codeLines)
+	for _, n := range codeLines {
+		for _, code := range doc.code[n] {
+			invocations := commandInvocations(code)
+			targets := make([]string, 0, len(invocations))
+			for target := range invocations {
+				targets = append(targets, target)
+			}
+			sort.Strings(targets)
+			for _, target := range targets {
+				if reported[target] {
+					continue
+				}
+				accepted, ok := c.commandFlags(target)
+				if !ok {
+					continue
+				}
+				for _, flag := range invocations[target] {
+					if !accepted.accepts(flag) {
+						add(docRuleUnknownFlag, n, fmt.Sprintf("%s does not accept %s", target, flag))
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func runMarkdownLinkScan() ([]finding, error) {
+	fmt.Println("OK: verify-lite markdown_link_scan start")
+	checker := newMarkdownChecker()
+	var findings []finding
+	files := 0
+	err := filepath.WalkDir(".", func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if scanSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.EqualFold(filepath.Ext(p), ".md") {
+			return nil
+		}
+		doc, ok := checker.doc(filepath.ToSlash(p))
+		if !ok {
+			return nil
+		}
+		files++
+		findings = append(findings, checker.evaluate(doc)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("markdown link scan failed: %w", err)
+	}
+	for _, f := range findings {
+		fmt.Printf("ERROR: verify-lite markdown_link_scan %s\n", f)
+	}
+	if len(findings) == 0 {
+		fmt.Printf("OK: verify-lite markdown_link_scan done files=%d\n", files)
+	}
+	return findings, nil
+}
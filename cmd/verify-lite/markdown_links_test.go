@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func docRuleLines(findings []finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.String())
+	}
+	return out
+}
+
+func TestHeadingSlug(t *testing.T) {
+	cases := map[string]string{
+		"Quick Start":                 "quick-start",
+		"実行時パラメータ（運用）":                "実行時パラメータ運用",
+		"`verify-lite` の使い方":          "verify-lite-の使い方",
+		"Step 1: [Setup](setup.md)!":  "step-1-setup",
+		"snake_case and **bold** 2.0": "snake_case-and-bold-20",
+		"ラベル: mobile / android":       "ラベル-mobile--android",
+	}
+	for in, want := range cases {
+		if got := headingSlug(in); got != want {
+			t.Errorf("headingSlug(%q)=%q want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseMarkdownAnchorsAndCode(t *testing.T) {
+	doc := parseMarkdown("docs/a.md", strings.Join([]string{
+		"# 概要",
+		"## Setup",
+		"## Setup",
+		"Setext Title",
+		"============",
+		"<a id=\"custom\"></a>",
+		"```sh",
+		"# not a heading",
+		"go run ./cmd/tool \\",
+		"  --flag x",
+		"```",
+		"see [x](b.md) and `go run ./cmd/tool --inline`",
+	}, "\n"))
+	for _, anchor := range []string{"概要", "setup", "setup-1", "setext-title", "custom"} {
+		if !doc.anchors[anchor] {
+			t.Errorf("missing anchor %q in %v", anchor, doc.anchors)
+		}
+	}
+	if doc.anchors["not-a-heading"] {
+		t.Error("comment in a code block must not become an anchor")
+	}
+	if got := doc.code[9]; len(got) != 1 || !strings.Contains(got[0], "--flag x") {
+		t.Errorf("continued code line not joined: %q", got)
+	}
+	if got := doc.code[12]; len(got) != 1 || got[0] != "go run ./cmd/tool --inline" {
+		t.Errorf("inline code: %q", got)
+	}
+	if links := markdownLinks(doc.prose[12]); len(links) != 1 || links[0] != "b.md" {
+		t.Errorf("links=%v", links)
+	}
+}
+
+func TestMarkdownLinkScan(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"README.md": strings.Join([]string{
+			"# ci",
+			"[runbook](docs/RUNBOOK.md#実行時パラメータ運用)",
+			"[gone](docs/OLD.md)",
+			"[bad anchor](docs/RUNBOOK.md#missing)",
+			"[self](#ci) [web](https://example.com/x.md) [mail](mailto:a@example.com)",
+			"[script](ops/ci/run.sh) [ref]: docs/RUNBOOK.md",
+			"`[not a link](nowhere.md)`",
+			"",
+			"```sh",
+			"go run ./cmd/tool --dry-run -apply --repo=x | grep --removed",
+			"go run ./cmd/gone --x",
+			"ops/ci/run.sh --repo r --force",
+			"ops/ci/missing.sh",
+			"```",
+		}, "\n"),
+		"docs/RUNBOOK.md": "# RUNBOOK\n\n## 実行時パラメータ（運用）\n\n[back](../README.md#ci)\n",
+		"cmd/tool/main.go": strings.Join([]string{
+			"package main",
+			"",
+			"func parse() {",
+			"\tfs.BoolVar(&opts.apply, \"apply\", false, \"\")",
+			"\tfs.String(\"repo\", \"\", \"\")",
+			"\tif arg == \"--dry-run\" {",
+			"\t}",
+			"}",
+		}, "\n"),
+		"ops/ci/run.sh": "#!/usr/bin/env bash\nwhile [[ $# -gt 0 ]]; do\n  case \"$1\" in\n    --repo) shift 2 ;;\n    -h|--help) exit 0 ;;\n  esac\ndone\n",
+	})
+	t.Chdir(dir)
+
+	findings, err := runMarkdownLinkScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(docRuleLines(findings), "\n")
+	for _, want := range []string{
+		"README.md:3 md-broken-link: link target not found docs/OLD.md",
+		"README.md:4 md-broken-anchor: anchor not found docs/RUNBOOK.md#missing",
+		"README.md:11 md-missing-command: referenced command not found cmd/gone",
+		"README.md:12 md-unknown-flag: ops/ci/run.sh does not accept --force",
+		"README.md:13 md-missing-command: referenced command not found ops/ci/missing.sh",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if len(findings) != 5 {
+		t.Fatalf("unexpected findings:\n%s", got)
+	}
+	if err := findingsError(findings); !strings.HasPrefix(err.Error(), "markdown link errors: ") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestCommandReferencesSkippedWithoutDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"README.md": "Run `ops/ci/run_verify_lite.sh` from the ci-self-runner checkout.\n",
+	})
+	t.Chdir(dir)
+	findings, err := runMarkdownLinkScan()
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("findings=%v err=%v", docRuleLines(findings), err)
+	}
+}
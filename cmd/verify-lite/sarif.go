@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolURI = "https://github.com/mt4110/ci-self-runner"
+	// sarifFingerprintKey names our line-independent fingerprint so code
+	// scanning keeps tracking a finding when unrelated lines move.
+	sarifFingerprintKey = "verifyLiteFinding/v1"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifText          `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]any     `json:"properties,omitempty"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifText         `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// buildSARIF converts findings into a single-run SARIF 2.1.0 log. Every
+// known rule is listed so consumers can show rule help for clean runs too.
+func buildSARIF(findings []finding) sarifLog {
+	metas := allRuleMetas()
+	rules := make([]sarifRule, 0, len(metas))
+	ruleIndex := map[string]int{}
+	for i, meta := range metas {
+		ruleIndex[meta.id] = i
+		rules = append(rules, sarifRule{
+			ID:                   meta.id,
+			Name:                 sarifRuleName(meta.id),
+			ShortDescription:     sarifText{Text: meta.description},
+			DefaultConfiguration: sarifConfiguration{Level: "error"},
+			Properties: map[string]any{
+				"tags":              []string{"security"},
+				"security-severity": meta.securitySeverity,
+			},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	seen := map[string]int{}
+	for _, f := range findings {
+		index, ok := ruleIndex[f.rule]
+		if !ok {
+			index = -1
+		}
+		location := sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.file), URIBaseID: "%SRCROOT%"},
+		}
+		if f.line > 0 {
+			location.Region = &sarifRegion{StartLine: f.line}
+		}
+		message := f.message
+		var properties map[string]any
+		if f.commit != "" {
+			message = fmt.Sprintf("%s (commit %s by %s)", f.message, f.commit, f.author)
+			properties = map[string]any{"commit": f.commit, "author": f.author}
+		}
+		fingerprintBase := strings.Join([]string{f.rule, f.file, f.message, f.commit}, "\x00")
+		seen[fingerprintBase]++
+		results = append(results, sarifResult{
+			RuleID:    f.rule,
+			RuleIndex: index,
+			Level:     "error",
+			Message:   sarifText{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+			PartialFingerprints: map[string]string{
+				sarifFingerprintKey: sarifFingerprint(fingerprintBase, seen[fingerprintBase]),
+			},
+			Properties: properties,
+		})
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "verify-lite",
+				InformationURI: sarifToolURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// sarifFingerprint hashes the finding identity plus its occurrence number so
+// repeated identical findings in one file stay distinct.
+func sarifFingerprint(base string, occurrence int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", base, occurrence)))
+	return hex.EncodeToString(sum[:])
+}
+
+// sarifRuleName turns "unpinned-uses" into "UnpinnedUses".
+func sarifRuleName(id string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(id, "-") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+func writeSARIF(path string, findings []finding) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(buildSARIF(findings), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testdata/sarif-schema-2.1.0.json is the OASIS SARIF 2.1.0 schema (errata01,
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json).
+func loadSARIFSchema(t *testing.T) schemaValidator {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	return schemaValidator{root: schema}
+}
+
+var sarifTestFindings = []finding{
+	{rule: "unpinned-uses", file: ".github/workflows/verify.yml", line: 7, message: "non-SHA uses (actions/checkout@v4)"},
+	{rule: "mobile-signing-file", file: "android/release.jks", message: "pattern=mobile_signing_file"},
+	{rule: "slack-webhook", file: "old.env", line: 1, message: "pattern=x", commit: "abc", author: "Dev <dev@example.com>"},
+}
+
+func writeTestSARIF(t *testing.T) any {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out", "verify-lite.sarif")
+	if err := writeSARIF(path, sarifTestFindings); err != nil {
+		t.Fatalf("writeSARIF returned error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read sarif: %v", err)
+	}
+	var doc any
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("parse sarif: %v", err)
+	}
+	return doc
+}
+
+func TestWriteSARIFValidatesAgainstSchema(t *testing.T) {
+	v := loadSARIFSchema(t)
+	if errs := v.validate(v.root, writeTestSARIF(t), "$"); len(errs) > 0 {
+		t.Fatalf("sarif does not match schema:\n%s", strings.Join(errs, "\n"))
+	}
+
+	log := buildSARIF(sarifTestFindings)
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Fatal("file-level finding should not carry a region")
+	}
+	rule := log.Runs[0].Tool.Driver.Rules[results[0].RuleIndex]
+	if rule.ID != "unpinned-uses" || rule.Name != "UnpinnedUses" {
+		t.Fatalf("ruleIndex does not point at the rule: %+v", rule)
+	}
+}
+
+// TestSARIFSchemaRejectsBrokenLogs guards the validator itself: each edit of
+// a valid log must be reported.
+func TestSARIFSchemaRejectsBrokenLogs(t *testing.T) {
+	v := loadSARIFSchema(t)
+	for name, tc := range map[string]struct {
+		edit func(doc map[string]any)
+		want string
+	}{
+		"missing version": {func(doc map[string]any) { delete(doc, "version") }, `$: missing required "version"`},
+		"unknown level":   {func(doc map[string]any) { firstSARIFResult(doc)["level"] = "fatal" }, "$.runs[0].results[0].level: fatal not in enum"},
+		"zero start line": {func(doc map[string]any) {
+			region := firstSARIFResult(doc)["locations"].([]any)[0].(map[string]any)["physicalLocation"].(map[string]any)["region"].(map[string]any)
+			region["startLine"] = 0.0
+		}, "region.startLine: 0 below minimum 1"},
+		"unknown property": {func(doc map[string]any) { firstSARIFResult(doc)["severity"] = "high" }, `$.runs[0].results[0]: unexpected property "severity"`},
+		"rule without id": {func(doc map[string]any) {
+			rules := doc["runs"].([]any)[0].(map[string]any)["tool"].(map[string]any)["driver"].(map[string]any)["rules"].([]any)
+			delete(rules[0].(map[string]any), "id")
+		}, `rules[0]: missing required "id"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc := writeTestSARIF(t).(map[string]any)
+			tc.edit(doc)
+			errs := strings.Join(v.validate(v.root, doc, "$"), "\n")
+			if !strings.Contains(errs, tc.want) {
+				t.Fatalf("want %q in:\n%s", tc.want, errs)
+			}
+		})
+	}
+}
+
+func firstSARIFResult(doc map[string]any) map[string]any {
+	return doc["runs"].([]any)[0].(map[string]any)["results"].([]any)[0].(map[string]any)
+}
+
+func TestSARIFFingerprintIgnoresLineButSeparatesRepeats(t *testing.T) {
+	moved := buildSARIF([]finding{{rule: "slack-webhook", file: "a", line: 3, message: "pattern=x"}})
+	original := buildSARIF([]finding{
+		{rule: "slack-webhook", file: "a", line: 1, message: "pattern=x"},
+		{rule: "slack-webhook", file: "a", line: 9, message: "pattern=x"},
+	})
+	first := original.Runs[0].Results[0].PartialFingerprints[sarifFingerprintKey]
+	second := original.Runs[0].Results[1].PartialFingerprints[sarifFingerprintKey]
+	if moved.Runs[0].Results[0].PartialFingerprints[sarifFingerprintKey] != first {
+		t.Fatal("fingerprint should not depend on the line number")
+	}
+	if first == second {
+		t.Fatal("repeated findings should get distinct fingerprints")
+	}
+}
+
+// schemaValidator checks a value against the draft-04 keywords the SARIF
+// schema uses. A keyword it does not know fails validation instead of being
+// skipped.
+type schemaValidator struct {
+	root map[string]any
+}
+
+// schemaAnnotations carry no constraint ("format" is advisory in draft-04).
+var schemaAnnotations = map[string]bool{
+	"$schema": true, "id": true, "title": true, "description": true, "default": true, "format": true, "definitions": true,
+}
+
+var schemaKeywords = map[string]bool{
+	"$ref": true, "type": true, "enum": true, "minimum": true, "maximum": true, "pattern": true, "anyOf": true, "oneOf": true,
+	"required": true, "properties": true, "additionalProperties": true, "items": true, "minItems": true, "uniqueItems": true,
+}
+
+func (v schemaValidator) resolve(schema map[string]any) map[string]any {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	name := strings.TrimPrefix(ref, "#/definitions/")
+	return v.root["definitions"].(map[string]any)[name].(map[string]any)
+}
+
+func (v schemaValidator) validate(schema map[string]any, value any, path string) []string {
+	schema = v.resolve(schema)
+	var errs []string
+	for keyword := range schema {
+		if !schemaKeywords[keyword] && !schemaAnnotations[keyword] {
+			errs = append(errs, fmt.Sprintf("%s: unsupported schema keyword %q", path, keyword))
+		}
+	}
+	if types, ok := schema["type"]; ok && !matchesSchemaType(types, value) {
+		return append(errs, fmt.Sprintf("%s: type %T does not match %v", path, value, types))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v not in enum %v", path, value, enum))
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if n, isNum := value.(float64); isNum && n < minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v below minimum %v", path, n, minimum))
+		}
+	}
+	if maximum, ok := schema["maximum"].(float64); ok {
+		if n, isNum := value.(float64); isNum && n > maximum {
+			errs = append(errs, fmt.Sprintf("%s: %v above maximum %v", path, n, maximum))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, isStr := value.(string); isStr && !regexp.MustCompile(pattern).MatchString(s) {
+			errs = append(errs, fmt.Sprintf("%s: %q does not match %s", path, s, pattern))
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && v.matching(anyOf, value, path) == 0 {
+		errs = append(errs, fmt.Sprintf("%s: matches none of anyOf", path))
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if n := v.matching(oneOf, value, path); n != 1 {
+			errs = append(errs, fmt.Sprintf("%s: matches %d of oneOf, want 1", path, n))
+		}
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		for _, req := range asStrings(schema["required"]) {
+			if _, ok := typed[req]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required %q", path, req))
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for key, child := range typed {
+			if propSchema, ok := props[key].(map[string]any); ok {
+				errs = append(errs, v.validate(propSchema, child, path+"."+key)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Sprintf("%s: unexpected property %q", path, key))
+				}
+			case map[string]any:
+				errs = append(errs, v.validate(additional, child, path+"."+key)...)
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(typed)) < minItems {
+			errs = append(errs, fmt.Sprintf("%s: fewer than %v items", path, minItems))
+		}
+		if unique, _ := schema["uniqueItems"].(bool); unique {
+			for i := range typed {
+				for j := i + 1; j < len(typed); j++ {
+					if reflect.DeepEqual(typed[i], typed[j]) {
+						errs = append(errs, fmt.Sprintf("%s: items %d and %d are not unique", path, i, j))
+					}
+				}
+			}
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range typed {
+				errs = append(errs, v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// matching counts the subschemas value satisfies.
+func (v schemaValidator) matching(options []any, value any, path string) int {
+	n := 0
+	for _, option := range options {
+		if len(v.validate(option.(map[string]any), value, path)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesSchemaType(types any, value any) bool {
+	names := asStrings(types)
+	if name, ok := types.(string); ok {
+		names = []string{name}
+	}
+	for _, name := range names {
+		switch name {
+		case "object":
+			if _, ok := value.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]any); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := value.(float64); ok && n == float64(int64(n)) {
+				return true
+			}
+		case "null":
+			if value == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func asStrings(raw any) []string {
+	list, _ := raw.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
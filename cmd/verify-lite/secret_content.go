@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Archive limits bound the work a single committed file can cause. Members
+// beyond a limit are not scanned and the archive is reported in the status
+// file instead of being skipped silently.
+const (
+	defaultArchiveMaxDepth    = 3
+	defaultArchiveMaxMemberMB = 64
+	defaultArchiveMaxTotalMB  = 512
+)
+
+// binarySniffBytes matches git's heuristic: a NUL in the first 8000 bytes
+// marks the content as binary.
+const binarySniffBytes = 8000
+
+type archiveLimits struct {
+	maxDepth       int
+	maxMemberBytes int64
+	maxTotalBytes  int64
+}
+
+func loadArchiveLimits() archiveLimits {
+	limits := archiveLimits{
+		maxDepth:       defaultArchiveMaxDepth,
+		maxMemberBytes: defaultArchiveMaxMemberMB << 20,
+		maxTotalBytes:  defaultArchiveMaxTotalMB << 20,
+	}
+	if v, err := envOrInt("VERIFY_LITE_ARCHIVE_MAX_DEPTH", defaultArchiveMaxDepth); err == nil {
+		limits.maxDepth = v
+	}
+	if v, err := envOrInt("VERIFY_LITE_ARCHIVE_MAX_MEMBER_MB", defaultArchiveMaxMemberMB); err == nil {
+		limits.maxMemberBytes = int64(v) << 20
+	}
+	if v, err := envOrInt("VERIFY_LITE_ARCHIVE_MAX_TOTAL_MB", defaultArchiveMaxTotalMB); err == nil {
+		limits.maxTotalBytes = int64(v) << 20
+	}
+	return limits
+}
+
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	gzipStream
+	tarArchive
+)
+
+// sniffArchive detects archives by content, so renamed files (.ipa, .aab,
+// .jar, .apk are all zip) are covered regardless of extension.
+func sniffArchive(head []byte) archiveKind {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return zipArchive
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return gzipStream
+	case len(head) >= 263 && bytes.Equal(head[257:262], []byte("ustar")):
+		return tarArchive
+	}
+	return notArchive
+}
+
+// errArchiveBudget stops reading once an archive exceeds its size limits.
+var errArchiveBudget = errors.New("archive size limit exceeded")
+
+// budgetReader charges decompressed archive content to the scanner's budget
+// for the current top-level file.
+type budgetReader struct {
+	r io.Reader
+	s *contentScanner
+}
+
+func (b budgetReader) Read(p []byte) (int, error) {
+	if b.s.budget <= 0 {
+		b.s.budgetHit = true
+		return 0, errArchiveBudget
+	}
+	if int64(len(p)) > b.s.budget {
+		p = p[:b.s.budget]
+	}
+	n, err := b.r.Read(p)
+	b.s.budget -= int64(n)
+	return n, err
+}
+
+// contentScanner applies the secret patterns to file contents and the
+// mobile signing path rules plus patterns to archive members. Member paths
+// are reported as <archive>!/<member>.
+type contentScanner struct {
+	limits   archiveLimits
+	findings []finding
+	// skipped records archives (or members) that hit a limit or could not
+	// be read, as "<path> reason=<reason>".
+	skipped       []string
+	files         int
+	archives      int
+	members       int
+	binarySkipped int
+	// budget is what is left of maxTotalBytes for the current top-level file.
+	budget    int64
+	budgetHit bool
+}
+
+func newContentScanner() *contentScanner {
+	return &contentScanner{limits: loadArchiveLimits()}
+}
+
+func (s *contentScanner) details() []string {
+	details := []string{
+		fmt.Sprintf("secret_scan_files=%d", s.files),
+		fmt.Sprintf("secret_scan_archives=%d", s.archives),
+		fmt.Sprintf("secret_scan_archive_members=%d", s.members),
+		fmt.Sprintf("secret_scan_binary_skipped=%d", s.binarySkipped),
+	}
+	for _, skipped := range s.skipped {
+		details = append(details, "secret_scan_archive_skipped="+skipped)
+	}
+	return details
+}
+
+func (s *contentScanner) skip(path, reason string) {
+	fmt.Printf("SKIP: verify-lite secret_scan archive=%s reason=%s\n", path, reason)
+	s.skipped = append(s.skipped, path+" reason="+reason)
+}
+
+// scanFile scans one working tree file. Zip files are read in place; every
+// other format is streamed.
+func (s *contentScanner) scanFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if sniffArchive(head[:n]) != zipArchive {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		s.scanContent(path, f)
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	s.begin()
+	s.archives++
+	s.scanZip(path, f, info.Size(), 1)
+	s.end(path)
+	return nil
+}
+
+// scanContent scans one top-level stream such as a working tree file or a
+// git blob.
+func (s *contentScanner) scanContent(path string, r io.Reader) {
+	s.begin()
+	s.scanReader(path, r, 0)
+	s.end(path)
+}
+
+func (s *contentScanner) begin() {
+	s.files++
+	s.budget = s.limits.maxTotalBytes
+	s.budgetHit = false
+}
+
+func (s *contentScanner) end(path string) {
+	if s.budgetHit {
+		s.skip(path, "total_size_limit")
+	}
+}
+
+// scanReader scans content that is not yet known to be an archive. depth is
+// the archive nesting level of path (0 for a top-level file). Decompressed
+// data is charged to the budget; plain text files are streamed unbounded.
+func (s *contentScanner) scanReader(path string, r io.Reader, depth int) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	kind := sniffArchive(head)
+	if kind == gzipStream {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			s.skip(path, "corrupt")
+			return
+		}
+		defer gz.Close()
+		// The gzip layer is not a container; its payload keeps the path.
+		s.scanReader(path, budgetReader{r: gz, s: s}, depth)
+		return
+	}
+	if kind != notArchive {
+		if depth >= s.limits.maxDepth {
+			s.skip(path, "depth_limit")
+			return
+		}
+		s.archives++
+		if kind == tarArchive {
+			s.scanTar(path, br, depth+1)
+			return
+		}
+		// Nested zips need random access, so the member is buffered.
+		data, err := io.ReadAll(io.LimitReader(br, s.limits.maxMemberBytes+1))
+		if err != nil {
+			if !errors.Is(err, errArchiveBudget) {
+				s.skip(path, "corrupt")
+			}
+			return
+		}
+		if int64(len(data)) > s.limits.maxMemberBytes {
+			s.skip(path, "member_size_limit")
+			return
+		}
+		s.scanZip(path, bytes.NewReader(data), int64(len(data)), depth+1)
+		return
+	}
+
+	matches, binary, err := scanSecretStream(br)
+	if err != nil && !errors.Is(err, errArchiveBudget) {
+		s.skip(path, "corrupt")
+	}
+	if binary {
+		s.binarySkipped++
+		return
+	}
+	for _, m := range matches {
+		s.findings = append(s.findings, secretFinding(path, m))
+	}
+}
+
+func (s *contentScanner) scanZip(path string, r io.ReaderAt, size int64, depth int) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		s.skip(path, "corrupt")
+		return
+	}
+	for _, f := range zr.File {
+		if s.budgetHit {
+			return
+		}
+		if f.FileInfo().IsDir() || !s.member(path, f.Name) {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(s.limits.maxMemberBytes) {
+			s.skip(memberPath(path, f.Name), "member_size_limit")
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			s.skip(memberPath(path, f.Name), "corrupt")
+			continue
+		}
+		s.scanReader(memberPath(path, f.Name), budgetReader{r: rc, s: s}, depth)
+		rc.Close()
+	}
+}
+
+func (s *contentScanner) scanTar(path string, r io.Reader, depth int) {
+	tr := tar.NewReader(r)
+	for !s.budgetHit {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, errArchiveBudget) {
+				s.skip(path, "corrupt")
+			}
+			return
+		}
+		if hdr.Typeflag != tar.TypeReg || !s.member(path, hdr.Name) {
+			continue
+		}
+		if hdr.Size > s.limits.maxMemberBytes {
+			s.skip(memberPath(path, hdr.Name), "member_size_limit")
+			continue
+		}
+		s.scanReader(memberPath(path, hdr.Name), tr, depth)
+	}
+}
+
+// member counts an archive member and applies the signing file name rules.
+// It reports whether the member content still needs scanning.
+func (s *contentScanner) member(archive, name string) bool {
+	s.members++
+	if isMobileSensitivePath(name) {
+		s.findings = append(s.findings, finding{rule: "mobile-signing-file", file: memberPath(archive, name), message: "pattern=" + mobileSigningPattern})
+		return false
+	}
+	return true
+}
+
+func memberPath(archive, name string) string {
+	return archive + "!/" + strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// maxSecretLiteral is the longest pattern; a line read in pieces keeps this
+// many bytes of overlap so a match split across two reads is still found.
+var maxSecretLiteral = func() int {
+	n := len(`"private_key"`)
+	for _, p := range secretPatterns {
+		n = max(n, len(p.literal))
+	}
+	return n
+}()
+
+// scanSecretStream returns every line that contains a secret pattern. Content
+// of any size is read once, line by line, without holding it in memory.
+// binary is true when the first bytes contain a NUL; such content is not
+// scanned.
+func scanSecretStream(r io.Reader) (matches []secretMatch, binary bool, err error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 64*1024)
+	}
+	head, _ := br.Peek(binarySniffBytes)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, true, nil
+	}
+
+	var serviceAccountType, privateKeyField bool
+	seen := map[string]bool{}
+	line := 1
+	carry := ""
+	for {
+		chunk, readErr := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			text := carry + string(chunk)
+			serviceAccountType = serviceAccountType || strings.Contains(text, "service_account")
+			privateKeyField = privateKeyField || strings.Contains(text, `"private_key"`)
+			for _, p := range secretPatterns {
+				key := fmt.Sprintf("%d\x00%s", line, p.literal)
+				if !seen[key] && strings.Contains(text, p.literal) {
+					seen[key] = true
+					matches = append(matches, secretMatch{rule: p.rule, pattern: p.literal, line: line})
+				}
+			}
+			if chunk[len(chunk)-1] == '\n' {
+				line++
+				carry = ""
+			} else if len(text) >= maxSecretLiteral {
+				carry = text[len(text)-maxSecretLiteral+1:]
+			} else {
+				carry = text
+			}
+		}
+		if readErr == bufio.ErrBufferFull {
+			continue
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return matches, false, readErr
+		}
+	}
+
+	// The private key of a service account JSON is reported under its own rule.
+	if serviceAccountType && privateKeyField {
+		for i, m := range matches {
+			if m.pattern == "-----BEGIN "+"PRIVATE KEY-----" {
+				matches[i].rule = "google-service-account-key"
+				matches[i].pattern = "google_service_account_private_key"
+			}
+		}
+	}
+	return matches, false, nil
+}
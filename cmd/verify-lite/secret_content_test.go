@@ -0,0 +1,227 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPEM = "-----BEGIN " + "PRIVATE KEY-----\nabc\n"
+
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func findingSet(findings []finding) map[string]bool {
+	out := map[string]bool{}
+	for _, f := range findings {
+		out[fmt.Sprintf("%s %s:%d", f.rule, f.file, f.line)] = true
+	}
+	return out
+}
+
+func TestSecretScanOpensArchives(t *testing.T) {
+	repo := t.TempDir()
+	inner := zipBytes(t, map[string][]byte{"certs/dist.p12": []byte("x")})
+	writeFiles(t, repo, map[string]string{
+		"build/app.ipa": string(zipBytes(t, map[string][]byte{
+			"Payload/App.app/embedded.mobileprovision": []byte("x"),
+			"Payload/App.app/Info.plist":               []byte("ok"),
+		})),
+		"release/app.aab": string(zipBytes(t, map[string][]byte{"BUNDLE-METADATA/release.jks": []byte("x")})),
+		"libs/sdk.jar":    string(zipBytes(t, map[string][]byte{"META-INF/key.pem": []byte("x\n" + testPEM)})),
+		"backup.tar.gz":   string(tarGzBytes(t, map[string][]byte{"android/key.properties": []byte("x"), "conf/notify.env": []byte("https://hooks.slack.com/" + "services/T/B/x\n")})),
+		"nested.zip":      string(zipBytes(t, map[string][]byte{"inner.zip": inner})),
+		"image.png":       "\x89PNG\x00\x00binary",
+	})
+	t.Chdir(repo)
+
+	findings, details, err := runSecretPatternScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := findingSet(findings)
+	for _, want := range []string{
+		"mobile-signing-file build/app.ipa!/Payload/App.app/embedded.mobileprovision:0",
+		"mobile-signing-file release/app.aab!/BUNDLE-METADATA/release.jks:0",
+		"private-key-block libs/sdk.jar!/META-INF/key.pem:2",
+		"mobile-signing-file backup.tar.gz!/android/key.properties:0",
+		"slack-webhook backup.tar.gz!/conf/notify.env:1",
+		"mobile-signing-file nested.zip!/inner.zip!/certs/dist.p12:0",
+	} {
+		if !got[want] {
+			t.Errorf("missing %q in %v", want, got)
+		}
+	}
+	if len(findings) != 6 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+	joined := strings.Join(details, "\n")
+	for _, want := range []string{"secret_scan_archives=6", "secret_scan_binary_skipped=1"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("details missing %q:\n%s", want, joined)
+		}
+	}
+}
+
+func TestSecretScanArchiveLimits(t *testing.T) {
+	repo := t.TempDir()
+	level3 := zipBytes(t, map[string][]byte{"deep.jks": []byte("x")})
+	level2 := zipBytes(t, map[string][]byte{"l3.zip": level3})
+	writeFiles(t, repo, map[string]string{
+		"deep.zip": string(zipBytes(t, map[string][]byte{"l2.zip": level2})),
+		"big.zip":  string(zipBytes(t, map[string][]byte{"huge.txt": bytes.Repeat([]byte("a"), 2<<20)})),
+		"bad.zip":  "PK\x03\x04truncated",
+	})
+	t.Chdir(repo)
+	t.Setenv("VERIFY_LITE_ARCHIVE_MAX_DEPTH", "2")
+	t.Setenv("VERIFY_LITE_ARCHIVE_MAX_MEMBER_MB", "1")
+
+	findings, details, err := runSecretPatternScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("member beyond the depth limit must not be opened: %v", findings)
+	}
+	joined := strings.Join(details, "\n")
+	for _, want := range []string{
+		"secret_scan_archive_skipped=deep.zip!/l2.zip!/l3.zip reason=depth_limit",
+		"secret_scan_archive_skipped=big.zip!/huge.txt reason=member_size_limit",
+		"secret_scan_archive_skipped=bad.zip reason=corrupt",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("details missing %q:\n%s", want, joined)
+		}
+	}
+}
+
+func TestSecretScanTotalSizeLimit(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"bomb.tar.gz": string(tarGzBytes(t, map[string][]byte{
+			"a.txt": bytes.Repeat([]byte("a"), 900<<10),
+			"b.txt": bytes.Repeat([]byte("b"), 900<<10),
+			"c.jks": []byte("x"),
+		})),
+	})
+	t.Chdir(repo)
+	t.Setenv("VERIFY_LITE_ARCHIVE_MAX_TOTAL_MB", "1")
+
+	_, details, err := runSecretPatternScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined := strings.Join(details, "\n"); !strings.Contains(joined, "secret_scan_archive_skipped=bomb.tar.gz reason=total_size_limit") {
+		t.Fatalf("details:\n%s", joined)
+	}
+}
+
+func TestSecretScanStreamsLargeFiles(t *testing.T) {
+	repo := t.TempDir()
+	var big strings.Builder
+	for big.Len() < 3<<20 {
+		big.WriteString("filler line without secrets\n")
+	}
+	lines := strings.Count(big.String(), "\n")
+	big.WriteString("webhook=https://discord.com/api/" + "webhooks/1/x\n")
+	writeFiles(t, repo, map[string]string{"logs/big.log": big.String()})
+	t.Chdir(repo)
+
+	findings, _, err := runSecretPatternScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].rule != "discord-webhook" || findings[0].line != lines+1 {
+		t.Fatalf("findings=%v want line %d", findings, lines+1)
+	}
+}
+
+func TestScanSecretStreamMatchesAcrossBufferBoundary(t *testing.T) {
+	literal := "hooks.slack.com/" + "services/"
+	// One line longer than the reader buffer with the literal split across
+	// the two reads.
+	line := strings.Repeat("x", 16-5) + literal + strings.Repeat("y", 100)
+	matches, binary, err := scanSecretStream(bufio.NewReaderSize(strings.NewReader("first\n"+line+"\n"), 16))
+	if err != nil || binary {
+		t.Fatalf("err=%v binary=%v", err, binary)
+	}
+	if len(matches) != 1 || matches[0].line != 2 || matches[0].rule != "slack-webhook" {
+		t.Fatalf("matches=%+v", matches)
+	}
+}
+
+func TestScanSecretStreamServiceAccountKey(t *testing.T) {
+	text := "{\n\"type\": \"service_account\",\n\"private_key\": \"" + strings.TrimSuffix(testPEM, "\n") + "\"\n}\n"
+	matches, _, err := scanSecretStream(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].rule != "google-service-account-key" || matches[0].line != 3 {
+		t.Fatalf("matches=%+v", matches)
+	}
+}
+
+func TestHistoryScanOpensArchiveBlobs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "release.zip"), zipBytes(t, map[string][]byte{"upload.keystore": []byte("x")}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "add release")
+	t.Chdir(repo)
+
+	findings, _, err := runHistoryMode(t.Context(), "")
+	if err == nil || len(findings) != 1 || findings[0].file != "release.zip!/upload.keystore" || findings[0].commit == "" {
+		t.Fatalf("err=%v findings=%+v", err, findings)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// shellRule is a docs/ci/SHELL_POLICY.md convention checked on shell scripts.
+type shellRule struct {
+	id          string
+	description string
+}
+
+const (
+	shellRuleStrictMode     = "shell-missing-strict-mode"
+	shellRuleBashism        = "shell-bashism-in-sh"
+	shellRuleUnquotedRmCd   = "shell-unquoted-var-in-rm-cd"
+	shellRuleEval           = "shell-eval-expanded-input"
+	shellRuleShellcheckNote = "shell-unjustified-shellcheck-disable"
+)
+
+var shellRules = []shellRule{
+	{id: shellRuleStrictMode, description: "bash scripts must run with set -euo pipefail"},
+	{id: shellRuleBashism, description: "#!/usr/bin/env sh scripts must stay POSIX sh (no bash-only syntax)"},
+	{id: shellRuleUnquotedRmCd, description: "rm and cd arguments must quote variable expansions"},
+	{id: shellRuleEval, description: "eval must not run expanded (possibly untrusted) input"},
+	{id: shellRuleShellcheckNote, description: "shellcheck disable directives need a justification comment"},
+}
+
+func shellRuleMetas() []ruleMeta {
+	metas := make([]ruleMeta, 0, len(shellRules))
+	for _, rule := range shellRules {
+		severity := "4.0"
+		if rule.id == shellRuleEval || rule.id == shellRuleUnquotedRmCd {
+			severity = "6.5"
+		}
+		metas = append(metas, ruleMeta{id: rule.id, description: rule.description, securitySeverity: severity})
+	}
+	return metas
+}
+
+// shellDialect is the interpreter a script declares.
+type shellDialect int
+
+const (
+	shellNone shellDialect = iota
+	shellPOSIX
+	shellBash
+)
+
+var shebangPattern = regexp.MustCompile(`^#!\s*(\S+)(?:\s+(\S+))?`)
+
+// scriptDialect reads the shebang. *.sh files without one are treated as
+// POSIX sh, other files without a shell shebang are not scripts.
+func scriptDialect(path, content string) shellDialect {
+	firstLine, _, _ := strings.Cut(content, "\n")
+	m := shebangPattern.FindStringSubmatch(firstLine)
+	if m == nil {
+		if strings.HasSuffix(path, ".sh") {
+			return shellPOSIX
+		}
+		return shellNone
+	}
+	interp := filepath.Base(m[1])
+	if interp == "env" {
+		interp = m[2]
+	}
+	switch interp {
+	case "bash":
+		return shellBash
+	case "sh", "dash", "ash":
+		return shellPOSIX
+	}
+	if strings.HasSuffix(path, ".sh") {
+		return shellPOSIX
+	}
+	return shellNone
+}
+
+func runShellPolicyScan() ([]finding, error) {
+	fmt.Println("OK: verify-lite shell_policy_scan start")
+	var findings []finding
+	scripts := 0
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if scanSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > 1024*1024 {
+			return nil
+		}
+		// Only *.sh files and executables can be scripts; this avoids reading
+		// every file in the tree.
+		if !strings.HasSuffix(path, ".sh") && info.Mode().Perm()&0o111 == 0 {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		path = filepath.ToSlash(path)
+		dialect := scriptDialect(path, string(content))
+		if dialect == shellNone {
+			return nil
+		}
+		scripts++
+		findings = append(findings, evaluateShellScript(path, string(content), dialect)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("shell policy scan failed: %w", err)
+	}
+	for _, f := range findings {
+		fmt.Printf("ERROR: verify-lite shell_policy_scan %s\n", f)
+	}
+	if len(findings) == 0 {
+		fmt.Printf("OK: verify-lite shell_policy_scan done scripts=%d\n", scripts)
+	}
+	return findings, nil
+}
+
+// shellLine is one logical source line with heredoc bodies removed.
+type shellLine struct {
+	number int
+	text   string
+}
+
+var heredocPattern = regexp.MustCompile(`(?:^|[^<])<<(-?)\s*(?:'([A-Za-z_][A-Za-z0-9_]*)'|"([A-Za-z_][A-Za-z0-9_]*)"|\\?([A-Za-z_][A-Za-z0-9_]*))`)
+
+// shellCodeLines drops heredoc bodies, which are data rather than code.
+func shellCodeLines(content string) []shellLine {
+	var out []shellLine
+	var pending []string
+	stripTabs := false
+	for i, text := range strings.Split(content, "\n") {
+		if len(pending) > 0 {
+			candidate := text
+			if stripTabs {
+				candidate = strings.TrimLeft(candidate, "\t")
+			}
+			if candidate == pending[0] {
+				pending = pending[1:]
+			}
+			continue
+		}
+		out = append(out, shellLine{number: i + 1, text: text})
+		for _, m := range heredocPattern.FindAllStringSubmatch(stripShellComment(text), -1) {
+			stripTabs = m[1] == "-"
+			pending = append(pending, m[2]+m[3]+m[4])
+		}
+	}
+	return out
+}
+
+// shellWord is a word of a simple command. unquotedExpansion is set when a
+// $ expansion appears outside double quotes; expansion when one appears at all.
+type shellWord struct {
+	text              string
+	expansion         bool
+	unquotedExpansion bool
+}
+
+// splitShellCommands lexes one line into simple commands. It understands
+// quotes, escapes, $(...) and ${...}, and stops at an unquoted comment.
+func splitShellCommands(line string) [][]shellWord {
+	var commands [][]shellWord
+	var words []shellWord
+	var cur strings.Builder
+	var word shellWord
+	inWord := false
+
+	flushWord := func() {
+		if inWord {
+			word.text = cur.String()
+			words = append(words, word)
+		}
+		cur.Reset()
+		word = shellWord{}
+		inWord = false
+	}
+	flushCommand := func() {
+		flushWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+		}
+		words = nil
+	}
+
+	inDouble := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			cur.WriteByte(c)
+			cur.WriteByte(line[i+1])
+			inWord = true
+			i++
+		case !inDouble && c == '\'':
+			stop := closingQuote(line, i)
+			cur.WriteString(line[i:stop])
+			inWord = true
+			i = stop - 1
+		case c == '"':
+			inDouble = !inDouble
+			cur.WriteByte(c)
+			inWord = true
+		case c == '$' && i+1 < len(line) && (line[i+1] == '(' || line[i+1] == '{'):
+			end := matchShellGroup(line, i+1)
+			cur.WriteString(line[i:end])
+			word.expansion = true
+			if !inDouble {
+				word.unquotedExpansion = true
+			}
+			inWord = true
+			i = end - 1
+		case c == '$' && i+1 < len(line) && isShellParamStart(line[i+1]):
+			cur.WriteByte(c)
+			word.expansion = true
+			if !inDouble {
+				word.unquotedExpansion = true
+			}
+			inWord = true
+		case c == '`':
+			stop := closingQuote(line, i)
+			cur.WriteString(line[i:stop])
+			word.expansion = true
+			if !inDouble {
+				word.unquotedExpansion = true
+			}
+			inWord = true
+			i = stop - 1
+		case inDouble:
+			cur.WriteByte(c)
+		case c == '#' && !inWord:
+			flushCommand()
+			return commands
+		case c == ' ' || c == '\t':
+			flushWord()
+		case c == ';' || c == '&' || c == '|' || c == '(' || c == ')':
+			flushCommand()
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	flushCommand()
+	return commands
+}
+
+// closingQuote returns the index after the quote matching line[open], or the
+// end of the line when it is not closed.
+func closingQuote(line string, open int) int {
+	end := strings.IndexByte(line[open+1:], line[open])
+	if end < 0 {
+		return len(line)
+	}
+	return open + end + 2
+}
+
+func isShellParamStart(c byte) bool {
+	return c == '_' || c == '@' || c == '*' || c == '#' || c == '?' || c == '!' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// matchShellGroup returns the index after the ) or } closing the group that
+// opens at line[open], honoring nesting and quotes.
+func matchShellGroup(line string, open int) int {
+	opening := line[open]
+	closing := byte(')')
+	if opening == '{' {
+		closing = '}'
+	}
+	depth := 0
+	quote := byte(0)
+	for i := open; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '\\':
+			i++
+		case c == opening:
+			depth++
+		case c == closing:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(line)
+}
+
+// stripShellComment removes an unquoted trailing comment.
+func stripShellComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '\\':
+			i++
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// shellKeywords precede the command word without being the command.
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "do": true,
+	"while": true, "until": true, "!": true, "time": true, "{": true, "}": true,
+	"command": true, "builtin": true, "exec": true, "sudo": true,
+}
+
+var shellAssignmentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// commandWord returns the index of the command word in a simple command.
+func commandWord(words []shellWord) int {
+	for i, w := range words {
+		if shellKeywords[w.text] || shellAssignmentPattern.MatchString(w.text) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// shellBashisms are bash-only constructs. Each pattern runs on code with
+// single-quoted strings and comments removed.
+var shellBashisms = []struct {
+	pattern *regexp.Regexp
+	name    string
+}{
+	{regexp.MustCompile(`\[\[|\]\]`), "[[ ]]"},
+	{regexp.MustCompile(`(^|[\s;])function\s+[A-Za-z_]`), "function keyword"},
+	{regexp.MustCompile(`<<<`), "here-string <<<"},
+	{regexp.MustCompile(`&>`), "&> redirection"},
+	{regexp.MustCompile(`(^|[\s;])(source|declare|typeset|let|pushd|popd|shopt)\s`), "bash builtin"},
+	{regexp.MustCompile(`(^|[\s;])\(\(`), "(( )) arithmetic command"},
+	{regexp.MustCompile(`^\s*[A-Za-z_][A-Za-z0-9_]*=\(`), "array assignment"},
+	{regexp.MustCompile(`\$\{![A-Za-z_]`), "${!var} indirection"},
+	{regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*(//?[^}]|:[0-9 ]|\^|,)`), "bash parameter expansion"},
+	{regexp.MustCompile(`\$'`), "$'...' quoting"},
+	{regexp.MustCompile(`(^|[\s;])echo\s+-e\s`), "echo -e"},
+	{regexp.MustCompile(`\[\s[^]]*\s==\s`), "== in [ ]"},
+}
+
+var (
+	shellcheckDisableLine = regexp.MustCompile(`^\s*#\s*shellcheck\s+disable=\S+(.*)$`)
+	singleQuotedPattern   = regexp.MustCompile(`'[^']*'`)
+)
+
+// setShortOptions maps the short set flags strict mode needs to their -o
+// names.
+var setShortOptions = map[byte]string{'e': "errexit", 'u': "nounset"}
+
+// collectSetOptions records the options one set command turns on, whatever
+// the flag order or clustering: -eu, -o pipefail, -euo pipefail, -o errexit.
+func collectSetOptions(args []shellWord, enabled map[string]bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i].text
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return
+		}
+		for j := 1; j < len(arg); j++ {
+			if arg[j] == 'o' {
+				if i+1 < len(args) {
+					i++
+					enabled[args[i].text] = true
+				}
+				continue
+			}
+			if name, ok := setShortOptions[arg[j]]; ok {
+				enabled[name] = true
+			}
+		}
+	}
+}
+
+// evaluateShellScript applies every shell rule to one script.
+func evaluateShellScript(path, content string, dialect shellDialect) []finding {
+	lines := shellCodeLines(content)
+	var out []finding
+	add := func(rule string, line int, message string) {
+		out = append(out, finding{rule: rule, file: path, line: line, message: message})
+	}
+
+	if dialect == shellBash {
+		enabled := map[string]bool{}
+		for _, l := range lines {
+			for _, words := range splitShellCommands(l.text) {
+				if idx := commandWord(words); idx >= 0 && words[idx].text == "set" {
+					collectSetOptions(words[idx+1:], enabled)
+				}
+			}
+		}
+		if !enabled["errexit"] || !enabled["nounset"] || !enabled["pipefail"] {
+			add(shellRuleStrictMode, 1, "bash script without set -euo pipefail")
+		}
+	}
+
+	for i, l := range lines {
+		if m := shellcheckDisableLine.FindStringSubmatch(l.text); m != nil {
+			reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(m[1]), "#"))
+			if reason == "" && !isJustificationComment(lines, i-1) {
+				add(shellRuleShellcheckNote, l.number, "shellcheck disable without a reason")
+			}
+			continue
+		}
+		if dialect == shellPOSIX {
+			code := singleQuotedPattern.ReplaceAllString(stripShellComment(l.text), "''")
+			for _, b := range shellBashisms {
+				if b.pattern.MatchString(code) {
+					add(shellRuleBashism, l.number, fmt.Sprintf("bashism in sh script: %s", b.name))
+				}
+			}
+		}
+		for _, words := range splitShellCommands(l.text) {
+			idx := commandWord(words)
+			if idx < 0 {
+				continue
+			}
+			name := words[idx].text
+			for _, w := range words[idx+1:] {
+				if (name == "rm" || name == "cd") && w.unquotedExpansion {
+					add(shellRuleUnquotedRmCd, l.number, fmt.Sprintf("unquoted expansion in %s: %s", name, w.text))
+					break
+				}
+				if name == "eval" && w.expansion {
+					add(shellRuleEval, l.number, fmt.Sprintf("eval of expanded input: %s", w.text))
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// isJustificationComment reports whether lines[i] is a plain comment that can
+// explain the shellcheck directive below it.
+func isJustificationComment(lines []shellLine, i int) bool {
+	if i < 0 {
+		return false
+	}
+	text := strings.TrimSpace(lines[i].text)
+	if !strings.HasPrefix(text, "#") || strings.HasPrefix(text, "#!") {
+		return false
+	}
+	return !shellcheckDisableLine.MatchString(text) && strings.TrimSpace(strings.TrimPrefix(text, "#")) != ""
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func shellRuleLines(findings []finding) string {
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestEvaluateShellScriptAcceptsRepoScripts(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "ops", "ci", "*.sh"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("glob ops/ci scripts: %v", err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if findings := evaluateShellScript(path, string(content), scriptDialect(path, string(content))); len(findings) > 0 {
+			t.Errorf("%s should pass the shell policy:\n%s", path, shellRuleLines(findings))
+		}
+	}
+}
+
+func TestEvaluateShellScriptBashRules(t *testing.T) {
+	script := strings.Join([]string{
+		"#!/usr/bin/env bash",
+		"set -eu",
+		`rm -rf $OUT_DIR/cache`,
+		`rm -rf "$OUT_DIR/cache"`,
+		`cd ${REPO_DIR}`,
+		`cd "$(dirname "$0")"`,
+		`[[ -n "$x" ]] && cd $HOME/dev`,
+		`eval "$1"`,
+		`eval 'set -x'`,
+		"# shellcheck disable=SC2086",
+		"# word splitting is intended here",
+		"# shellcheck disable=SC2086",
+		"# shellcheck disable=SC2016 # expanded remotely",
+		`echo "rm -rf $OUT_DIR"`,
+		"cat <<EOF",
+		"cd $NOT_CODE",
+		"EOF",
+	}, "\n")
+	got := shellRuleLines(evaluateShellScript("x.sh", script, shellBash))
+	want := strings.Join([]string{
+		"x.sh:1 shell-missing-strict-mode: bash script without set -euo pipefail",
+		"x.sh:3 shell-unquoted-var-in-rm-cd: unquoted expansion in rm: $OUT_DIR/cache",
+		"x.sh:5 shell-unquoted-var-in-rm-cd: unquoted expansion in cd: ${REPO_DIR}",
+		"x.sh:7 shell-unquoted-var-in-rm-cd: unquoted expansion in cd: $HOME/dev",
+		`x.sh:8 shell-eval-expanded-input: eval of expanded input: "$1"`,
+		"x.sh:10 shell-unjustified-shellcheck-disable: shellcheck disable without a reason",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected findings:\n%s\nwant:\n%s", got, want)
+	}
+
+}
+
+func TestEvaluateShellScriptStrictModeForms(t *testing.T) {
+	for _, setup := range []string{
+		"set -Eeuo pipefail",
+		"set -eu -o pipefail",
+		"set -ue -o pipefail",
+		"set -o pipefail -eu",
+		"set -o errexit -o pipefail -o nounset",
+		"set -eu\nset -o pipefail",
+		"main() {\n  set -euo pipefail\n}",
+	} {
+		script := "#!/bin/bash\n" + setup + "\n"
+		if findings := evaluateShellScript("y.sh", script, shellBash); len(findings) != 0 {
+			t.Errorf("%q should satisfy strict mode: %s", setup, shellRuleLines(findings))
+		}
+	}
+	for _, setup := range []string{
+		"set -eu",
+		"set -e -o pipefail",
+		"set -- -euo pipefail",
+		"echo set -euo pipefail",
+		"# set -euo pipefail",
+	} {
+		script := "#!/bin/bash\n" + setup + "\n"
+		if got := shellRuleLines(evaluateShellScript("y.sh", script, shellBash)); !strings.Contains(got, shellRuleStrictMode) {
+			t.Errorf("%q should not satisfy strict mode: %q", setup, got)
+		}
+	}
+}
+
+func TestEvaluateShellScriptBashismsInSh(t *testing.T) {
+	script := strings.Join([]string{
+		"#!/usr/bin/env sh",
+		`if [[ -n "$x" ]]; then echo ok; fi`,
+		`if [ "$x" == "y" ]; then echo ok; fi`,
+		`source ./env.sh`,
+		`echo '[[ in quotes is fine ]]'`,
+		`y="${x:-default}"`,
+		`z="${x//a/b}"`,
+		`# [[ in a comment is fine ]]`,
+	}, "\n")
+	got := shellRuleLines(evaluateShellScript("w.sh", script, scriptDialect("w.sh", script)))
+	want := strings.Join([]string{
+		"w.sh:2 shell-bashism-in-sh: bashism in sh script: [[ ]]",
+		"w.sh:3 shell-bashism-in-sh: bashism in sh script: == in [ ]",
+		"w.sh:4 shell-bashism-in-sh: bashism in sh script: bash builtin",
+		"w.sh:7 shell-bashism-in-sh: bashism in sh script: bash parameter expansion",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected findings:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScriptDialect(t *testing.T) {
+	cases := []struct {
+		path, content string
+		want          shellDialect
+	}{
+		{"a.sh", "#!/usr/bin/env bash\n", shellBash},
+		{"bin/tool", "#!/bin/bash -e\n", shellBash},
+		{"bin/tool", "#!/bin/sh\n", shellPOSIX},
+		{"lib.sh", "echo hi\n", shellPOSIX},
+		{"bin/tool", "#!/usr/bin/env python3\n", shellNone},
+		{"README", "hello\n", shellNone},
+	}
+	for _, tc := range cases {
+		if got := scriptDialect(tc.path, tc.content); got != tc.want {
+			t.Errorf("scriptDialect(%q) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os/exec"
+	"strings"
+)
+
+// runStagedMode is the pre-commit fast path: it only reads the index, so it
+// checks exactly what is about to be committed. Secrets use the same rules as
+// the working tree scan; Go files are checked with gofmt.
+func runStagedMode(ctx context.Context) ([]finding, []string, error) {
+	fmt.Println("OK: verify-lite staged start")
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil, errors.New("git command not found")
+	}
+
+	blobs, findings, err := listStagedBlobs(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("staged scan failed: %w", err)
+	}
+	contentFindings, err := scanHistoryBlobs(ctx, blobs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("staged scan failed: %w", err)
+	}
+	findings = append(findings, contentFindings...)
+
+	var unformatted []string
+	goFiles := 0
+	for _, blob := range blobs {
+		if !strings.HasSuffix(blob.path, ".go") {
+			continue
+		}
+		goFiles++
+		content, err := exec.CommandContext(ctx, "git", "cat-file", "blob", blob.sha).Output()
+		if err != nil {
+			return nil, nil, fmt.Errorf("staged scan failed: git cat-file %s: %w", blob.path, err)
+		}
+		formatted, err := format.Source(content)
+		if err != nil || !bytes.Equal(formatted, content) {
+			unformatted = append(unformatted, blob.path)
+		}
+	}
+
+	details := []string{
+		"mode=staged",
+		fmt.Sprintf("staged_files=%d", len(blobs)),
+		fmt.Sprintf("staged_go_files=%d", goFiles),
+		fmt.Sprintf("findings=%d", len(findings)),
+	}
+	for _, path := range unformatted {
+		details = append(details, "staged_unformatted="+path)
+	}
+
+	var errs []string
+	if len(findings) > 0 {
+		for _, f := range findings {
+			fmt.Printf("ERROR: verify-lite staged_secret_scan %s\n", f)
+		}
+		errs = append(errs, findingsError(findings).Error())
+	}
+	if len(unformatted) > 0 {
+		for _, path := range unformatted {
+			fmt.Printf("ERROR: verify-lite staged_gofmt file=%s\n", path)
+		}
+		errs = append(errs, "gofmt check failed; unformatted staged files: "+strings.Join(unformatted, ", "))
+	}
+	if len(errs) > 0 {
+		return findings, details, errors.New(strings.Join(errs, "; "))
+	}
+	fmt.Printf("OK: verify-lite staged done files=%d go_files=%d\n", len(blobs), goFiles)
+	return findings, details, nil
+}
+
+// listStagedBlobs returns the index blobs of added, copied and modified paths.
+// Signing files are reported by path, like the working tree scan.
+func listStagedBlobs(ctx context.Context) ([]historyBlob, []finding, error) {
+	out, err := exec.CommandContext(ctx, "git", "diff", "--cached", "--raw", "--no-abbrev", "--no-renames", "--diff-filter=ACM").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("git diff --cached: %w", err)
+	}
+	var blobs []historyBlob
+	var findings []finding
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		sha, path, ok := parseRawDiffLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if isMobileSensitivePath(path) {
+			findings = append(findings, finding{rule: "mobile-signing-file", file: path, message: "pattern=" + mobileSigningPattern})
+			continue
+		}
+		blobs = append(blobs, historyBlob{sha: sha, path: path})
+	}
+	return blobs, findings, scanner.Err()
+}
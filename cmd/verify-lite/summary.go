@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// publishGHA writes the job summary ($GITHUB_STEP_SUMMARY) and step outputs
+// ($GITHUB_OUTPUT) from the final status file. Both are optional; a write
+// failure is logged and does not change the run status.
+func publishGHA(cfg config, getenv func(string) string, w io.Writer) {
+	summaryPath, outputPath := getenv("GITHUB_STEP_SUMMARY"), getenv("GITHUB_OUTPUT")
+	if summaryPath == "" && outputPath == "" {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(cfg.outDir, "verify-lite.status"))
+	if err != nil {
+		content = []byte("status=ERROR\nreason=status_file_missing\n")
+	}
+	doc := parseSummaryStatus(string(content))
+	if summaryPath != "" {
+		if err := appendFile(summaryPath, renderSummary(doc, getenv)); err != nil {
+			fmt.Fprintf(w, "ERROR: verify-lite gha_summary err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(w, "OK: verify-lite gha_summary path=%s\n", summaryPath)
+		}
+	}
+	if outputPath != "" {
+		if err := appendFile(outputPath, renderOutputs(doc)); err != nil {
+			fmt.Fprintf(w, "ERROR: verify-lite gha_output err=%s\n", err.Error())
+		} else {
+			fmt.Fprintf(w, "OK: verify-lite gha_output path=%s\n", outputPath)
+		}
+	}
+}
+
+// summaryStatus is the part of verify-lite.status the summary shows.
+type summaryStatus struct {
+	values   map[string]string
+	checks   []map[string]string
+	failures []map[string]string
+	// output holds go_test_failure_output lines per failureKey, so
+	// same-named tests in different packages stay apart.
+	output map[string][]string
+}
+
+func parseSummaryStatus(content string) summaryStatus {
+	doc := summaryStatus{values: map[string]string{}, output: map[string][]string{}}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "OK:") || strings.HasPrefix(line, "SKIP:") || strings.HasPrefix(line, "ERROR:") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "check":
+			doc.checks = append(doc.checks, summaryFields(line))
+		case "go_test_failure":
+			doc.failures = append(doc.failures, summaryFields(value))
+		case "go_test_failure_output":
+			parts := strings.SplitN(value, " ", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			key := failureKey(summaryFields(parts[0] + " " + parts[1]))
+			doc.output[key] = append(doc.output[key], parts[2])
+		default:
+			// A repeated key keeps its first value, except reason, where a
+			// later line replaces the earlier one.
+			if _, seen := doc.values[key]; !seen || key == "reason" {
+				doc.values[key] = value
+			}
+		}
+	}
+	return doc
+}
+
+// summaryFields splits "k=v k=v ..." status lines.
+func failureKey(fields map[string]string) string {
+	return fields["package"] + " " + fields["test"]
+}
+
+func summaryFields(line string) map[string]string {
+	fields := map[string]string{}
+	for _, token := range strings.Fields(line) {
+		if key, value, ok := strings.Cut(token, "="); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+func renderSummary(doc summaryStatus, getenv func(string) string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## verify-lite: %s\n\n", doc.values["status"])
+	if mode := doc.values["mode"]; mode != "" {
+		fmt.Fprintf(&b, "- mode: `%s`\n", mode)
+	}
+	if ecosystems := doc.values["ecosystems"]; ecosystems != "" {
+		fmt.Fprintf(&b, "- ecosystems: `%s`\n", ecosystems)
+	}
+	if findings := doc.values["findings"]; findings != "" {
+		fmt.Fprintf(&b, "- findings: %s\n", findings)
+	}
+	if reason := doc.values["reason"]; reason != "" {
+		fmt.Fprintf(&b, "- reason: `%s`\n", reason)
+	}
+
+	if len(doc.checks) > 0 {
+		b.WriteString("\n| check | status | duration |\n|---|---|---|\n")
+		for _, c := range doc.checks {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", tableCell(c["check"]), c["status"], formatMS(c["duration_ms"]))
+		}
+	}
+
+	if len(doc.failures) > 0 {
+		b.WriteString("\n**failing tests**\n\n")
+		for _, f := range doc.failures {
+			name := f["package"]
+			if f["test"] != "-" {
+				name += "." + f["test"]
+			}
+			if f["file"] != "" {
+				fmt.Fprintf(&b, "- `%s` (%s:%s)\n", name, f["file"], f["line"])
+			} else {
+				fmt.Fprintf(&b, "- `%s`\n", name)
+			}
+		}
+		for _, f := range doc.failures {
+			key := failureKey(f)
+			lines := doc.output[key]
+			if len(lines) == 0 {
+				continue
+			}
+			name := f["package"]
+			if f["test"] != "-" {
+				name += "." + f["test"]
+			}
+			fmt.Fprintf(&b, "\n<details><summary>%s: last %d output lines</summary>\n\n```text\n", name, len(lines))
+			for _, line := range lines {
+				b.WriteString(strings.ReplaceAll(line, "```", "'''") + "\n")
+			}
+			b.WriteString("```\n\n</details>\n")
+			// Output is keyed by package and test; show it once.
+			delete(doc.output, key)
+		}
+	}
+
+	b.WriteString("\n**artifacts**\n\n")
+	b.WriteString("- status: `verify-lite.status`\n")
+	if sarif := doc.values["sarif"]; sarif != "" {
+		fmt.Fprintf(&b, "- sarif: `%s`\n", sarif)
+	}
+	if durations := doc.values["go_test_durations"]; durations != "" {
+		fmt.Fprintf(&b, "- go test durations: `%s`\n", durations)
+	}
+	if url := runURL(getenv); url != "" {
+		fmt.Fprintf(&b, "- run: [%s](%s#artifacts)\n", url, url)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderOutputs sets status, reason and the failure counts so later steps
+// read steps.<id>.outputs.* instead of grepping the status file. Values are
+// written in the multiline form and are never rewritten.
+func renderOutputs(doc summaryStatus) string {
+	failedChecks := 0
+	for _, c := range doc.checks {
+		if c["status"] == "ERROR" {
+			failedChecks++
+		}
+	}
+	pairs := [][2]string{
+		{"status", doc.values["status"]},
+		{"reason", doc.values["reason"]},
+		{"findings", doc.values["findings"]},
+		{"checks_failed", strconv.Itoa(failedChecks)},
+		{"tests_failed", strconv.Itoa(len(doc.failures))},
+	}
+	var b strings.Builder
+	for _, p := range pairs {
+		delim := outputDelimiter(p[1])
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", p[0], delim, p[1], delim)
+	}
+	return b.String()
+}
+
+// outputDelimiter returns the end marker for one name<<marker output; it is
+// lengthened until value does not contain it.
+func outputDelimiter(value string) string {
+	delim := "CI_SELF_EOF"
+	for strings.Contains(value, delim) {
+		delim += "_"
+	}
+	return delim
+}
+
+func runURL(getenv func(string) string) string {
+	server, repo, id := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, id)
+}
+
+func formatMS(raw string) string {
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return "-"
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+}
+
+func tableCell(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, "|", `\|`)
+}
+
+func appendFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
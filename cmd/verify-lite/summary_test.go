@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishGHAWritesSummaryAndOutputs(t *testing.T) {
+	cfg := config{outDir: t.TempDir(), stamp: "20260301T000000Z"}
+	details := []string{
+		"ecosystems=go",
+		"findings=0",
+		"check=secret_scan status=OK duration_ms=120",
+		"check=go_test status=ERROR duration_ms=65400",
+		"go_test_failed_tests=1",
+		"go_test_failure=package=example.com/m/p test=TestParse file=p_test.go line=12",
+		"go_test_failure_output=package=example.com/m/p test=TestParse p_test.go:12: got 1, want 2",
+	}
+	if err := writeStatus(cfg, "ERROR", "failed_tests=1 example.com/m/p.TestParse", details); err != nil {
+		t.Fatal(err)
+	}
+	gha := t.TempDir()
+	env := map[string]string{
+		"GITHUB_STEP_SUMMARY": filepath.Join(gha, "summary.md"),
+		"GITHUB_OUTPUT":       filepath.Join(gha, "output"),
+		"GITHUB_SERVER_URL":   "https://github.com",
+		"GITHUB_REPOSITORY":   "owner/repo",
+		"GITHUB_RUN_ID":       "42",
+	}
+	var buf bytes.Buffer
+	publishGHA(cfg, func(key string) string { return env[key] }, &buf)
+	if !strings.Contains(buf.String(), "OK: verify-lite gha_summary path=") {
+		t.Fatalf("console:\n%s", buf.String())
+	}
+
+	summary, err := os.ReadFile(env["GITHUB_STEP_SUMMARY"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## verify-lite: ERROR",
+		"| secret_scan | OK | 100ms |",
+		"| go_test | ERROR | 1m5.4s |",
+		"- `example.com/m/p.TestParse` (p_test.go:12)",
+		"<details><summary>example.com/m/p.TestParse: last 1 output lines</summary>",
+		"p_test.go:12: got 1, want 2",
+		"(https://github.com/owner/repo/actions/runs/42#artifacts)",
+	} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+
+	output, err := os.ReadFile(env["GITHUB_OUTPUT"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "status<<CI_SELF_EOF\nERROR\nCI_SELF_EOF\n" +
+		"reason<<CI_SELF_EOF\nfailed_tests=1 example.com/m/p.TestParse\nCI_SELF_EOF\n" +
+		"findings<<CI_SELF_EOF\n0\nCI_SELF_EOF\n" +
+		"checks_failed<<CI_SELF_EOF\n1\nCI_SELF_EOF\n" +
+		"tests_failed<<CI_SELF_EOF\n1\nCI_SELF_EOF\n"
+	if string(output) != want {
+		t.Fatalf("output:\n%s", output)
+	}
+}
+
+func TestPublishGHAWithoutEnvDoesNothing(t *testing.T) {
+	var buf bytes.Buffer
+	publishGHA(config{outDir: t.TempDir()}, func(string) string { return "" }, &buf)
+	if buf.Len() != 0 {
+		t.Fatalf("console:\n%s", buf.String())
+	}
+}
+
+func TestRenderOutputsKeepsMultilineReason(t *testing.T) {
+	doc := summaryStatus{values: map[string]string{"status": "ERROR", "reason": "first line\nCI_SELF_EOF\nlast line"}}
+	got := renderOutputs(doc)
+	want := "reason<<CI_SELF_EOF_\nfirst line\nCI_SELF_EOF\nlast line\nCI_SELF_EOF_\n"
+	if !strings.Contains(got, want) {
+		t.Fatalf("outputs:\n%s", got)
+	}
+}
+
+func TestRenderSummaryKeepsOutputPerPackage(t *testing.T) {
+	doc := parseSummaryStatus(strings.Join([]string{
+		"status=ERROR",
+		"go_test_failure=package=example.com/m/a test=TestParse",
+		"go_test_failure_output=package=example.com/m/a test=TestParse a says",
+		"go_test_failure=package=example.com/m/b test=TestParse",
+		"go_test_failure_output=package=example.com/m/b test=TestParse b says",
+		"go_test_failure=package=example.com/m/c test=-",
+		"go_test_failure_output=package=example.com/m/c test=- c does not build",
+	}, "\n"))
+	summary := renderSummary(doc, func(string) string { return "" })
+	for _, want := range []string{
+		"<details><summary>example.com/m/a.TestParse: last 1 output lines</summary>\n\n```text\na says\n```",
+		"<details><summary>example.com/m/b.TestParse: last 1 output lines</summary>\n\n```text\nb says\n```",
+		"<details><summary>example.com/m/c: last 1 output lines</summary>\n\n```text\nc does not build\n```",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+}
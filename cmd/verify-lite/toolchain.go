@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// toolchainSource is one place that declares a pinned tool version.
+type toolchainSource struct {
+	tool    string
+	path    string
+	extract func(content string) string
+}
+
+var (
+	goModGoPattern         = regexp.MustCompile(`(?m)^go\s+(\S+)\s*$`)
+	dockerGolangPattern    = regexp.MustCompile(`(?m)^FROM\s+(?:--platform=\S+\s+)?golang:(\d+(?:\.\d+)*)`)
+	goBuilderImagePattern  = regexp.MustCompile(`^golang:(\d+(?:\.\d+)*)`)
+	runnerVersionPattern   = regexp.MustCompile(`(?m)^\s*runnerVersion\s*=\s*"([^"]+)"`)
+	runnerLockPattern      = regexp.MustCompile(`\*\*actions/runner v([0-9][^*\s]*)\*\*`)
+	tomlSectionPattern     = regexp.MustCompile(`^\[([^\]]+)\]\s*(?:#.*)?$`)
+	tomlStringValuePattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*=\s*"([^"]*)"`)
+)
+
+// toolchainSources lists every declaration that must agree per tool. Sources
+// whose file is absent are ignored, so other repos only compare what they have.
+var toolchainSources = []toolchainSource{
+	{tool: "go", path: "go.mod", extract: func(c string) string { return firstSubmatch(goModGoPattern, c) }},
+	{tool: "go", path: "mise.toml", extract: func(c string) string { return tomlString(c, "tools", "go") }},
+	{tool: "go", path: "ci/image/versions.lock", extract: func(c string) string { return tomlString(c, "toolchain", "go") }},
+	{tool: "go", path: "ci/image/versions.lock#go_builder_image", extract: func(c string) string {
+		return firstSubmatch(goBuilderImagePattern, tomlString(c, "notes", "go_builder_image"))
+	}},
+	{tool: "go", path: "ci/image/Dockerfile", extract: func(c string) string { return firstSubmatch(dockerGolangPattern, c) }},
+	{tool: "actions-runner", path: "cmd/runner_setup/main.go", extract: func(c string) string { return firstSubmatch(runnerVersionPattern, c) }},
+	{tool: "actions-runner", path: "docs/ci/RUNNER_LOCK.md", extract: func(c string) string { return firstSubmatch(runnerLockPattern, c) }},
+}
+
+func firstSubmatch(re *regexp.Regexp, content string) string {
+	m := re.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// tomlString reads a string value from a flat TOML section; enough for
+// mise.toml and versions.lock.
+func tomlString(content, section, key string) string {
+	current := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if m := tomlSectionPattern.FindStringSubmatch(line); m != nil {
+			current = strings.TrimSpace(m[1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if m := tomlStringValuePattern.FindStringSubmatch(line); m != nil && m[1] == key {
+			return m[2]
+		}
+	}
+	return ""
+}
+
+// toolchainVersion is one source's declared value. value is empty when the
+// file exists but the declaration was not found.
+type toolchainVersion struct {
+	tool   string
+	source string
+	value  string
+}
+
+func readToolchainVersions(sources []toolchainSource) ([]toolchainVersion, error) {
+	var out []toolchainVersion
+	for _, s := range sources {
+		file, _, _ := strings.Cut(s.path, "#")
+		content, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, toolchainVersion{tool: s.tool, source: s.path, value: s.extract(string(content))})
+	}
+	return out, nil
+}
+
+// toolchainDrift returns the tools whose sources disagree or lack a value.
+// A tool declared in only one place has nothing to agree with.
+func toolchainDrift(versions []toolchainVersion) []string {
+	byTool := map[string][]toolchainVersion{}
+	var tools []string
+	for _, v := range versions {
+		if _, ok := byTool[v.tool]; !ok {
+			tools = append(tools, v.tool)
+		}
+		byTool[v.tool] = append(byTool[v.tool], v)
+	}
+	var drift []string
+	for _, tool := range tools {
+		list := byTool[tool]
+		if len(list) < 2 {
+			continue
+		}
+		for _, v := range list {
+			if v.value == "" || v.value != list[0].value {
+				drift = append(drift, tool)
+				break
+			}
+		}
+	}
+	return drift
+}
+
+// toolchainTable renders the sources as a markdown table for the console.
+func toolchainTable(versions []toolchainVersion) string {
+	var b strings.Builder
+	b.WriteString("| tool | source | version |\n|---|---|---|\n")
+	for _, v := range versions {
+		value := v.value
+		if value == "" {
+			value = "(not found)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", v.tool, v.source, value)
+	}
+	return b.String()
+}
+
+// runToolchainCheck fails when pinned versions disagree and prints the table
+// of every source so the stale one is obvious.
+func runToolchainCheck(context.Context) checkOutcome {
+	versions, err := readToolchainVersions(toolchainSources)
+	if err != nil {
+		return checkOutcome{err: fmt.Errorf("toolchain check failed: %w", err)}
+	}
+	if len(versions) < 2 {
+		return checkOutcome{skip: "no_pinned_toolchain"}
+	}
+	var details []string
+	for _, v := range versions {
+		details = append(details, fmt.Sprintf("toolchain_version=tool=%s source=%s value=%s", v.tool, v.source, v.value))
+	}
+	drift := toolchainDrift(versions)
+	if len(drift) == 0 {
+		return checkOutcome{details: details}
+	}
+	fmt.Print(toolchainTable(versions))
+	var parts []string
+	for _, tool := range drift {
+		var values []string
+		for _, v := range versions {
+			if v.tool == tool {
+				values = append(values, v.source+"="+v.value)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", tool, strings.Join(values, ", ")))
+	}
+	return checkOutcome{details: details, err: fmt.Errorf("toolchain version drift: %s", strings.Join(parts, "; "))}
+}
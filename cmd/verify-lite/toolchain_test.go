@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func toolchainRepo(t *testing.T, dockerGo string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":    "module example.com/app\n\ngo 1.25.6\n",
+		"mise.toml": "[tools]\ngo = \"1.25.6\"\n",
+		"ci/image/versions.lock": "[toolchain]\ngo = \"1.25.6\"\n\n[notes]\n" +
+			"go_builder_image = \"golang:1.25.6-bookworm@sha256:abc\"\n",
+		"ci/image/Dockerfile":      "FROM golang:" + dockerGo + "-bookworm@sha256:abc AS go-builder\n",
+		"cmd/runner_setup/main.go": "package main\n\nconst (\n\trunnerVersion = \"2.334.0\"\n)\n",
+		"docs/ci/RUNNER_LOCK.md":   "## 固定バージョン\n\n- **actions/runner v2.334.0**\n",
+	})
+	return dir
+}
+
+func TestRunToolchainCheckPassesWhenVersionsAgree(t *testing.T) {
+	t.Chdir(toolchainRepo(t, "1.25.6"))
+	outcome := runToolchainCheck(context.Background())
+	if outcome.err != nil || outcome.skip != "" {
+		t.Fatalf("outcome=%+v", outcome)
+	}
+	if len(outcome.details) != len(toolchainSources) {
+		t.Fatalf("details=%v", outcome.details)
+	}
+}
+
+func TestRunToolchainCheckReportsDrift(t *testing.T) {
+	dir := toolchainRepo(t, "1.24.2")
+	writeFiles(t, dir, map[string]string{"docs/ci/RUNNER_LOCK.md": "no version here\n"})
+	t.Chdir(dir)
+
+	outcome := runToolchainCheck(context.Background())
+	if outcome.err == nil {
+		t.Fatal("expected drift error")
+	}
+	msg := outcome.err.Error()
+	for _, want := range []string{"go (go.mod=1.25.6,", "ci/image/Dockerfile=1.24.2", "actions-runner (cmd/runner_setup/main.go=2.334.0, docs/ci/RUNNER_LOCK.md=)"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("err=%s missing %q", msg, want)
+		}
+	}
+}
+
+func TestToolchainTableMarksMissingValues(t *testing.T) {
+	table := toolchainTable([]toolchainVersion{
+		{tool: "go", source: "go.mod", value: "1.25.6"},
+		{tool: "go", source: "mise.toml"},
+	})
+	want := "| tool | source | version |\n|---|---|---|\n| go | go.mod | 1.25.6 |\n| go | mise.toml | (not found) |\n"
+	if table != want {
+		t.Fatalf("table=%q", table)
+	}
+}
+
+func TestRunToolchainCheckSkipsWithoutPins(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": "module example.com/app\n\ngo 1.25.6\n"})
+	t.Chdir(dir)
+	if outcome := runToolchainCheck(context.Background()); outcome.skip != "no_pinned_toolchain" {
+		t.Fatalf("outcome=%+v", outcome)
+	}
+}
+
+func TestRepoToolchainVersionsAgree(t *testing.T) {
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ci", "image", "versions.lock")); err != nil {
+		t.Skip("repo layout not available")
+	}
+	t.Chdir(root)
+	if outcome := runToolchainCheck(context.Background()); outcome.err != nil {
+		t.Fatal(outcome.err)
+	}
+}
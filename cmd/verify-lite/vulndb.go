@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// vulnDBDir is the local mirror written by `ci-self vulndb sync`. It mirrors
+// defaultDir in cmd/vulndb.
+func vulnDBDir() string {
+	if dir := strings.TrimSpace(os.Getenv("CI_SELF_VULNDB_DIR")); dir != "" {
+		return dir
+	}
+	cacheHome := strings.TrimSpace(os.Getenv("XDG_CACHE_HOME"))
+	if cacheHome == "" {
+		cacheHome = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "ci-self", "vulndb")
+}
+
+// osvEntry is the subset of an OSV record the check reads.
+type osvEntry struct {
+	ID       string        `json:"id"`
+	Aliases  []string      `json:"aliases"`
+	Summary  string        `json:"summary"`
+	Affected []osvAffected `json:"affected"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string `json:"type"`
+		Events []struct {
+			Introduced string `json:"introduced,omitempty"`
+			Fixed      string `json:"fixed,omitempty"`
+		} `json:"events"`
+	} `json:"ranges"`
+	EcosystemSpecific struct {
+		Imports []struct {
+			Path    string   `json:"path"`
+			Symbols []string `json:"symbols"`
+		} `json:"imports"`
+	} `json:"ecosystem_specific"`
+}
+
+// vulnDB reads the index/ and ID/ layout of the Go vulnerability database.
+type vulnDB struct {
+	dir      string
+	modified string
+	byModule map[string][]string
+}
+
+func openVulnDB(dir string) (*vulnDB, error) {
+	var meta struct {
+		Modified string `json:"modified"`
+	}
+	if err := readJSONFile(filepath.Join(dir, "index", "db.json"), &meta); err != nil {
+		return nil, err
+	}
+	var modules []struct {
+		Path  string `json:"path"`
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	}
+	if err := readJSONFile(filepath.Join(dir, "index", "modules.json"), &modules); err != nil {
+		return nil, err
+	}
+	db := &vulnDB{dir: dir, modified: meta.Modified, byModule: map[string][]string{}}
+	for _, m := range modules {
+		for _, v := range m.Vulns {
+			db.byModule[m.Path] = append(db.byModule[m.Path], v.ID)
+		}
+	}
+	return db, nil
+}
+
+func readJSONFile(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func (db *vulnDB) entry(id string) (osvEntry, error) {
+	var e osvEntry
+	err := readJSONFile(filepath.Join(db.dir, "ID", id+".json"), &e)
+	return e, err
+}
+
+// goModuleUse is one module of the build list and the packages the main
+// module imports from it.
+type goModuleUse struct {
+	path     string
+	version  string
+	packages map[string]bool
+}
+
+// vulnMatch is one vulnerability affecting one required module version.
+type vulnMatch struct {
+	id       string
+	aliases  []string
+	module   string
+	version  string
+	fixed    string
+	imported []string
+	symbols  []string
+}
+
+func (m vulnMatch) detail() string {
+	fixed := m.fixed
+	if fixed == "" {
+		fixed = "none"
+	}
+	line := fmt.Sprintf("go_vuln=id=%s module=%s version=%s fixed=%s", m.id, m.module, m.version, fixed)
+	if len(m.aliases) > 0 {
+		line += " aliases=" + strings.Join(m.aliases, ",")
+	}
+	if len(m.imported) > 0 {
+		line += " imported=" + strings.Join(m.imported, ",")
+	}
+	if len(m.symbols) > 0 {
+		line += " symbols=" + strings.Join(m.symbols, ",")
+	}
+	return line
+}
+
+// matchVulns returns the entries affecting the given modules. The standard
+// library is only reported when a vulnerable package is imported, since every
+// build depends on it.
+func matchVulns(db *vulnDB, modules []goModuleUse) ([]vulnMatch, error) {
+	var matches []vulnMatch
+	for _, mod := range modules {
+		for _, id := range db.byModule[mod.path] {
+			e, err := db.entry(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range e.Affected {
+				if a.Package.Name != mod.path {
+					continue
+				}
+				affected, fixed := osvAffects(a, mod.version)
+				if !affected {
+					continue
+				}
+				m := vulnMatch{id: e.ID, aliases: e.Aliases, module: mod.path, version: mod.version, fixed: fixed}
+				for _, imp := range a.EcosystemSpecific.Imports {
+					if !mod.packages[imp.Path] {
+						continue
+					}
+					m.imported = append(m.imported, imp.Path)
+					for _, sym := range imp.Symbols {
+						m.symbols = append(m.symbols, imp.Path+"."+sym)
+					}
+				}
+				if mod.path == "stdlib" && len(m.imported) == 0 {
+					continue
+				}
+				matches = append(matches, m)
+				break
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].module != matches[j].module {
+			return matches[i].module < matches[j].module
+		}
+		return matches[i].id < matches[j].id
+	})
+	return matches, nil
+}
+
+// osvAffects reports whether version falls in one of the SEMVER ranges and
+// the fixed version that closes that range.
+func osvAffects(a osvAffected, version string) (bool, string) {
+	v := strings.TrimSuffix(strings.TrimPrefix(version, "v"), "+incompatible")
+	for _, r := range a.Ranges {
+		if r.Type != "SEMVER" {
+			continue
+		}
+		in := false
+		for _, ev := range r.Events {
+			switch {
+			case ev.Introduced != "":
+				if ev.Introduced == "0" || compareSemver(v, ev.Introduced) >= 0 {
+					in = true
+				}
+			case ev.Fixed != "":
+				if compareSemver(v, ev.Fixed) < 0 {
+					if in {
+						return true, "v" + ev.Fixed
+					}
+				} else {
+					in = false
+				}
+			}
+		}
+		if in {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+// compareSemver compares versions without the leading "v", following semver
+// precedence for prerelease identifiers.
+func compareSemver(a, b string) int {
+	a, _, _ = strings.Cut(a, "+")
+	b, _, _ = strings.Cut(b, "+")
+	aCore, aPre, _ := strings.Cut(a, "-")
+	bCore, bPre, _ := strings.Cut(b, "-")
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < 3; i++ {
+		if c := compareNumeric(semverPart(aParts, i), semverPart(bParts, i)); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	aIDs := strings.Split(aPre, ".")
+	bIDs := strings.Split(bPre, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		if aIDs[i] == bIDs[i] {
+			continue
+		}
+		_, aErr := strconv.Atoi(aIDs[i])
+		_, bErr := strconv.Atoi(bIDs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return compareNumeric(aIDs[i], bIDs[i])
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case aIDs[i] < bIDs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return compareNumeric(strconv.Itoa(len(aIDs)), strconv.Itoa(len(bIDs)))
+}
+
+func semverPart(parts []string, i int) string {
+	if i < len(parts) && parts[i] != "" {
+		return parts[i]
+	}
+	return "0"
+}
+
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+type goListModule struct {
+	Path    string        `json:"Path"`
+	Version string        `json:"Version"`
+	Main    bool          `json:"Main"`
+	Replace *goListModule `json:"Replace"`
+}
+
+type goListPackage struct {
+	ImportPath string        `json:"ImportPath"`
+	Standard   bool          `json:"Standard"`
+	Module     *goListModule `json:"Module"`
+}
+
+// decodeJSONStream reads the concatenated objects printed by go list -json.
+func decodeJSONStream[T any](r io.Reader) ([]T, error) {
+	dec := json.NewDecoder(r)
+	var out []T
+	for {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+// goModuleUses combines the build list (go list -m all) with the packages
+// imported by the main module (go list -deps). Standard library packages are
+// grouped under "stdlib" at the toolchain version.
+func goModuleUses(modulesJSON, depsJSON, goVersion string) ([]goModuleUse, error) {
+	modules, err := decodeJSONStream[goListModule](strings.NewReader(modulesJSON))
+	if err != nil {
+		return nil, fmt.Errorf("parse go list -m: %w", err)
+	}
+	packages, err := decodeJSONStream[goListPackage](strings.NewReader(depsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("parse go list -deps: %w", err)
+	}
+
+	uses := map[string]*goModuleUse{}
+	var order []string
+	add := func(path, version string) *goModuleUse {
+		if u, ok := uses[path]; ok {
+			return u
+		}
+		u := &goModuleUse{path: path, version: version, packages: map[string]bool{}}
+		uses[path] = u
+		order = append(order, path)
+		return u
+	}
+	for _, m := range modules {
+		if m.Main {
+			continue
+		}
+		version := m.Version
+		if m.Replace != nil {
+			if m.Replace.Version == "" {
+				// Replaced by a local directory; there is no version to match.
+				continue
+			}
+			version = m.Replace.Version
+		}
+		add(m.Path, version)
+	}
+	if v := strings.TrimPrefix(goVersion, "go"); v != "" {
+		v, _, _ = strings.Cut(v, " ")
+		add("stdlib", "v"+v)
+	}
+	for _, p := range packages {
+		switch {
+		case p.Standard:
+			if u, ok := uses["stdlib"]; ok {
+				u.packages[p.ImportPath] = true
+			}
+		case p.Module != nil:
+			if u, ok := uses[p.Module.Path]; ok {
+				u.packages[p.ImportPath] = true
+			}
+		}
+	}
+
+	out := make([]goModuleUse, 0, len(order))
+	for _, path := range order {
+		out = append(out, *uses[path])
+	}
+	return out, nil
+}
+
+// runGoVulnCheck matches the module's build list against the local vulnerability
+// database. It never touches the network: GOPROXY=off and a missing database is
+// a skip, so offline runners keep working.
+func runGoVulnCheck(ctx context.Context) checkOutcome {
+	dir := vulnDBDir()
+	db, err := openVulnDB(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return checkOutcome{skip: "vulndb_missing"}
+		}
+		return checkOutcome{err: fmt.Errorf("go vulnerability check failed: %w", err)}
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		return checkOutcome{err: errors.New("go command not found")}
+	}
+	details := []string{"go_vuln_db=" + dir, "go_vuln_db_modified=" + db.modified}
+
+	goList := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "go", append([]string{"list"}, args...)...)
+		cmd.Env = append(os.Environ(), "GOPROXY=off")
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		return string(out), err
+	}
+	modulesJSON, err := goList("-m", "-json", "all")
+	if err != nil {
+		return checkOutcome{details: details, err: fmt.Errorf("go list -m failed: %w", err)}
+	}
+	depsJSON, err := goList("-deps", "-json", "./...")
+	if err != nil {
+		return checkOutcome{details: details, err: fmt.Errorf("go list -deps failed: %w", err)}
+	}
+	goVersion, err := runCommandCapture(ctx, "go", "env", "GOVERSION")
+	if err != nil {
+		return checkOutcome{details: details, err: fmt.Errorf("go env GOVERSION failed: %w", err)}
+	}
+
+	uses, err := goModuleUses(modulesJSON, depsJSON, strings.TrimSpace(goVersion))
+	if err != nil {
+		return checkOutcome{details: details, err: err}
+	}
+	matches, err := matchVulns(db, uses)
+	if err != nil {
+		return checkOutcome{details: details, err: fmt.Errorf("go vulnerability check failed: %w", err)}
+	}
+	details = append(details, fmt.Sprintf("go_vulns=%d", len(matches)))
+	if len(matches) == 0 {
+		return checkOutcome{details: details}
+	}
+	summary := make([]string, 0, len(matches))
+	for _, m := range matches {
+		details = append(details, m.detail())
+		fmt.Printf("ERROR: verify-lite %s\n", m.detail())
+		summary = append(summary, fmt.Sprintf("%s %s@%s", m.id, m.module, m.version))
+	}
+	return checkOutcome{details: details, err: fmt.Errorf("go vulnerability check failed: %s", strings.Join(summary, ", "))}
+}
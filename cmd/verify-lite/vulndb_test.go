@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeVulnDB writes entries in the index/ and ID/ layout of vuln.go.dev.
+func writeVulnDB(t *testing.T, entries ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	byModule := map[string][]map[string]string{}
+	for _, raw := range entries {
+		var e osvEntry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range e.Affected {
+			byModule[a.Package.Name] = append(byModule[a.Package.Name], map[string]string{"id": e.ID, "modified": "2026-01-01T00:00:00Z"})
+		}
+		writeFiles(t, dir, map[string]string{filepath.Join("ID", e.ID+".json"): raw})
+	}
+	var modules []map[string]any
+	for path, vulns := range byModule {
+		modules = append(modules, map[string]any{"path": path, "vulns": vulns})
+	}
+	content, err := json.Marshal(modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"index/modules.json": string(content),
+		"index/db.json":      `{"modified":"2026-01-01T00:00:00Z"}`,
+	})
+	return dir
+}
+
+const vulnExampleEntry = `{
+  "id": "GO-2099-0001",
+  "aliases": ["CVE-2099-0001"],
+  "affected": [{
+    "package": {"name": "example.com/lib", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}, {"introduced": "2.0.0"}, {"fixed": "2.0.3"}]}],
+    "ecosystem_specific": {"imports": [{"path": "example.com/lib/parse", "symbols": ["Parse"]}]}
+  }]
+}`
+
+const vulnStdlibEntry = `{
+  "id": "GO-2099-0002",
+  "affected": [{
+    "package": {"name": "stdlib", "ecosystem": "Go"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "999.0.0"}]}],
+    "ecosystem_specific": {"imports": [{"path": "net/http", "symbols": ["Server.Serve"]}]}
+  }]
+}`
+
+func TestCompareSemver(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "1.10.0", -1},
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3-rc.1", "1.2.3", -1},
+		{"1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{"0.0.0-20240101000000-abcdef123456", "0.1.0", -1},
+		{"2.0.0+build", "2.0.0", 0},
+	}
+	for _, tc := range cases {
+		if got := compareSemver(tc.a, tc.b); got != tc.want {
+			t.Fatalf("compareSemver(%s, %s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestOSVAffectsRanges(t *testing.T) {
+	var e osvEntry
+	if err := json.Unmarshal([]byte(vulnExampleEntry), &e); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{"v1.1.9", true, "v1.2.0"},
+		{"v1.2.0", false, ""},
+		{"v2.0.1", true, "v2.0.3"},
+		{"v2.0.3", false, ""},
+	}
+	for _, tc := range cases {
+		affected, fixed := osvAffects(e.Affected[0], tc.version)
+		if affected != tc.affected || fixed != tc.fixed {
+			t.Fatalf("osvAffects(%s) = %v %q, want %v %q", tc.version, affected, fixed, tc.affected, tc.fixed)
+		}
+	}
+}
+
+func TestMatchVulnsReportsRequiredModulesAndImports(t *testing.T) {
+	db, err := openVulnDB(writeVulnDB(t, vulnExampleEntry, vulnStdlibEntry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modulesJSON := `{"Path":"example.com/app","Main":true}
+{"Path":"example.com/lib","Version":"v1.1.0"}
+{"Path":"example.com/other","Version":"v0.1.0","Replace":{"Path":"../other"}}`
+	depsJSON := `{"ImportPath":"fmt","Standard":true}
+{"ImportPath":"example.com/lib/parse","Module":{"Path":"example.com/lib","Version":"v1.1.0"}}`
+
+	uses, err := goModuleUses(modulesJSON, depsJSON, "go1.27.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := matchVulns(db, uses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stdlib entry only covers net/http, which is not imported.
+	if len(matches) != 1 {
+		t.Fatalf("matches=%+v", matches)
+	}
+	got := matches[0].detail()
+	want := "go_vuln=id=GO-2099-0001 module=example.com/lib version=v1.1.0 fixed=v1.2.0 aliases=CVE-2099-0001 imported=example.com/lib/parse symbols=example.com/lib/parse.Parse"
+	if got != want {
+		t.Fatalf("detail=%q want %q", got, want)
+	}
+}
+
+func TestRunGoVulnCheckSkipsWithoutDatabase(t *testing.T) {
+	t.Setenv("CI_SELF_VULNDB_DIR", filepath.Join(t.TempDir(), "missing"))
+	outcome := runGoVulnCheck(context.Background())
+	if outcome.skip != "vulndb_missing" || outcome.err != nil {
+		t.Fatalf("outcome=%+v", outcome)
+	}
+}
+
+func TestRunGoVulnCheckFindsImportedStdlibPackage(t *testing.T) {
+	t.Setenv("CI_SELF_VULNDB_DIR", writeVulnDB(t, vulnStdlibEntry))
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.21\n",
+		"main.go": "package main\n\nimport _ \"net/http\"\n\nfunc main() {}\n",
+	})
+	t.Chdir(dir)
+
+	outcome := runGoVulnCheck(context.Background())
+	if outcome.err == nil || !strings.Contains(outcome.err.Error(), "GO-2099-0002 stdlib@") {
+		t.Fatalf("err=%v", outcome.err)
+	}
+	joined := strings.Join(outcome.details, "\n")
+	if !strings.Contains(joined, "go_vulns=1") || !strings.Contains(joined, "imported=net/http") {
+		t.Fatalf("details=%s", joined)
+	}
+}
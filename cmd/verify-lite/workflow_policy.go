@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// workflowRule is a named policy evaluated against a parsed workflow file.
+type workflowRule struct {
+	id          string
+	description string
+	check       func(wf *workflowFile) []finding
+}
+
+var workflowRules = []workflowRule{
+	{
+		id:          "forbidden-pull-request-target",
+		description: "pull_request_target runs untrusted PR code with repository secrets",
+		check:       checkPullRequestTarget,
+	},
+	{
+		id:          "unpinned-uses",
+		description: "third-party actions and reusable workflows must be pinned to a 40-char commit SHA (fix with ci-self pin-actions)",
+		check:       checkUsesPinned,
+	},
+	{
+		id:          "self-hosted-without-fork-guard",
+		description: "self-hosted jobs need the owner guard and, for PR triggers, the fork guard",
+		check:       checkSelfHostedGuard,
+	},
+	{
+		id:          "permissions-write-all",
+		description: "permissions: write-all grants every scope to GITHUB_TOKEN",
+		check:       checkWriteAllPermissions,
+	},
+	{
+		id:          "missing-permissions",
+		description: "workflows must declare top-level permissions",
+		check:       checkTopLevelPermissions,
+	},
+	{
+		id:          "event-expression-in-run",
+		description: "${{ github.event.* }} in run: is a script injection vector; pass it through env instead",
+		check:       checkEventExpressionInRun,
+	},
+	{
+		id:          "secrets-in-pr-job",
+		description: "secrets must not be exposed to PR-triggered jobs without the fork guard",
+		check:       checkSecretsInPRJob,
+	},
+}
+
+// invalidWorkflowRule reports files the YAML reader could not parse.
+const invalidWorkflowRule = "invalid-workflow-yaml"
+
+// workflowRuleMetas describes every workflow rule for reporting.
+func workflowRuleMetas() []ruleMeta {
+	metas := make([]ruleMeta, 0, len(workflowRules)+1)
+	for _, rule := range workflowRules {
+		metas = append(metas, ruleMeta{id: rule.id, description: rule.description, securitySeverity: "7.5"})
+	}
+	return append(metas, ruleMeta{id: invalidWorkflowRule, description: "workflow file could not be read or parsed as YAML", securitySeverity: "5.0"})
+}
+
+type workflowFile struct {
+	path     string
+	root     *yamlNode
+	triggers map[string]*yamlNode
+	jobs     []workflowJob
+}
+
+type workflowJob struct {
+	id   string
+	line int
+	node *yamlNode
+}
+
+func (wf *workflowFile) prTriggered() bool {
+	return wf.triggers["pull_request"] != nil || wf.triggers["pull_request_target"] != nil
+}
+
+func runWorkflowPolicyScan() ([]finding, error) {
+	fmt.Println("OK: verify-lite workflow_policy_scan start")
+	root := filepath.Join(".github", "workflows")
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("SKIP: verify-lite workflow_policy_scan reason=missing_.github/workflows")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("workflow policy scan stat failed: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, errors.New(".github/workflows is not a directory")
+	}
+
+	var violations []finding
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".yml") && !strings.HasSuffix(d.Name(), ".yaml") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			violations = append(violations, finding{rule: invalidWorkflowRule, file: filepath.ToSlash(path), line: 1, message: "read_failed"})
+			return nil
+		}
+		violations = append(violations, evaluateWorkflow(filepath.ToSlash(path), string(content))...)
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("workflow policy scan failed: %w", walkErr)
+	}
+	for _, v := range violations {
+		fmt.Printf("ERROR: verify-lite workflow_policy_scan %s\n", v)
+	}
+	if len(violations) == 0 {
+		fmt.Println("OK: verify-lite workflow_policy_scan done")
+	}
+	return violations, nil
+}
+
+// evaluateWorkflow parses one workflow file and runs every rule against it.
+func evaluateWorkflow(path, content string) []finding {
+	root, err := parseYAML(content)
+	if err != nil {
+		line := 1
+		var yerr *yamlError
+		if errors.As(err, &yerr) {
+			line = yerr.line
+		}
+		return []finding{{rule: invalidWorkflowRule, file: path, line: line, message: err.Error()}}
+	}
+	if root.kind != yamlMapping {
+		return []finding{{rule: invalidWorkflowRule, file: path, line: root.line, message: "workflow is not a mapping"}}
+	}
+	wf := &workflowFile{path: path, root: root, triggers: workflowTriggers(root.get("on"))}
+	if jobs := root.get("jobs"); jobs != nil && jobs.kind == yamlMapping {
+		for i, key := range jobs.keys {
+			wf.jobs = append(wf.jobs, workflowJob{id: key.value, line: key.line, node: jobs.items[i]})
+		}
+	}
+
+	var out []finding
+	for _, rule := range workflowRules {
+		for _, f := range rule.check(wf) {
+			f.rule = rule.id
+			f.file = path
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].line < out[j].line })
+	return out
+}
+
+// workflowTriggers normalizes the scalar, sequence and mapping forms of on:.
+func workflowTriggers(on *yamlNode) map[string]*yamlNode {
+	triggers := map[string]*yamlNode{}
+	if on == nil {
+		return triggers
+	}
+	switch on.kind {
+	case yamlMapping:
+		for _, key := range on.keys {
+			triggers[key.value] = key
+		}
+	default:
+		for _, item := range on.scalarValues() {
+			triggers[item.value] = item
+		}
+	}
+	return triggers
+}
+
+func checkPullRequestTarget(wf *workflowFile) []finding {
+	if node := wf.triggers["pull_request_target"]; node != nil {
+		return []finding{{line: node.line, message: "forbidden pull_request_target"}}
+	}
+	return nil
+}
+
+var usesRefPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+func checkUsesPinned(wf *workflowFile) []finding {
+	var out []finding
+	check := func(node *yamlNode) {
+		if node == nil || node.kind != yamlScalar {
+			return
+		}
+		ref := strings.TrimSpace(node.value)
+		if ref == "" {
+			out = append(out, finding{line: node.line, message: "empty uses ref"})
+			return
+		}
+		if strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "docker://") {
+			return
+		}
+		parts := strings.Split(ref, "@")
+		if len(parts) != 2 {
+			out = append(out, finding{line: node.line, message: fmt.Sprintf("unpinned uses (%s)", ref)})
+			return
+		}
+		if !usesRefPattern.MatchString(parts[1]) {
+			out = append(out, finding{line: node.line, message: fmt.Sprintf("non-SHA uses (%s)", ref)})
+		}
+	}
+	for _, job := range wf.jobs {
+		check(job.node.get("uses"))
+		for _, step := range jobSteps(job) {
+			check(step.get("uses"))
+		}
+	}
+	return out
+}
+
+func jobSteps(job workflowJob) []*yamlNode {
+	steps := job.node.get("steps")
+	if steps == nil || steps.kind != yamlSequence {
+		return nil
+	}
+	return steps.items
+}
+
+var expressionWhitespace = regexp.MustCompile(`\s+`)
+
+func normalizeExpression(expr string) string {
+	return expressionWhitespace.ReplaceAllString(expr, "")
+}
+
+// hasOwnerGuard matches the SELF_HOSTED_OWNER check used in verify.yml.
+func hasOwnerGuard(ifExpr string) bool {
+	expr := normalizeExpression(ifExpr)
+	return strings.Contains(expr, "github.repository_owner==") ||
+		strings.Contains(expr, "==github.repository_owner") ||
+		strings.Contains(expr, "github.repository==") ||
+		strings.Contains(expr, "==github.repository")
+}
+
+// hasForkGuard matches checks that keep fork pull requests off the job.
+func hasForkGuard(ifExpr string) bool {
+	expr := normalizeExpression(ifExpr)
+	return strings.Contains(expr, "github.event.pull_request.head.repo.fork==false") ||
+		strings.Contains(expr, "!github.event.pull_request.head.repo.fork") ||
+		strings.Contains(expr, "github.event.pull_request.head.repo.full_name==github.repository")
+}
+
+func jobIf(job workflowJob) string {
+	if node := job.node.get("if"); node != nil && node.kind == yamlScalar {
+		return node.value
+	}
+	return ""
+}
+
+func isSelfHostedJob(job workflowJob) (bool, int) {
+	runsOn := job.node.get("runs-on")
+	if runsOn == nil {
+		return false, 0
+	}
+	labels := runsOn
+	if runsOn.kind == yamlMapping {
+		labels = runsOn.get("labels")
+	}
+	for _, label := range labels.scalarValues() {
+		if strings.EqualFold(strings.TrimSpace(label.value), "self-hosted") {
+			return true, runsOn.line
+		}
+	}
+	return false, 0
+}
+
+func checkSelfHostedGuard(wf *workflowFile) []finding {
+	var out []finding
+	for _, job := range wf.jobs {
+		selfHosted, line := isSelfHostedJob(job)
+		if !selfHosted {
+			continue
+		}
+		ifExpr := jobIf(job)
+		var missing []string
+		if !hasOwnerGuard(ifExpr) {
+			missing = append(missing, "owner")
+		}
+		if wf.prTriggered() && !hasForkGuard(ifExpr) {
+			missing = append(missing, "fork")
+		}
+		if len(missing) > 0 {
+			out = append(out, finding{line: line, message: fmt.Sprintf("self-hosted job %s missing %s guard", job.id, strings.Join(missing, "/"))})
+		}
+	}
+	return out
+}
+
+func checkWriteAllPermissions(wf *workflowFile) []finding {
+	var out []finding
+	check := func(node *yamlNode, scope string) {
+		if node != nil && node.kind == yamlScalar && strings.TrimSpace(node.value) == "write-all" {
+			out = append(out, finding{line: node.line, message: fmt.Sprintf("permissions: write-all (%s)", scope)})
+		}
+	}
+	check(wf.root.get("permissions"), "workflow")
+	for _, job := range wf.jobs {
+		check(job.node.get("permissions"), "job "+job.id)
+	}
+	return out
+}
+
+func checkTopLevelPermissions(wf *workflowFile) []finding {
+	if wf.root.get("permissions") == nil {
+		return []finding{{line: 1, message: "missing top-level permissions"}}
+	}
+	return nil
+}
+
+var eventExpressionPattern = regexp.MustCompile(`\$\{\{[^}]*\bgithub\.event\.[^}]*\}\}`)
+
+func checkEventExpressionInRun(wf *workflowFile) []finding {
+	var out []finding
+	for _, job := range wf.jobs {
+		for _, step := range jobSteps(job) {
+			run := step.get("run")
+			if run == nil || run.kind != yamlScalar {
+				continue
+			}
+			for _, loc := range eventExpressionPattern.FindAllStringIndex(run.value, -1) {
+				line := run.line + strings.Count(run.value[:loc[0]], "\n")
+				expr := run.value[loc[0]:loc[1]]
+				out = append(out, finding{line: line, message: fmt.Sprintf("%s interpolated into run script (job %s)", expr, job.id)})
+			}
+		}
+	}
+	return out
+}
+
+var secretsExpressionPattern = regexp.MustCompile(`\$\{\{[^}]*\bsecrets\.([A-Za-z0-9_]+)[^}]*\}\}`)
+
+func checkSecretsInPRJob(wf *workflowFile) []finding {
+	if !wf.prTriggered() {
+		return nil
+	}
+	var out []finding
+	for _, job := range wf.jobs {
+		if hasForkGuard(jobIf(job)) {
+			continue
+		}
+		if inherit := job.node.get("secrets"); inherit != nil && inherit.kind == yamlScalar && inherit.value == "inherit" {
+			out = append(out, finding{line: inherit.line, message: fmt.Sprintf("secrets: inherit in PR-triggered job %s", job.id)})
+		}
+		walkScalars(job.node, func(n *yamlNode) {
+			for _, match := range secretsExpressionPattern.FindAllStringSubmatch(n.value, -1) {
+				if match[1] == "GITHUB_TOKEN" {
+					continue
+				}
+				out = append(out, finding{line: n.line, message: fmt.Sprintf("secrets.%s exposed to PR-triggered job %s", match[1], job.id)})
+			}
+		})
+	}
+	return out
+}
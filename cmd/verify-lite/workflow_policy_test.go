@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func findingRules(findings []finding) map[string]int {
+	rules := map[string]int{}
+	for _, f := range findings {
+		rules[f.rule]++
+	}
+	return rules
+}
+
+func TestEvaluateWorkflowAcceptsRepoWorkflows(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", ".github", "workflows", "*.yml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("repo workflows not found: %v", err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if findings := evaluateWorkflow(path, string(content)); len(findings) > 0 {
+			t.Fatalf("unexpected findings for %s: %v", path, findings)
+		}
+	}
+}
+
+func TestEvaluateWorkflowFlagsFlowUsesAndMultiLineOn(t *testing.T) {
+	findings := evaluateWorkflow("wf.yml", `on:
+  push:
+  pull_request_target:
+    types: [opened]
+permissions: {}
+jobs:
+  a:
+    runs-on: ubuntu-latest
+    steps:
+      - { uses: actions/checkout@`+`v4 }
+`)
+	rules := findingRules(findings)
+	if rules["forbidden-pull-request-target"] != 1 || rules["unpinned-uses"] != 1 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+	for _, f := range findings {
+		if f.rule == "forbidden-pull-request-target" && f.line != 3 {
+			t.Fatalf("pull_request_target reported on wrong line: %v", f)
+		}
+		if f.rule == "unpinned-uses" && f.line != 10 {
+			t.Fatalf("uses reported on wrong line: %v", f)
+		}
+	}
+}
+
+func TestEvaluateWorkflowSelfHostedGuard(t *testing.T) {
+	findings := evaluateWorkflow("wf.yml", `on: [push, pull_request]
+permissions:
+  contents: read
+jobs:
+  unguarded:
+    runs-on: [self-hosted, mac-mini]
+    steps:
+      - run: echo hi
+  owner-only:
+    if: ${{ github.repository_owner == vars.SELF_HOSTED_OWNER }}
+    runs-on:
+      labels: [self-hosted]
+    steps:
+      - run: echo hi
+  guarded:
+    if: ${{ github.repository_owner == vars.SELF_HOSTED_OWNER && github.event.pull_request.head.repo.fork == false }}
+    runs-on: self-hosted
+    steps:
+      - run: echo hi
+`)
+	var messages []string
+	for _, f := range findings {
+		if f.rule == "self-hosted-without-fork-guard" {
+			messages = append(messages, f.message)
+		}
+	}
+	joined := strings.Join(messages, "\n")
+	if len(messages) != 2 ||
+		!strings.Contains(joined, "unguarded missing owner/fork guard") ||
+		!strings.Contains(joined, "owner-only missing fork guard") {
+		t.Fatalf("unexpected guard findings: %v", findings)
+	}
+}
+
+func TestEvaluateWorkflowPermissionsRules(t *testing.T) {
+	rules := findingRules(evaluateWorkflow("wf.yml", `on: push
+jobs:
+  a:
+    runs-on: ubuntu-latest
+    permissions: write-all
+    steps:
+      - run: echo hi
+`))
+	if rules["missing-permissions"] != 1 || rules["permissions-write-all"] != 1 {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+}
+
+func TestEvaluateWorkflowEventExpressionInRun(t *testing.T) {
+	findings := evaluateWorkflow("wf.yml", `on: push
+permissions:
+  contents: read
+jobs:
+  a:
+    runs-on: ubuntu-latest
+    steps:
+      - env:
+          TITLE: ${{ github.event.head_commit.message }}
+        run: |
+          echo "$TITLE"
+          echo "${{ github.event.head_commit.message }}"
+`)
+	if len(findings) != 1 || findings[0].rule != "event-expression-in-run" || findings[0].line != 12 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+}
+
+func TestEvaluateWorkflowSecretsInPRJob(t *testing.T) {
+	findings := evaluateWorkflow("wf.yml", `on: pull_request
+permissions:
+  contents: read
+jobs:
+  leaky:
+    runs-on: ubuntu-latest
+    env:
+      TOKEN: ${{ secrets.DEPLOY_TOKEN }}
+    steps:
+      - run: echo ok
+        env:
+          GH: ${{ secrets.GITHUB_TOKEN }}
+  guarded:
+    if: ${{ github.event.pull_request.head.repo.fork == false }}
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo ok
+        env:
+          TOKEN: ${{ secrets.DEPLOY_TOKEN }}
+`)
+	if len(findings) != 1 || findings[0].rule != "secrets-in-pr-job" || findings[0].line != 8 {
+		t.Fatalf("unexpected findings: %v", findings)
+	}
+	if !strings.Contains(findings[0].String(), "wf.yml:8 secrets-in-pr-job: secrets.DEPLOY_TOKEN") {
+		t.Fatalf("unexpected finding format: %s", findings[0])
+	}
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This is a deliberately small YAML reader for GitHub workflow files. It
+// covers block/flow mappings and sequences, quoted, plain and block scalars,
+// and keeps the source line of every node so policy findings can point at
+// it. Anchors, tags and merge keys are tolerated but not resolved.
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMapping
+	yamlSequence
+)
+
+type yamlNode struct {
+	kind  yamlKind
+	line  int
+	value string
+	// keys holds mapping keys; items holds mapping values (parallel to keys)
+	// or sequence entries.
+	keys  []*yamlNode
+	items []*yamlNode
+}
+
+type yamlError struct {
+	line int
+	msg  string
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// get returns the value for key in a mapping node, or nil.
+func (n *yamlNode) get(key string) *yamlNode {
+	if n == nil || n.kind != yamlMapping {
+		return nil
+	}
+	for i, k := range n.keys {
+		if k.value == key {
+			return n.items[i]
+		}
+	}
+	return nil
+}
+
+// scalarValues flattens a scalar or a sequence of scalars.
+func (n *yamlNode) scalarValues() []*yamlNode {
+	if n == nil {
+		return nil
+	}
+	switch n.kind {
+	case yamlScalar:
+		return []*yamlNode{n}
+	case yamlSequence:
+		var out []*yamlNode
+		for _, item := range n.items {
+			if item.kind == yamlScalar {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// walkScalars visits every scalar value (not mapping keys) below n.
+func walkScalars(n *yamlNode, fn func(*yamlNode)) {
+	if n == nil {
+		return
+	}
+	if n.kind == yamlScalar {
+		fn(n)
+		return
+	}
+	for _, item := range n.items {
+		walkScalars(item, fn)
+	}
+}
+
+func parseYAML(content string) (*yamlNode, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	p := &yamlParser{lines: strings.Split(content, "\n")}
+	root, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, &yamlError{line: p.pos + 1, msg: "unexpected indentation"}
+	}
+	if root == nil {
+		root = &yamlNode{kind: yamlMapping, line: 1}
+	}
+	return root, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isBlankYAMLLine(line string) bool {
+	trim := strings.TrimSpace(line)
+	return trim == "" || strings.HasPrefix(trim, "#") || trim == "---" || trim == "..."
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && isBlankYAMLLine(p.lines[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseBlock parses the node starting at the next content line if it is
+// indented at least minIndent. It returns nil when there is no such node.
+func (p *yamlParser) parseBlock(minIndent int) (*yamlNode, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	line := p.lines[p.pos]
+	if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+		return nil, &yamlError{line: p.pos + 1, msg: "tab indentation"}
+	}
+	indent := lineIndent(line)
+	if indent < minIndent {
+		return nil, nil
+	}
+	text := stripYAMLComment(strings.TrimSpace(line))
+	if isSequenceEntry(text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(text); ok {
+		return p.parseMapping(indent)
+	}
+	return p.parseValue(indent-1, text)
+}
+
+func isSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSequence, line: p.pos + 1}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		text := stripYAMLComment(strings.TrimSpace(line))
+		if lineIndent(line) != indent || !isSequenceEntry(text) {
+			return node, nil
+		}
+		rest := strings.TrimLeft(line[indent+1:], " ")
+		if stripYAMLComment(rest) == "" {
+			itemLine := p.pos + 1
+			p.pos++
+			item, err := p.parseBlock(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			if item == nil {
+				item = &yamlNode{kind: yamlScalar, line: itemLine}
+			}
+			node.items = append(node.items, item)
+			continue
+		}
+		// Re-read "- key: value" as "  key: value" so compact nested
+		// mappings and sequences continue at the entry's column.
+		column := len(line) - len(rest)
+		p.lines[p.pos] = strings.Repeat(" ", column) + rest
+		item, err := p.parseBlock(indent + 1)
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMapping, line: p.pos + 1}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		if lineIndent(line) != indent {
+			if lineIndent(line) > indent {
+				return nil, &yamlError{line: p.pos + 1, msg: "unexpected indentation"}
+			}
+			return node, nil
+		}
+		text := stripYAMLComment(strings.TrimSpace(line))
+		if isSequenceEntry(text) {
+			return node, nil
+		}
+		rawKey, rest, ok := splitYAMLKey(text)
+		if !ok {
+			return nil, &yamlError{line: p.pos + 1, msg: "expected mapping key"}
+		}
+		key := &yamlNode{kind: yamlScalar, line: p.pos + 1, value: unquoteYAMLScalar(rawKey)}
+		value, err := p.parseMappingValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		node.keys = append(node.keys, key)
+		node.items = append(node.items, value)
+	}
+}
+
+// parseMappingValue parses what follows "key:" on the current line.
+func (p *yamlParser) parseMappingValue(indent int, rest string) (*yamlNode, error) {
+	keyLine := p.pos + 1
+	rest = stripYAMLProperties(rest)
+	if rest != "" {
+		return p.parseValue(indent, rest)
+	}
+	p.pos++
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		// A block sequence may sit at the same indentation as its key.
+		if lineIndent(next) == indent && isSequenceEntry(stripYAMLComment(strings.TrimSpace(next))) {
+			return p.parseSequence(indent)
+		}
+	}
+	value, err := p.parseBlock(indent + 1)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = &yamlNode{kind: yamlScalar, line: keyLine}
+	}
+	return value, nil
+}
+
+// parseValue parses an inline value that starts on the current line. Any
+// continuation lines must be indented deeper than parentIndent.
+func (p *yamlParser) parseValue(parentIndent int, text string) (*yamlNode, error) {
+	startLine := p.pos + 1
+	switch {
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		p.pos++
+		return p.parseBlockScalar(parentIndent, text, startLine)
+	case strings.HasPrefix(text, "{") || strings.HasPrefix(text, "["):
+		return p.parseFlow(parentIndent, text, startLine)
+	case strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'"):
+		return p.parseQuoted(parentIndent, text, startLine)
+	}
+
+	parts := []string{text}
+	p.pos++
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			p.pos++
+			continue
+		}
+		if lineIndent(line) <= parentIndent || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			break
+		}
+		parts = append(parts, stripYAMLComment(strings.TrimSpace(line)))
+		p.pos++
+	}
+	return &yamlNode{kind: yamlScalar, line: startLine, value: strings.Join(parts, " ")}, nil
+}
+
+func (p *yamlParser) parseQuoted(parentIndent int, text string, startLine int) (*yamlNode, error) {
+	joined := text
+	p.pos++
+	for !closesYAMLQuote(joined) {
+		if p.pos >= len(p.lines) || (strings.TrimSpace(p.lines[p.pos]) != "" && lineIndent(p.lines[p.pos]) <= parentIndent) {
+			return nil, &yamlError{line: startLine, msg: "unterminated quoted scalar"}
+		}
+		joined += " " + strings.TrimSpace(p.lines[p.pos])
+		p.pos++
+	}
+	return &yamlNode{kind: yamlScalar, line: startLine, value: unquoteYAMLScalar(stripYAMLComment(joined))}, nil
+}
+
+// closesYAMLQuote reports whether the quoted scalar at the start of text is
+// terminated.
+func closesYAMLQuote(text string) bool {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return true
+		}
+	}
+	return false
+}
+
+func (p *yamlParser) parseBlockScalar(parentIndent int, header string, startLine int) (*yamlNode, error) {
+	folded := strings.HasPrefix(header, ">")
+	chomp := ""
+	if strings.Contains(header, "-") {
+		chomp = "-"
+	} else if strings.Contains(header, "+") {
+		chomp = "+"
+	}
+
+	blockIndent := -1
+	var lines []string
+	contentLine := startLine + 1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		indent := lineIndent(line)
+		if indent <= parentIndent || (blockIndent >= 0 && indent < blockIndent) {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+			contentLine = p.pos + 1
+			// Leading blank lines belong to the scalar but not to its start line.
+			lines = lines[:0]
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+
+	// Trailing blank lines are only kept with the "+" chomping indicator.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	// Blank lines consumed past the scalar may precede the next key.
+	if chomp != "+" {
+		trailing = 0
+	}
+
+	var value string
+	if folded {
+		value = foldYAMLLines(lines)
+	} else {
+		value = strings.Join(lines, "\n")
+	}
+	switch chomp {
+	case "-":
+	case "+":
+		value += "\n" + strings.Repeat("\n", trailing)
+	default:
+		if value != "" {
+			value += "\n"
+		}
+	}
+	return &yamlNode{kind: yamlScalar, line: contentLine, value: value}, nil
+}
+
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case line == "" || prev == "":
+				b.WriteString("\n")
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// parseFlow gathers a flow collection that may span several lines and parses
+// it with line tracking.
+func (p *yamlParser) parseFlow(parentIndent int, text string, startLine int) (*yamlNode, error) {
+	src := text
+	p.pos++
+	for !flowBalanced(src) {
+		if p.pos >= len(p.lines) || (strings.TrimSpace(p.lines[p.pos]) != "" && lineIndent(p.lines[p.pos]) <= parentIndent) {
+			return nil, &yamlError{line: startLine, msg: "unterminated flow collection"}
+		}
+		src += "\n" + stripYAMLComment(strings.TrimSpace(p.lines[p.pos]))
+		p.pos++
+	}
+	fp := &yamlFlowParser{src: src, line: startLine}
+	node, err := fp.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	fp.skipSpace()
+	if fp.i < len(fp.src) {
+		return nil, &yamlError{line: fp.line, msg: "unexpected text after flow collection"}
+	}
+	return node, nil
+}
+
+func flowBalanced(src string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if quote != 0 {
+			if quote == '"' && c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth <= 0 && quote == 0
+}
+
+type yamlFlowParser struct {
+	src  string
+	i    int
+	line int
+}
+
+func (f *yamlFlowParser) skipSpace() {
+	for f.i < len(f.src) {
+		switch f.src[f.i] {
+		case '\n':
+			f.line++
+		case ' ', '\t':
+		default:
+			return
+		}
+		f.i++
+	}
+}
+
+func (f *yamlFlowParser) parseValue() (*yamlNode, error) {
+	f.skipSpace()
+	if f.i >= len(f.src) {
+		return &yamlNode{kind: yamlScalar, line: f.line}, nil
+	}
+	switch f.src[f.i] {
+	case '{':
+		return f.parseMapping()
+	case '[':
+		return f.parseSequence()
+	}
+	return f.parseScalar()
+}
+
+func (f *yamlFlowParser) parseMapping() (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMapping, line: f.line}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i >= len(f.src) {
+			return nil, &yamlError{line: f.line, msg: "unterminated flow mapping"}
+		}
+		if f.src[f.i] == '}' {
+			f.i++
+			return node, nil
+		}
+		key, err := f.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		value := &yamlNode{kind: yamlScalar, line: key.line}
+		if f.i < len(f.src) && f.src[f.i] == ':' {
+			f.i++
+			value, err = f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+		}
+		node.keys = append(node.keys, key)
+		node.items = append(node.items, value)
+		if f.i < len(f.src) && f.src[f.i] == ',' {
+			f.i++
+			continue
+		}
+		if f.i < len(f.src) && f.src[f.i] == '}' {
+			continue
+		}
+		return nil, &yamlError{line: f.line, msg: "expected , or } in flow mapping"}
+	}
+}
+
+func (f *yamlFlowParser) parseSequence() (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSequence, line: f.line}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i >= len(f.src) {
+			return nil, &yamlError{line: f.line, msg: "unterminated flow sequence"}
+		}
+		if f.src[f.i] == ']' {
+			f.i++
+			return node, nil
+		}
+		item, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+		f.skipSpace()
+		if f.i < len(f.src) && f.src[f.i] == ',' {
+			f.i++
+			continue
+		}
+		if f.i < len(f.src) && f.src[f.i] == ']' {
+			continue
+		}
+		return nil, &yamlError{line: f.line, msg: "expected , or ] in flow sequence"}
+	}
+}
+
+// parseScalar reads a quoted or plain flow scalar. Plain scalars stop at
+// ",", "]", "}" or ": ", except inside a ${{ }} expression.
+func (f *yamlFlowParser) parseScalar() (*yamlNode, error) {
+	line := f.line
+	start := f.i
+	if c := f.src[f.i]; c == '"' || c == '\'' {
+		f.i++
+		for f.i < len(f.src) {
+			switch {
+			case c == '"' && f.src[f.i] == '\\':
+				f.i++
+			case c == '\'' && f.src[f.i] == '\'' && f.i+1 < len(f.src) && f.src[f.i+1] == '\'':
+				f.i++
+			case f.src[f.i] == c:
+				f.i++
+				raw := strings.ReplaceAll(f.src[start:f.i], "\n", " ")
+				f.line += strings.Count(f.src[start:f.i], "\n")
+				return &yamlNode{kind: yamlScalar, line: line, value: unquoteYAMLScalar(raw)}, nil
+			}
+			f.i++
+		}
+		return nil, &yamlError{line: line, msg: "unterminated quoted scalar"}
+	}
+	for f.i < len(f.src) {
+		if strings.HasPrefix(f.src[f.i:], "${{") {
+			end := strings.Index(f.src[f.i:], "}}")
+			if end < 0 {
+				return nil, &yamlError{line: f.line, msg: "unterminated expression"}
+			}
+			f.line += strings.Count(f.src[f.i:f.i+end], "\n")
+			f.i += end + 2
+			continue
+		}
+		c := f.src[f.i]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if c == ':' && (f.i+1 >= len(f.src) || strings.ContainsRune(" \n,]}", rune(f.src[f.i+1]))) {
+			break
+		}
+		if c == '\n' {
+			f.line++
+		}
+		f.i++
+	}
+	raw := strings.Join(strings.Fields(f.src[start:f.i]), " ")
+	return &yamlNode{kind: yamlScalar, line: line, value: stripYAMLProperties(raw)}, nil
+}
+
+// splitYAMLKey splits "key: rest" at the first ": " (or trailing ":") that is
+// outside quotes and flow brackets.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" || strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	var quote byte
+	if text[0] == '"' || text[0] == '\'' {
+		quote = text[0]
+	}
+	for i := 1; i < len(text); i++ {
+		if quote != 0 {
+			if quote == '"' && text[i] == '\\' {
+				i++
+			} else if text[i] == quote {
+				quote = 0
+			}
+			continue
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a trailing " # comment" outside quoted tokens.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if quote != 0 {
+			if quote == '"' && c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:", rune(text[i-1])) {
+				quote = c
+			}
+		case '#':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+				return strings.TrimSpace(text[:i])
+			}
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// stripYAMLProperties drops leading anchors (&name) and tags (!tag).
+func stripYAMLProperties(text string) string {
+	for strings.HasPrefix(text, "&") || strings.HasPrefix(text, "!") {
+		_, rest, found := strings.Cut(text, " ")
+		if !found {
+			return ""
+		}
+		text = strings.TrimSpace(rest)
+	}
+	return text
+}
+
+func unquoteYAMLScalar(text string) string {
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	}
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		inner := text[1 : len(text)-1]
+		replacer := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\t`, "\t", `\/`, "/")
+		return replacer.Replace(inner)
+	}
+	return text
+}
@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestParseYAMLFlowMappingAndBlockScalar(t *testing.T) {
+	root, err := parseYAML(`name: verify
+on:
+  push:
+    branches: ["main", 'codex/**']
+jobs:
+  build:
+    steps:
+      - { name: checkout, uses: "actions/checkout@v4" }
+      - name: test
+        run: |
+          go test ./...
+          echo done # not a comment
+      - run: >-
+          folded
+          text
+`)
+	if err != nil {
+		t.Fatalf("parseYAML returned error: %v", err)
+	}
+	branches := root.get("on").get("push").get("branches")
+	if branches == nil || len(branches.items) != 2 || branches.items[1].value != "codex/**" {
+		t.Fatalf("unexpected branches: %+v", branches)
+	}
+
+	steps := root.get("jobs").get("build").get("steps")
+	if steps == nil || len(steps.items) != 3 {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+	uses := steps.items[0].get("uses")
+	if uses == nil || uses.value != "actions/checkout@v4" || uses.line != 8 {
+		t.Fatalf("unexpected flow uses: %+v", uses)
+	}
+	run := steps.items[1].get("run")
+	if run.value != "go test ./...\necho done # not a comment\n" || run.line != 11 {
+		t.Fatalf("unexpected literal run: %q line=%d", run.value, run.line)
+	}
+	if folded := steps.items[2].get("run"); folded.value != "folded text" {
+		t.Fatalf("unexpected folded run: %q", folded.value)
+	}
+}
+
+func TestParseYAMLMultiLineFlowAndComments(t *testing.T) {
+	root, err := parseYAML(`"on": [push,
+  pull_request_target]  # trailing comment
+permissions: read-all
+`)
+	if err != nil {
+		t.Fatalf("parseYAML returned error: %v", err)
+	}
+	on := root.get("on")
+	if on == nil || on.kind != yamlSequence || len(on.items) != 2 {
+		t.Fatalf("unexpected on: %+v", on)
+	}
+	if on.items[1].value != "pull_request_target" || on.items[1].line != 2 {
+		t.Fatalf("unexpected second trigger: %+v", on.items[1])
+	}
+	if root.get("permissions").value != "read-all" {
+		t.Fatalf("unexpected permissions: %+v", root.get("permissions"))
+	}
+}
+
+func TestParseYAMLRejectsBadIndentation(t *testing.T) {
+	_, err := parseYAML("jobs:\n  a:\n    x: 1\n   y: 2\n")
+	if err == nil {
+		t.Fatal("expected indentation error")
+	}
+}
@@ -30,15 +30,20 @@ func main() {
 	statusPath := filepath.Join(outDir, "verify-full.status")
 	_ = os.Remove(statusPath)
 
-	// Build the command
-	args := []string{"ops/ci/run_verify_full.sh"}
+	// Build the command: the Engine API runner, or the shell script with
+	// VERIFY_FULL_RUNNER=sh
+	name, args := "go", []string{"run", "./cmd/verify-full-docker"}
+	if envOr("VERIFY_FULL_RUNNER", "engine") == "sh" {
+		name, args = "sh", []string{"ops/ci/run_verify_full.sh"}
+	}
+	fmt.Printf("OK: runner=%s\n", strings.Join(append([]string{name}, args...), " "))
 	env := os.Environ()
 	if dryRun {
 		env = appendEnv(env, "VERIFY_DRY_RUN", "1")
 	}
 	env = appendEnv(env, "VERIFY_GHA_SYNC", envOr("VERIFY_GHA_SYNC", "0"))
 
-	cmd := exec.Command("sh", args...)
+	cmd := exec.Command(name, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Env = env
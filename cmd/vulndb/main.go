@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of the Go vulnerability database (https://go.dev/security/vuln/database):
+//
+//	index/db.json       {"modified": "..."}
+//	index/modules.json  [{"path": "...", "vulns": [{"id": "...", "modified": "...", "fixed": "..."}]}]
+//	ID/<id>.json        one OSV entry
+//
+// sync mirrors it into a local directory so verify-lite can check offline.
+
+type options struct {
+	dir     string
+	url     string
+	force   bool
+	workers int
+}
+
+type dbIndex struct {
+	Modified string `json:"modified"`
+}
+
+type moduleIndex struct {
+	Path  string      `json:"path"`
+	Vulns []vulnIndex `json:"vulns"`
+}
+
+type vulnIndex struct {
+	ID       string `json:"id"`
+	Modified string `json:"modified"`
+	Fixed    string `json:"fixed,omitempty"`
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		printUsage()
+		return
+	}
+	if os.Args[1] != "sync" {
+		printUsage()
+		fmt.Printf("ERROR: vulndb unknown_command=%s\n", os.Args[1])
+		fmt.Println("STATUS: ERROR")
+		os.Exit(2)
+	}
+
+	opts, err := parseOptions(os.Args[2:], os.Getenv)
+	if err != nil {
+		printUsage()
+		fmt.Printf("ERROR: vulndb invalid_args=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(2)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	if err := runSync(opts, client, os.Stdout); err != nil {
+		fmt.Printf("ERROR: vulndb sync err=%s\n", err.Error())
+		fmt.Println("STATUS: ERROR")
+		os.Exit(1)
+	}
+	fmt.Println("STATUS: OK")
+}
+
+func printUsage() {
+	fmt.Println("Usage: ci-self vulndb sync [--dir path] [--url base] [--force] [--workers N]")
+	fmt.Println()
+	fmt.Println("Mirrors the Go vulnerability database (OSV JSON) into a local directory for offline verify-lite checks.")
+	fmt.Println("Defaults: --dir $CI_SELF_VULNDB_DIR or ~/.cache/ci-self/vulndb, --url $CI_SELF_VULNDB_URL or https://vuln.go.dev")
+}
+
+// defaultDir mirrors vulnDBDir in cmd/verify-lite.
+func defaultDir(getenv func(string) string) string {
+	if dir := strings.TrimSpace(getenv("CI_SELF_VULNDB_DIR")); dir != "" {
+		return dir
+	}
+	cacheHome := strings.TrimSpace(getenv("XDG_CACHE_HOME"))
+	if cacheHome == "" {
+		cacheHome = filepath.Join(getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheHome, "ci-self", "vulndb")
+}
+
+func parseOptions(args []string, getenv func(string) string) (options, error) {
+	urlDefault := strings.TrimSpace(getenv("CI_SELF_VULNDB_URL"))
+	if urlDefault == "" {
+		urlDefault = "https://vuln.go.dev"
+	}
+	fs := flag.NewFlagSet("vulndb sync", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dir := fs.String("dir", defaultDir(getenv), "local database directory")
+	url := fs.String("url", urlDefault, "database base URL")
+	force := fs.Bool("force", false, "re-download every entry")
+	workers := fs.Int("workers", 8, "parallel downloads")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected_args=%s", strings.Join(fs.Args(), ","))
+	}
+	if *workers < 1 {
+		return options{}, errors.New("--workers must be positive")
+	}
+	return options{dir: *dir, url: strings.TrimRight(*url, "/"), force: *force, workers: *workers}, nil
+}
+
+func fetch(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url) //nolint:gosec // URL is the configured database base.
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: http_status=%d", url, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// writeFileAtomic keeps readers from seeing half-written entries.
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// localModified returns the modified time of every entry in the local index.
+func localModified(dir string) map[string]string {
+	out := map[string]string{}
+	content, err := os.ReadFile(filepath.Join(dir, "index", "modules.json"))
+	if err != nil {
+		return out
+	}
+	var modules []moduleIndex
+	if json.Unmarshal(content, &modules) != nil {
+		return out
+	}
+	for _, m := range modules {
+		for _, v := range m.Vulns {
+			out[v.ID] = v.Modified
+		}
+	}
+	return out
+}
+
+// runSync downloads the index, then only the entries whose modified time
+// changed. index/db.json is written last, so an interrupted sync is retried.
+func runSync(opts options, client *http.Client, out io.Writer) error {
+	fmt.Fprintf(out, "OK: vulndb sync start dir=%s url=%s\n", opts.dir, opts.url)
+
+	dbContent, err := fetch(client, opts.url+"/index/db.json")
+	if err != nil {
+		return err
+	}
+	var remote dbIndex
+	if err := json.Unmarshal(dbContent, &remote); err != nil {
+		return fmt.Errorf("parse index/db.json: %w", err)
+	}
+	if !opts.force {
+		if local, err := os.ReadFile(filepath.Join(opts.dir, "index", "db.json")); err == nil {
+			var current dbIndex
+			if json.Unmarshal(local, &current) == nil && current.Modified == remote.Modified {
+				fmt.Fprintf(out, "OK: vulndb sync up_to_date modified=%s\n", remote.Modified)
+				return nil
+			}
+		}
+	}
+
+	modulesContent, err := fetch(client, opts.url+"/index/modules.json")
+	if err != nil {
+		return err
+	}
+	var modules []moduleIndex
+	if err := json.Unmarshal(modulesContent, &modules); err != nil {
+		return fmt.Errorf("parse index/modules.json: %w", err)
+	}
+
+	previous := map[string]string{}
+	if !opts.force {
+		previous = localModified(opts.dir)
+	}
+	var pending []string
+	seen := map[string]bool{}
+	total := 0
+	for _, m := range modules {
+		for _, v := range m.Vulns {
+			if seen[v.ID] {
+				continue
+			}
+			seen[v.ID] = true
+			total++
+			path := filepath.Join(opts.dir, "ID", v.ID+".json")
+			if _, err := os.Stat(path); err == nil && previous[v.ID] == v.Modified {
+				continue
+			}
+			pending = append(pending, v.ID)
+		}
+	}
+
+	if err := fetchEntries(opts, client, pending); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(opts.dir, "index", "modules.json"), modulesContent); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(opts.dir, "index", "db.json"), dbContent); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "OK: vulndb sync done modified=%s entries=%d fetched=%d\n", remote.Modified, total, len(pending))
+	return nil
+}
+
+func fetchEntries(opts options, client *http.Client, ids []string) error {
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				content, err := fetch(client, opts.url+"/ID/"+id+".json")
+				if err == nil {
+					err = writeFileAtomic(filepath.Join(opts.dir, "ID", id+".json"), content)
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
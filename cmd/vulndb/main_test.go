@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeVulnDB serves the vuln.go.dev layout from files and records requests.
+type fakeVulnDB struct {
+	mu    sync.Mutex
+	files map[string]string
+	calls []string
+}
+
+func (f *fakeVulnDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, r.URL.Path)
+	content, ok := f.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write([]byte(content))
+}
+
+func (f *fakeVulnDB) takeCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+func newFakeVulnDB(modified, entryModified string) *fakeVulnDB {
+	return &fakeVulnDB{files: map[string]string{
+		"/index/db.json": `{"modified":"` + modified + `"}`,
+		"/index/modules.json": `[{"path":"example.com/a","vulns":[{"id":"GO-2099-0001","modified":"2026-01-01T00:00:00Z"}]},` +
+			`{"path":"example.com/b","vulns":[{"id":"GO-2099-0002","modified":"` + entryModified + `"},{"id":"GO-2099-0001","modified":"2026-01-01T00:00:00Z"}]}]`,
+		"/ID/GO-2099-0001.json": `{"id":"GO-2099-0001"}`,
+		"/ID/GO-2099-0002.json": `{"id":"GO-2099-0002","modified":"` + entryModified + `"}`,
+	}}
+}
+
+func TestRunSyncDownloadsOnlyChangedEntries(t *testing.T) {
+	fake := newFakeVulnDB("2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	dir := t.TempDir()
+	opts := options{dir: dir, url: srv.URL, workers: 2}
+
+	var out bytes.Buffer
+	if err := runSync(opts, srv.Client(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "entries=2 fetched=2") {
+		t.Fatalf("out=%s", out.String())
+	}
+	for _, name := range []string{"index/db.json", "index/modules.json", "ID/GO-2099-0001.json", "ID/GO-2099-0002.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+	fake.takeCalls()
+
+	out.Reset()
+	if err := runSync(opts, srv.Client(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.takeCalls(); len(calls) != 1 || !strings.Contains(out.String(), "up_to_date") {
+		t.Fatalf("calls=%v out=%s", calls, out.String())
+	}
+
+	updated := newFakeVulnDB("2026-02-01T00:00:00Z", "2026-02-01T00:00:00Z")
+	fake.mu.Lock()
+	fake.files = updated.files
+	fake.mu.Unlock()
+	out.Reset()
+	if err := runSync(opts, srv.Client(), &out); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.takeCalls()
+	if strings.Join(calls, ",") != "/index/db.json,/index/modules.json,/ID/GO-2099-0002.json" {
+		t.Fatalf("calls=%v", calls)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "ID", "GO-2099-0002.json"))
+	if err != nil || !strings.Contains(string(content), "2026-02-01") {
+		t.Fatalf("entry=%s err=%v", content, err)
+	}
+}
+
+func TestRunSyncKeepsOldIndexWhenEntryFails(t *testing.T) {
+	fake := newFakeVulnDB("2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z")
+	delete(fake.files, "/ID/GO-2099-0002.json")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	err := runSync(options{dir: dir, url: srv.URL, workers: 1}, srv.Client(), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "http_status=404") {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index", "db.json")); !os.IsNotExist(err) {
+		t.Fatalf("index/db.json written after failed sync: %v", err)
+	}
+}
+
+func TestParseOptionsDefaults(t *testing.T) {
+	env := map[string]string{"HOME": "/home/runner"}
+	opts, err := parseOptions(nil, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.dir != "/home/runner/.cache/ci-self/vulndb" || opts.url != "https://vuln.go.dev" {
+		t.Fatalf("opts=%+v", opts)
+	}
+
+	env["CI_SELF_VULNDB_DIR"] = "/srv/vulndb"
+	env["CI_SELF_VULNDB_URL"] = "http://mirror.local/"
+	opts, err = parseOptions(nil, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.dir != "/srv/vulndb" || opts.url != "http://mirror.local" {
+		t.Fatalf("opts=%+v", opts)
+	}
+	if _, err := parseOptions([]string{"extra"}, func(string) string { return "" }); err == nil {
+		t.Fatal("expected error for extra args")
+	}
+}
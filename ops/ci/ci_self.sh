@@ -46,7 +46,7 @@ parse_decimal_index() {
 
 expand_local_path() {
   local p="$1"
-  # shellcheck disable=SC2088
+  # shellcheck disable=SC2088 # literal "~/" prefix match, expansion is done below
   if [[ "$p" == "~/"* ]]; then
     printf '%s\n' "${HOME}/${p#"~/"}"
   else
@@ -155,7 +155,7 @@ load_config() {
     return 0
   fi
 
-  # shellcheck disable=SC2034
+  # shellcheck disable=SC2034 # records the loaded config path; not read in this file
   CONFIG_FILE="$f"
 
   local raw line key val
@@ -199,6 +199,9 @@ Commands:
   focus      run-focus + optional PR auto-create
   doctor     Dependency/runner checks (with optional --fix)
   update     Check runner/dependency updates, optionally upgrade brew-managed tools
+  pin-actions  Pin workflow `uses:` tags to commit SHAs (--check reports moved tags)
+  vulndb       Sync the local Go vulnerability DB for offline verify-lite (vulndb sync)
+  hooks        Install/uninstall/status git hooks running verify-lite (pre-commit: --staged, pre-push: full)
   config-init  Create .ci-self.env template in current project
   mobile-workflow  Scaffold fastlane mobile-build workflow
   register   One-command runner registration for current repo
@@ -219,6 +222,10 @@ Examples:
   ci-self doctor --fix
   ci-self update
   ci-self update --apply
+  ci-self pin-actions
+  ci-self pin-actions --check
+  ci-self vulndb sync
+  ci-self hooks install
   ci-self config-init
   ci-self mobile-workflow --apply
   ci-self register
@@ -333,7 +340,7 @@ list_act_jobs() {
     [[ "$rows_started" -eq 1 ]] || continue
     case "$line" in
       [0-9]*)
-        # shellcheck disable=SC2086
+        # shellcheck disable=SC2086 # intentional word splitting of an act --list row
         set -- $line
         [[ $# -ge 2 ]] && printf '%s\n' "$2"
         ;;
@@ -1337,6 +1344,34 @@ cmd_update() {
   )
 }
 
+cmd_pin_actions() {
+  local project_dir="$PWD"
+  (
+    cd "$ROOT_DIR"
+    run_go_cmd run ./cmd/pin-actions --repo-dir "$project_dir" "$@"
+  )
+}
+
+cmd_vulndb() {
+  (
+    cd "$ROOT_DIR"
+    run_go_cmd run ./cmd/vulndb "$@"
+  )
+}
+
+cmd_hooks() {
+  local project_dir="$PWD"
+  if [[ $# -eq 0 ]]; then
+    set -- help
+  fi
+  local sub="$1"
+  shift
+  (
+    cd "$ROOT_DIR"
+    run_go_cmd run ./cmd/hooks "$sub" --repo-dir "$project_dir" --runner-dir "$ROOT_DIR" "$@"
+  )
+}
+
 cmd_config_init() {
   local path=""
   local force=0
@@ -1470,7 +1505,7 @@ default_remote_project_dir() {
   local name
   root="$(git rev-parse --show-toplevel 2>/dev/null || pwd)"
   name="$(basename "$root")"
-  # shellcheck disable=SC2088
+  # shellcheck disable=SC2088 # literal path shown as a hint
   printf '%s\n' "~/dev/$name"
 }
 
@@ -1512,7 +1547,7 @@ ensure_default_local_dir_matches_repo() {
 
 remote_path_for_shell() {
   local path="$1"
-  # shellcheck disable=SC2088
+  # shellcheck disable=SC2088 # literal "~/" prefix match, expansion is done below
   if [[ "$path" == "~/"* ]]; then
     # Emit a quoted path that expands $HOME on the remote side: cd "$HOME/..."
     printf '%s\n' "\"\$HOME/${path#"~/"}\""
@@ -1556,7 +1591,7 @@ run_remote_verify_wrapper() {
   [[ -n "$identity" ]] && ssh_cmd+=(-i "$identity")
 
   remote_cd_q="$(remote_path_for_shell "$project_dir")"
-  # shellcheck disable=SC2016
+  # shellcheck disable=SC2016 # expanded by the remote shell, not locally
   printf -v remote_script 'set -euo pipefail; cd %s; export REPO_DIR="$PWD" OUT_DIR="$PWD/out" VERIFY_DRY_RUN=%q VERIFY_GHA_SYNC=%q GITHUB_ACTIONS=%q' \
     "$remote_cd_q" "$verify_dry_run" "$verify_gha_sync" "true"
   if [[ -n "$github_sha" ]]; then
@@ -1582,7 +1617,7 @@ probe_remote_verify_artifacts() {
   [[ -n "$identity" ]] && ssh_cmd+=(-i "$identity")
 
   remote_cd_q="$(remote_path_for_shell "$project_dir")"
-  # shellcheck disable=SC2016
+  # shellcheck disable=SC2016 # expanded by the remote shell, not locally
   printf -v remote_script 'set -euo pipefail; cd %s; if [[ -f out/verify-full.status ]]; then echo "OK: remote_artifacts status_file=$PWD/out/verify-full.status"; else echo "WARN: remote_artifacts status_file_missing=$PWD/out/verify-full.status"; fi; if [[ -d out/logs ]]; then echo "OK: remote_artifacts logs_dir=$PWD/out/logs"; else echo "WARN: remote_artifacts logs_dir_missing=$PWD/out/logs"; fi' \
     "$remote_cd_q"
   script_q="$(quote_bash_lc_script "$remote_script")"
@@ -1830,14 +1865,14 @@ run_remote_ci_self() {
 
   remote_args_q="$(quote_words "${remote_args[@]}")"
   remote_cli_q="$(remote_path_for_shell "$remote_cli")"
-  # shellcheck disable=SC2088
+  # shellcheck disable=SC2088 # literal "~/" prefix match, expansion is done below
   if [[ "$project_dir" == "~/"* ]]; then
-    # shellcheck disable=SC2016
+    # shellcheck disable=SC2016 # $HOME is expanded by the remote shell
     printf -v remote_cd_q '$HOME/%s' "${project_dir#"~/"}"
   else
     printf -v remote_cd_q '%q' "$project_dir"
   fi
-  # shellcheck disable=SC2016
+  # shellcheck disable=SC2016 # expanded by the remote shell, not locally
   printf -v remote_script 'set -euo pipefail; remote_cli=%s; if [[ "$remote_cli" != */* ]] && ! command -v "$remote_cli" >/dev/null 2>&1 && [[ -x "$HOME/.local/bin/$remote_cli" ]]; then remote_cli="$HOME/.local/bin/$remote_cli"; fi; cd %s; "$remote_cli" %s' \
     "$remote_cli_q" "$remote_cd_q" "$remote_args_q"
   script_q="$(quote_bash_lc_script "$remote_script")"
@@ -2220,6 +2255,9 @@ main() {
     focus) cmd_focus "$@" ;;
     doctor) cmd_doctor "$@" ;;
     update) cmd_update "$@" ;;
+    pin-actions) cmd_pin_actions "$@" ;;
+    vulndb) cmd_vulndb "$@" ;;
+    hooks) cmd_hooks "$@" ;;
     config-init) cmd_config_init "$@" ;;
     mobile-workflow) cmd_mobile_workflow "$@" ;;
     register) cmd_register "$@" ;;
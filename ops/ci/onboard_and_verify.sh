@@ -55,7 +55,7 @@ ensure_nix_on_path() {
 
   local daemon_profile="/nix/var/nix/profiles/default/etc/profile.d/nix-daemon.sh"
   if [[ -f "$daemon_profile" ]]; then
-    # shellcheck disable=SC1091
+    # shellcheck disable=SC1091 # runtime-only file, not available to shellcheck
     . "$daemon_profile"
   fi
 
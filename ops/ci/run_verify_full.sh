@@ -13,9 +13,19 @@ GITHUB_ACTIONS="${GITHUB_ACTIONS:-false}"
 GITHUB_RUN_ID="${GITHUB_RUN_ID:-}"
 GITHUB_SHA="${GITHUB_SHA:-}"
 GITHUB_REF_NAME="${GITHUB_REF_NAME:-}"
+GITHUB_SERVER_URL="${GITHUB_SERVER_URL:-}"
+GITHUB_REPOSITORY="${GITHUB_REPOSITORY:-}"
+GITHUB_STEP_SUMMARY="${GITHUB_STEP_SUMMARY:-}"
+GITHUB_OUTPUT="${GITHUB_OUTPUT:-}"
 HOST_UID="${HOST_UID:-$(id -u)}"
 HOST_GID="${HOST_GID:-$(id -g)}"
+VERIFY_SHARDS="${VERIFY_SHARDS:-1}"
+VERIFY_ISOLATION="${VERIFY_ISOLATION:-standard}"
+VERIFY_TMPFS_SIZE="${VERIFY_TMPFS_SIZE:-2g}"
 STATUS_PATH="${OUT_DIR}/verify-full.status"
+SHARD_TIMINGS="${OUT_DIR}/metrics/verify-full-shard-timings.json"
+FETCH_DIR="${OUT_DIR}/fetch"
+FETCH_STATUS_PATH="${FETCH_DIR}/verify-full-fetch.status"
 DOCKER_READY_REASON="docker_daemon_unavailable"
 
 mkdir -p "${OUT_DIR}"
@@ -60,6 +70,7 @@ write_error_status() {
       echo "OK: github_ref=${GITHUB_REF_NAME}"
       echo "github_ref=${GITHUB_REF_NAME}"
     fi
+    echo "isolation=${VERIFY_ISOLATION}"
     echo "source=run_verify_full"
     echo "ERROR: reason=${reason}"
     echo "reason=${reason}"
@@ -70,8 +81,8 @@ write_dry_run_ok_status() {
   stamp="$(date -u '+%Y%m%dT%H%M%SZ')"
   mode="$(verify_mode)"
   gha_sync="$(gha_sync_value)"
-  log_dir="${OUT_DIR}/logs"
-  log_path="${log_dir}/verify-full-${stamp}.log"
+  log_dir="${OUT_DIR}/logs/verify-full/${stamp}"
+  log_path="${log_dir}/verify-full.log"
 
   mkdir -p "${log_dir}"
   {
@@ -92,6 +103,7 @@ write_dry_run_ok_status() {
       echo "OK: github_ref=${GITHUB_REF_NAME}"
       echo "github_ref=${GITHUB_REF_NAME}"
     fi
+    echo "isolation=${VERIFY_ISOLATION}"
     echo "source=run_verify_full"
   } >"${STATUS_PATH}"
 
@@ -143,26 +155,128 @@ if [ "${VERIFY_DRY_RUN}" = "1" ]; then
   exit 0
 fi
 
+case "${VERIFY_ISOLATION}" in
+  standard | hermetic) ;;
+  *)
+    echo "ERROR: VERIFY_ISOLATION must be standard or hermetic: ${VERIFY_ISOLATION}" >&2
+    write_error_status "invalid_verify_isolation"
+    exit 1
+    ;;
+esac
+
+case "${VERIFY_SHARDS}" in
+  "" | *[!0-9]* | 0*)
+    echo "ERROR: VERIFY_SHARDS must be a positive integer: ${VERIFY_SHARDS}" >&2
+    write_error_status "invalid_verify_shards"
+    exit 1
+    ;;
+esac
+
 if ! ensure_docker_ready; then
   write_error_status "${DOCKER_READY_REASON}"
   exit 1
 fi
 
-docker run --rm \
-  --user "${HOST_UID}:${HOST_GID}" \
-  -e VERIFY_DRY_RUN="${VERIFY_DRY_RUN}" \
-  -e VERIFY_GHA_SYNC="${VERIFY_GHA_SYNC}" \
-  -e GITHUB_ACTIONS="${GITHUB_ACTIONS}" \
-  -e GITHUB_RUN_ID="${GITHUB_RUN_ID}" \
-  -e GITHUB_SHA="${GITHUB_SHA}" \
-  -e GITHUB_REF_NAME="${GITHUB_REF_NAME}" \
-  -v "${REPO_DIR}:/repo" \
-  -v "${OUT_DIR}:/out" \
-  -v "${CACHE_VOL}:/cache" \
-  -w /repo \
-  "${IMAGE}" \
-  /usr/local/bin/verify-full
-rc="$?"
+# run_container が起動するコンテナの隔離レベル（fetch phase だけ standard にする）
+CONTAINER_ISOLATION="${VERIFY_ISOLATION}"
+
+# verify-full コンテナを1つ起動する
+#   $1: /out に mount するディレクトリ
+#   $2: shard（<index>/<total>。空なら shard なし）
+#   $3: 1 なら job summary / step output のファイルを mount する
+#   残り: verify-full の引数
+run_container() {
+  container_out="$1"
+  container_shard="$2"
+  container_gha="$3"
+  shift 3
+  set -- "${IMAGE}" /usr/local/bin/verify-full "$@"
+  # job summary / step output はホストのファイルを container 内の固定パスへ mount する
+  if [ "${container_gha}" = "1" ]; then
+    if [ -n "${GITHUB_OUTPUT}" ] && [ -f "${GITHUB_OUTPUT}" ]; then
+      set -- -v "${GITHUB_OUTPUT}:/gha/output" -e GITHUB_OUTPUT=/gha/output "$@"
+    fi
+    if [ -n "${GITHUB_STEP_SUMMARY}" ] && [ -f "${GITHUB_STEP_SUMMARY}" ]; then
+      set -- -v "${GITHUB_STEP_SUMMARY}:/gha/step_summary" -e GITHUB_STEP_SUMMARY=/gha/step_summary "$@"
+    fi
+  fi
+  # shard は前回までの package 所要時間（merge 済み）を読んで同じ分割を計算する
+  if [ -n "${container_shard}" ]; then
+    set -- -e VERIFY_FULL_SHARD="${container_shard}" "$@"
+    if [ -f "${SHARD_TIMINGS}" ]; then
+      set -- -v "${SHARD_TIMINGS}:/shard-timings.json:ro" -e VERIFY_FULL_SHARD_TIMINGS=/shard-timings.json "$@"
+    fi
+    # shard の /out は out/shards/<i> なので、fetch phase の結果は読み取り専用で渡す
+    if [ -d "${FETCH_DIR}" ]; then
+      set -- -v "${FETCH_DIR}:/fetch:ro" -e VERIFY_FULL_FETCH_DIR=/fetch "$@"
+    fi
+  fi
+
+  # hermetic: network なし・read-only root・capability なし・tmpfs の /tmp
+  if [ "${CONTAINER_ISOLATION}" = "hermetic" ]; then
+    set -- --network none --read-only --cap-drop ALL --security-opt no-new-privileges \
+      --tmpfs "/tmp:rw,exec,nosuid,nodev,size=${VERIFY_TMPFS_SIZE}" \
+      -e HOME=/tmp -e GOPROXY=off "$@"
+  fi
+
+  docker run --rm \
+    --user "${HOST_UID}:${HOST_GID}" \
+    -e VERIFY_DRY_RUN="${VERIFY_DRY_RUN}" \
+    -e VERIFY_GHA_SYNC="${VERIFY_GHA_SYNC}" \
+    -e GITHUB_ACTIONS="${GITHUB_ACTIONS}" \
+    -e GITHUB_RUN_ID="${GITHUB_RUN_ID}" \
+    -e GITHUB_SHA="${GITHUB_SHA}" \
+    -e GITHUB_REF_NAME="${GITHUB_REF_NAME}" \
+    -e GITHUB_SERVER_URL="${GITHUB_SERVER_URL}" \
+    -e GITHUB_REPOSITORY="${GITHUB_REPOSITORY}" \
+    -e VERIFY_ISOLATION="${CONTAINER_ISOLATION}" \
+    -v "${REPO_DIR}:/repo" \
+    -v "${container_out}:/out" \
+    -v "${CACHE_VOL}:/cache" \
+    -w /repo \
+    "$@"
+}
+
+# hermetic では先に network ありのコンテナで依存を /cache へ取得する
+if [ "${VERIFY_ISOLATION}" = "hermetic" ]; then
+  rm -rf "${FETCH_DIR}"
+  CONTAINER_ISOLATION="standard"
+  run_container "${OUT_DIR}" "" 0 --fetch
+  CONTAINER_ISOLATION="${VERIFY_ISOLATION}"
+  if ! grep -qx "status=OK" "${FETCH_STATUS_PATH}" 2>/dev/null; then
+    echo "ERROR: fetch phase failed status=${FETCH_STATUS_PATH}" >&2
+    write_error_status "fetch_failed"
+    exit 1
+  fi
+  echo "OK: fetch phase completed"
+fi
+
+if [ "${VERIFY_SHARDS}" -eq 1 ]; then
+  run_container "${OUT_DIR}" "" 1
+  rc="$?"
+else
+  # shard ごとに out/shards/<i> を /out にしたコンテナを並列に起動し、最後に merge する
+  pids=""
+  i=0
+  while [ "${i}" -lt "${VERIFY_SHARDS}" ]; do
+    shard_out="${OUT_DIR}/shards/${i}"
+    mkdir -p "${shard_out}"
+    rm -f "${shard_out}/verify-full.status" "${shard_out}/metrics/verify-full-shard-timings.json"
+    run_container "${shard_out}" "${i}/${VERIFY_SHARDS}" 0 >"${shard_out}/console.log" 2>&1 &
+    pids="${pids} $!"
+    echo "OK: shard=${i}/${VERIFY_SHARDS} started console=${shard_out}/console.log"
+    i=$((i + 1))
+  done
+  i=0
+  for pid in ${pids}; do
+    shard_rc=0
+    wait "${pid}" || shard_rc="$?"
+    echo "OK: shard=${i}/${VERIFY_SHARDS} exit_code=${shard_rc}"
+    i=$((i + 1))
+  done
+  run_container "${OUT_DIR}" "" 1 --merge-shards "${VERIFY_SHARDS}"
+  rc="$?"
+fi
 
 if [ "${rc}" -ne 0 ] && [ ! -f "${STATUS_PATH}" ]; then
   write_error_status "docker_run_failed"
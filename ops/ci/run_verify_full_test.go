@@ -73,7 +73,7 @@ exit 99
 		}
 	}
 
-	logs, globErr := filepath.Glob(filepath.Join(outDir, "logs", "verify-full-*.log"))
+	logs, globErr := filepath.Glob(filepath.Join(outDir, "logs", "verify-full", "*", "verify-full.log"))
 	if globErr != nil || len(logs) != 1 {
 		t.Fatalf("expected one dry-run log file, got %v err=%v\noutput:\n%s", logs, globErr, out)
 	}
@@ -227,3 +227,257 @@ esac
 		}
 	}
 }
+
+func TestRunVerifyFullMountsGitHubSummaryFiles(t *testing.T) {
+	binDir := t.TempDir()
+	stateDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+	dockerLog := filepath.Join(stateDir, "docker-run.log")
+	summaryPath := filepath.Join(stateDir, "step_summary")
+	outputPath := filepath.Join(stateDir, "output")
+	for _, path := range []string{summaryPath, outputPath} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFakeCommand(t, binDir, "docker", `#!/usr/bin/env sh
+case "$1" in
+  info)
+    exit 0
+    ;;
+  run)
+    printf '%s\n' "$*" > "$TEST_DOCKER_LOG"
+    mkdir -p "$(dirname "$TEST_STATUS_PATH")"
+    echo "status=OK" > "$TEST_STATUS_PATH"
+    ;;
+esac
+`)
+
+	out, err := runVerifyFullWithEnv(t, []string{
+		"PATH=" + binDir + ":/usr/bin:/bin",
+		"OUT_DIR=" + outDir,
+		"TEST_DOCKER_LOG=" + dockerLog,
+		"TEST_STATUS_PATH=" + filepath.Join(outDir, "verify-full.status"),
+		"GITHUB_STEP_SUMMARY=" + summaryPath,
+		"GITHUB_OUTPUT=" + outputPath,
+		"GITHUB_SERVER_URL=https://github.com",
+		"GITHUB_REPOSITORY=owner/repo",
+	})
+	if err != nil {
+		t.Fatalf("expected docker run to succeed: %v\noutput:\n%s", err, out)
+	}
+	body, readErr := os.ReadFile(dockerLog)
+	if readErr != nil {
+		t.Fatalf("expected docker run log: %v\noutput:\n%s", readErr, out)
+	}
+	args := string(body)
+	for _, want := range []string{
+		"-v " + summaryPath + ":/gha/step_summary -e GITHUB_STEP_SUMMARY=/gha/step_summary",
+		"-v " + outputPath + ":/gha/output -e GITHUB_OUTPUT=/gha/output",
+		"-e GITHUB_REPOSITORY=owner/repo",
+	} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("docker args missing %q\nargs:\n%s", want, args)
+		}
+	}
+}
+
+func TestRunVerifyFullShardsAndMerges(t *testing.T) {
+	binDir := t.TempDir()
+	stateDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+	dockerLog := filepath.Join(stateDir, "docker-run.log")
+	summaryPath := filepath.Join(stateDir, "step_summary")
+	if err := os.WriteFile(summaryPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake container writes a status into whatever is mounted at /out.
+	writeFakeCommand(t, binDir, "docker", `#!/usr/bin/env sh
+case "$1" in
+  info)
+    exit 0
+    ;;
+  run)
+    printf '%s\n' "$*" >> "$TEST_DOCKER_LOG"
+    for arg in "$@"; do
+      case "$arg" in
+        *:/out) out="${arg%:/out}" ;;
+      esac
+    done
+    echo "status=OK" > "$out/verify-full.status"
+    ;;
+esac
+`)
+
+	out, err := runVerifyFullWithEnv(t, []string{
+		"PATH=" + binDir + ":/usr/bin:/bin",
+		"OUT_DIR=" + outDir,
+		"VERIFY_SHARDS=2",
+		"TEST_DOCKER_LOG=" + dockerLog,
+		"GITHUB_STEP_SUMMARY=" + summaryPath,
+	})
+	if err != nil {
+		t.Fatalf("expected sharded run to succeed: %v\noutput:\n%s", err, out)
+	}
+	body, readErr := os.ReadFile(dockerLog)
+	if readErr != nil {
+		t.Fatalf("expected docker run log: %v\noutput:\n%s", readErr, out)
+	}
+	runs := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(runs) != 3 {
+		t.Fatalf("expected 2 shard runs and 1 merge run\nruns:\n%s", body)
+	}
+	// Shards run in the background, so their order in the log varies.
+	for i, shard := range []string{"0/2", "1/2"} {
+		want := "-v " + filepath.Join(outDir, "shards", shard[:1]) + ":/out"
+		found := false
+		for _, run := range runs[:2] {
+			if strings.Contains(run, want) && strings.Contains(run, "-e VERIFY_FULL_SHARD="+shard) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("shard run %d missing\nruns:\n%s", i, body)
+		}
+	}
+	if strings.Contains(strings.Join(runs[:2], "\n"), "/gha/step_summary") {
+		t.Fatalf("shard runs must not write the job summary\nruns:\n%s", body)
+	}
+	merge := runs[2]
+	if !strings.Contains(merge, "-v "+outDir+":/out") || !strings.HasSuffix(merge, "/usr/local/bin/verify-full --merge-shards 2") ||
+		!strings.Contains(merge, "GITHUB_STEP_SUMMARY=/gha/step_summary") {
+		t.Fatalf("merge run args:\n%s", merge)
+	}
+	if !strings.Contains(out, "OK: shard=1/2 exit_code=0") {
+		t.Fatalf("expected shard exit codes\noutput:\n%s", out)
+	}
+}
+
+func TestRunVerifyFullRejectsInvalidShardCount(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	out, err := runVerifyFullWithEnv(t, []string{
+		"PATH=/usr/bin:/bin",
+		"OUT_DIR=" + outDir,
+		"VERIFY_SHARDS=two",
+	})
+	if err == nil {
+		t.Fatalf("expected invalid VERIFY_SHARDS to fail\noutput:\n%s", out)
+	}
+	body, readErr := os.ReadFile(filepath.Join(outDir, "verify-full.status"))
+	if readErr != nil || !strings.Contains(string(body), "reason=invalid_verify_shards") {
+		t.Fatalf("status:\n%s\nerr=%v\noutput:\n%s", body, readErr, out)
+	}
+}
+
+func TestRunVerifyFullHermeticFetchesBeforeIsolatedRun(t *testing.T) {
+	binDir := t.TempDir()
+	stateDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+	dockerLog := filepath.Join(stateDir, "docker-run.log")
+
+	// The fake fetch container reports OK in out/fetch; the isolated runs
+	// write a status into whatever is mounted at /out.
+	writeFakeCommand(t, binDir, "docker", `#!/usr/bin/env sh
+case "$1" in
+  info)
+    exit 0
+    ;;
+  run)
+    printf '%s\n' "$*" >> "$TEST_DOCKER_LOG"
+    for arg in "$@"; do
+      case "$arg" in
+        *:/out) out="${arg%:/out}" ;;
+      esac
+    done
+    case "$*" in
+      *--fetch) mkdir -p "$out/fetch" && echo "status=OK" > "$out/fetch/verify-full-fetch.status" ;;
+      *) echo "status=OK" > "$out/verify-full.status" ;;
+    esac
+    ;;
+esac
+`)
+
+	out, err := runVerifyFullWithEnv(t, []string{
+		"PATH=" + binDir + ":/usr/bin:/bin",
+		"OUT_DIR=" + outDir,
+		"VERIFY_ISOLATION=hermetic",
+		"VERIFY_SHARDS=2",
+		"TEST_DOCKER_LOG=" + dockerLog,
+	})
+	if err != nil {
+		t.Fatalf("expected hermetic run to succeed: %v\noutput:\n%s", err, out)
+	}
+	body, readErr := os.ReadFile(dockerLog)
+	if readErr != nil {
+		t.Fatalf("expected docker run log: %v\noutput:\n%s", readErr, out)
+	}
+	runs := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(runs) != 4 {
+		t.Fatalf("expected a fetch run, 2 shard runs and a merge run\nruns:\n%s", body)
+	}
+	fetch := runs[0]
+	if !strings.HasSuffix(fetch, "/usr/local/bin/verify-full --fetch") || strings.Contains(fetch, "--network none") ||
+		!strings.Contains(fetch, "-e VERIFY_ISOLATION=standard") {
+		t.Fatalf("fetch run args:\n%s", fetch)
+	}
+	for _, isolated := range runs[1:] {
+		for _, want := range []string{
+			"--network none",
+			"--read-only",
+			"--cap-drop ALL",
+			"--security-opt no-new-privileges",
+			"--tmpfs /tmp:rw,exec,nosuid,nodev,size=2g",
+			"-e VERIFY_ISOLATION=hermetic",
+		} {
+			if !strings.Contains(isolated, want) {
+				t.Fatalf("isolated run missing %q\nargs:\n%s", want, isolated)
+			}
+		}
+	}
+	// Shards mount out/shards/<i> at /out, so they read the fetch result
+	// from a read-only mount; the merge run finds it under its own /out.
+	fetchMount := "-v " + filepath.Join(outDir, "fetch") + ":/fetch:ro -e VERIFY_FULL_FETCH_DIR=/fetch"
+	for _, shard := range runs[1:3] {
+		if !strings.Contains(shard, fetchMount) {
+			t.Fatalf("shard run missing %q\nargs:\n%s", fetchMount, shard)
+		}
+	}
+	if strings.Contains(runs[3], "/fetch:ro") {
+		t.Fatalf("merge run args:\n%s", runs[3])
+	}
+}
+
+func TestRunVerifyFullHermeticStopsWhenFetchFails(t *testing.T) {
+	binDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	writeFakeCommand(t, binDir, "docker", `#!/usr/bin/env sh
+case "$1" in
+  info)
+    exit 0
+    ;;
+  run)
+    case "$*" in
+      *--fetch) mkdir -p "$TEST_OUT_DIR/fetch" && echo "status=ERROR" > "$TEST_OUT_DIR/fetch/verify-full-fetch.status" ;;
+      *) echo "isolated run must not start" >&2; exit 99 ;;
+    esac
+    ;;
+esac
+`)
+
+	out, err := runVerifyFullWithEnv(t, []string{
+		"PATH=" + binDir + ":/usr/bin:/bin",
+		"OUT_DIR=" + outDir,
+		"VERIFY_ISOLATION=hermetic",
+		"TEST_OUT_DIR=" + outDir,
+	})
+	if err == nil {
+		t.Fatalf("expected failed fetch to stop the run\noutput:\n%s", out)
+	}
+	body, readErr := os.ReadFile(filepath.Join(outDir, "verify-full.status"))
+	if readErr != nil || !strings.Contains(string(body), "reason=fetch_failed") || !strings.Contains(string(body), "isolation=hermetic") {
+		t.Fatalf("status:\n%s\nerr=%v\noutput:\n%s", body, readErr, out)
+	}
+}